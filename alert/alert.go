@@ -1,91 +1,413 @@
 package alert
 
 import (
-	"bytes"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
+	"strings"
+	"sync"
+	"text/template"
 	"time"
 
+	"github.com/hmw/cos-uploader/config"
 	"github.com/hmw/cos-uploader/logger"
 )
 
-// Alert 报警器
+// 报警限流默认参数
+const (
+	defaultRateLimit  = 5 * time.Minute // 每个窗口内最多发送一条报警
+	maxSummarySamples = 10              // 汇总报警中最多列出的条目数
+)
+
+// Alert 报警器，将报警发送到一个或多个通知后端
 type Alert struct {
-	webhook string
-	logger  *logger.Logger
-	client  *http.Client
-}
+	notifiers []Notifier
+	logger    *logger.Logger
 
-// DingTalkMessage 钉钉消息格式
-type DingTalkMessage struct {
-	MsgType string      `json:"msgtype"`
-	Text    TextContent `json:"text"`
-}
+	format    string     // 消息格式：text、markdown 或 actioncard
+	templates *Templates // 各类报警的消息模板
+
+	textOnce       sync.Once
+	plainTemplates *Templates // 内置纯文本模板，格式化消息发送给不支持 Markdown 的后端时使用
+
+	bucket string // COS 存储桶，用于生成控制台链接
+	region string
+
+	health        *healthTracker // 项目健康状态，由 mu 保护
+	healthProject string         // 健康状态所属的项目
+	healthTimer   *time.Timer    // 没有新的上传结果时重新计算健康状态
 
-// TextContent 文本内容
-type TextContent struct {
-	Content string `json:"content"`
+	// 限流状态：窗口内的后续报警合并为一条汇总报警
+	rateLimit       time.Duration
+	mu              sync.Mutex
+	lastSent        time.Time
+	suppressed      []string
+	suppressedCount int
+	timer           *time.Timer
+	lastSend        chan struct{} // 最近一次后台发送完成的信号，保证报警按顺序送达
+	wg              sync.WaitGroup
 }
 
-// NewAlert 创建报警器
+// NewAlert 创建使用钉钉 webhook 的报警器
 func NewAlert(webhook string, log *logger.Logger) *Alert {
-	return &Alert{
-		webhook: webhook,
-		logger:  log,
-		client: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+	var notifiers []Notifier
+	if webhook != "" {
+		notifiers = append(notifiers, NewDingTalkNotifier(webhook, ""))
 	}
+	return NewAlertWithNotifiers(notifiers, log)
 }
 
-// SendAlert 发送报警
-func (a *Alert) SendAlert(title, message string) error {
-	if a.webhook == "" {
-		a.logger.Warn("DingTalk webhook not configured, alert not sent", "title", title)
-		return nil
+// NewAlertWithNotifiers 创建发送到指定通知后端的报警器
+func NewAlertWithNotifiers(notifiers []Notifier, log *logger.Logger) *Alert {
+	return &Alert{
+		notifiers: notifiers,
+		logger:    log,
+		format:    "text",
+		templates: defaultTemplates("text"),
+		health:    newHealthTracker(config.HealthConfig{}),
+		rateLimit: defaultRateLimit,
 	}
+}
 
-	// 构建消息
-	content := fmt.Sprintf("%s\n\n%s\n\nTime: %s", title, message, time.Now().Format(time.RFC3339))
-	msg := DingTalkMessage{
-		MsgType: "text",
-		Text: TextContent{
-			Content: content,
-		},
+// NewAlertFromConfig 根据项目报警配置创建报警器
+// dingtalk_webhook 和 notifiers 中的所有目标都会收到报警
+func NewAlertFromConfig(cfg config.AlertConfig, log *logger.Logger) (*Alert, error) {
+	var notifiers []Notifier
+	if cfg.DingTalkWebhook != "" {
+		notifiers = append(notifiers, NewDingTalkNotifier(cfg.DingTalkWebhook, cfg.DingTalkSecret))
+	}
+	for _, target := range cfg.Notifiers {
+		notifier, err := NewNotifier(target)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, notifier)
 	}
 
-	// 转换为JSON
-	jsonData, err := json.Marshal(msg)
+	templates, err := ParseTemplates(cfg.Templates, cfg.Format)
 	if err != nil {
-		return fmt.Errorf("failed to marshal message: %w", err)
+		return nil, err
 	}
 
-	// 发送HTTP POST请求
-	resp, err := a.client.Post(a.webhook, "application/json", bytes.NewReader(jsonData))
-	if err != nil {
-		return fmt.Errorf("failed to send alert: %w", err)
+	a := NewAlertWithNotifiers(notifiers, log)
+	if cfg.Format != "" {
+		a.format = cfg.Format
+	}
+	a.templates = templates
+	a.health = newHealthTracker(cfg.Health)
+	if cfg.RateLimit > 0 {
+		a.SetRateLimit(time.Duration(cfg.RateLimit) * time.Second)
+	} else if cfg.RateLimit < 0 {
+		a.SetRateLimit(0)
 	}
-	defer resp.Body.Close()
+	return a, nil
+}
 
-	// 读取响应
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response: %w", err)
+// HasNotifiers 是否配置了通知后端
+func (a *Alert) HasNotifiers() bool {
+	return len(a.notifiers) > 0
+}
+
+// SetRateLimit 设置报警限流窗口，0 表示不限流
+func (a *Alert) SetRateLimit(interval time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.rateLimit = interval
+}
+
+// SetBucket 设置项目的 COS 存储桶，报警中会附带对象在控制台中的链接
+func (a *Alert) SetBucket(bucket, region string) {
+	a.bucket = bucket
+	a.region = region
+}
+
+// SendAlert 发送纯文本报警到所有通知后端
+func (a *Alert) SendAlert(title, message string) error {
+	return a.SendMessage(Message{Title: title, Content: message})
+}
+
+// SendMessage 发送报警消息到所有通知后端
+// 某个后端失败不影响其他后端，返回所有失败后端的错误
+func (a *Alert) SendMessage(msg Message) error {
+	if len(a.notifiers) == 0 {
+		a.logger.Warn("No alert notifier configured, alert not sent", "title", msg.Title)
+		return nil
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("DingTalk API returned status %d: %s", resp.StatusCode, string(body))
+	var errs []error
+	for _, notifier := range a.notifiers {
+		var err error
+		if mn, ok := notifier.(MessageNotifier); ok {
+			err = mn.SendMessage(msg)
+		} else if msg.Text != "" {
+			err = notifier.Send(msg.Title, msg.Text)
+		} else {
+			err = notifier.Send(msg.Title, msg.Content)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", notifier.Name(), err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
 	}
 
-	a.logger.Info("Alert sent successfully", "title", title)
+	a.logger.Info("Alert sent successfully", "title", msg.Title, "notifiers", len(a.notifiers))
 	return nil
 }
 
 // SendUploadFailureAlert 发送上传失败报警
 func (a *Alert) SendUploadFailureAlert(projectName, filePath string, err error) error {
-	title := "COS Upload Failed"
-	message := fmt.Sprintf("Project: %s\nFile: %s\nError: %v", projectName, filePath, err)
-	return a.SendAlert(title, message)
+	return a.SendMessage(a.uploadFailureMessage(projectName, filePath, "", err))
+}
+
+// SendFullUploadReport 发送全量上传报告（不限流）
+func (a *Alert) SendFullUploadReport(data FullUploadReportData) error {
+	if data.Time == "" {
+		data.Time = time.Now().Format(time.RFC3339)
+	}
+	for i := range data.Failures {
+		if data.Failures[i].ConsoleURL == "" {
+			data.Failures[i].ConsoleURL = ConsoleURL(a.bucket, a.region, data.Failures[i].RemotePath)
+		}
+	}
+
+	title := "COS Full Upload Report"
+	if data.FailedFiles > 0 {
+		title = fmt.Sprintf("COS Full Upload Report: %d failed", data.FailedFiles)
+	}
+	return a.SendMessage(a.message(title, func(t *Templates) *template.Template { return t.fullUploadReport }, data, ""))
+}
+
+// SendSummaryReport 发送定时汇总报告（不限流）
+func (a *Alert) SendSummaryReport(data SummaryReportData) error {
+	if data.Time == "" {
+		data.Time = time.Now().Format(time.RFC3339)
+	}
+	for i := range data.Slowest {
+		if data.Slowest[i].ConsoleURL == "" {
+			data.Slowest[i].ConsoleURL = ConsoleURL(a.bucket, a.region, data.Slowest[i].RemotePath)
+		}
+	}
+	for i := range data.Failures {
+		if data.Failures[i].ConsoleURL == "" {
+			data.Failures[i].ConsoleURL = ConsoleURL(a.bucket, a.region, data.Failures[i].RemotePath)
+		}
+	}
+
+	title := "COS Uploader Summary Report"
+	return a.SendMessage(a.message(title, func(t *Templates) *template.Template { return t.summaryReport }, data, ""))
+}
+
+// NotifyUploadFailure 异步发送上传失败报警（限流）
+func (a *Alert) NotifyUploadFailure(projectName, filePath, remotePath string, err error) {
+	msg := a.uploadFailureMessage(projectName, filePath, remotePath, err)
+	a.notify(msg, fmt.Sprintf("[%s] upload failed: %s (%v)", projectName, filePath, err))
+}
+
+// NotifyWatcherError 异步发送文件监听错误报警（限流）
+func (a *Alert) NotifyWatcherError(projectName string, err error) {
+	data := WatcherErrorData{
+		Project: projectName,
+		Error:   fmt.Sprint(err),
+		Time:    time.Now().Format(time.RFC3339),
+	}
+	msg := a.message("COS Uploader Watcher Error", func(t *Templates) *template.Template { return t.watcherError }, data, "")
+	a.notify(msg, fmt.Sprintf("[%s] watcher error: %v", projectName, err))
+}
+
+// ObserveUpload 记录一次上传尝试的结果，更新项目健康状态
+// 状态变差时发送一条报警，恢复为 healthy 时发送一条恢复通知；
+// 状态不变时不发送，状态变化通知不受限流影响。
+// 上传停止后窗口内的结果过期时也会重新计算状态
+func (a *Alert) ObserveUpload(projectName string, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.healthProject = projectName
+	from, to := a.health.observe(time.Now(), err != nil)
+	a.healthChanged(from, to)
+	a.scheduleHealthCheck()
+}
+
+// checkHealth 在没有新的上传结果时重新计算健康状态
+func (a *Alert) checkHealth() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.healthTimer = nil
+	from, to := a.health.evaluate(time.Now())
+	a.healthChanged(from, to)
+	a.scheduleHealthCheck()
+}
+
+// scheduleHealthCheck 在窗口内最早的结果过期时重新计算健康状态（调用者需持有锁）
+func (a *Alert) scheduleHealthCheck() {
+	if a.healthTimer != nil {
+		a.healthTimer.Stop()
+		a.healthTimer = nil
+	}
+	if delay, ok := a.health.nextCheck(time.Now()); ok {
+		a.healthTimer = time.AfterFunc(delay, a.checkHealth)
+	}
+}
+
+// healthChanged 记录健康状态变化并发送通知（调用者需持有锁）
+func (a *Alert) healthChanged(from, to HealthState) {
+	if from == to {
+		return
+	}
+
+	projectName := a.healthProject
+
+	a.logger.Info("Project health changed", "project", projectName, "from", from.String(), "to", to.String(),
+		"failure_rate", a.health.failureRate())
+	if to < from && to != HealthHealthy {
+		return
+	}
+
+	data := HealthChangeData{
+		Project:     projectName,
+		From:        from.String(),
+		To:          to.String(),
+		Recovered:   to == HealthHealthy,
+		FailureRate: fmt.Sprintf("%.0f%%", a.health.failureRate()*100),
+		Failures:    a.health.failures(),
+		Total:       len(a.health.results),
+		Window:      a.health.window.String(),
+		Time:        time.Now().Format(time.RFC3339),
+	}
+	title := "COS Uploader Recovered"
+	switch to {
+	case HealthDegraded:
+		title = "COS Uploader Degraded"
+	case HealthFailing:
+		title = "COS Uploader Failing"
+	}
+	a.sendAsync(a.message(title, func(t *Templates) *template.Template { return t.healthChange }, data, ""))
+}
+
+// uploadFailureMessage 生成上传失败报警消息
+func (a *Alert) uploadFailureMessage(projectName, filePath, remotePath string, err error) Message {
+	data := UploadFailureData{
+		Project:    projectName,
+		FilePath:   filePath,
+		RemotePath: remotePath,
+		Error:      fmt.Sprint(err),
+		Time:       time.Now().Format(time.RFC3339),
+		ConsoleURL: ConsoleURL(a.bucket, a.region, remotePath),
+	}
+	return a.message("COS Upload Failed", func(t *Templates) *template.Template { return t.uploadFailure }, data, data.ConsoleURL)
+}
+
+// message 使用报警模板生成消息，模板执行失败时退回内置模板
+// 格式化消息同时使用内置纯文本模板生成 Text，发送给不支持 Markdown 的后端
+func (a *Alert) message(title string, pick func(*Templates) *template.Template, data interface{}, link string) Message {
+	content, err := render(pick(a.templates), data)
+	if err != nil {
+		a.logger.Warn("Failed to render alert template, using built-in template", "title", title, "error", err)
+		content, _ = render(pick(defaultTemplates(a.format)), data)
+	}
+	msg := Message{Title: title, Content: content, Format: a.format, Link: link}
+	if isMarkdownFormat(a.format) {
+		msg.Text, _ = render(pick(a.textTemplates()), data)
+	}
+	return msg
+}
+
+// textTemplates 返回内置纯文本模板，首次调用时解析
+func (a *Alert) textTemplates() *Templates {
+	a.textOnce.Do(func() {
+		a.plainTemplates = defaultTemplates("text")
+	})
+	return a.plainTemplates
+}
+
+// notify 限流发送报警
+// 窗口内的第一条报警立即发送，其余报警只记录摘要，窗口结束时合并为一条汇总报警
+func (a *Alert) notify(msg Message, summary string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	if a.rateLimit <= 0 || (a.suppressedCount == 0 && now.Sub(a.lastSent) >= a.rateLimit) {
+		a.lastSent = now
+		a.sendAsync(msg)
+		return
+	}
+
+	a.suppressedCount++
+	if len(a.suppressed) < maxSummarySamples {
+		a.suppressed = append(a.suppressed, summary)
+	}
+	if a.timer == nil {
+		a.timer = time.AfterFunc(a.lastSent.Add(a.rateLimit).Sub(now), a.flushSummary)
+	}
+}
+
+// sendAsync 在后台发送报警，等待前一条报警发送完成后再发送（调用者需持有锁）
+func (a *Alert) sendAsync(msg Message) {
+	prev := a.lastSend
+	done := make(chan struct{})
+	a.lastSend = done
+
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		defer close(done)
+		if prev != nil {
+			<-prev
+		}
+		if err := a.SendMessage(msg); err != nil {
+			a.logger.Error("Failed to send alert", "title", msg.Title, "error", err)
+		}
+	}()
+}
+
+// flushSummary 发送窗口内被合并的报警汇总
+func (a *Alert) flushSummary() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.timer = nil
+	if a.suppressedCount == 0 {
+		return
+	}
+
+	title := fmt.Sprintf("COS Uploader: %d more alerts", a.suppressedCount)
+	lines := a.suppressed
+	if a.suppressedCount > len(a.suppressed) {
+		lines = append(lines, fmt.Sprintf("... and %d more", a.suppressedCount-len(a.suppressed)))
+	}
+
+	msg := Message{Title: title, Format: a.format}
+	msg.Content = fmt.Sprintf("%d alerts were suppressed in the last %s.\n\n%s",
+		a.suppressedCount, a.rateLimit, strings.Join(lines, "\n"))
+	if isMarkdownFormat(a.format) {
+		// Markdown 中单个换行不分段，使用列表
+		msg.Text = msg.Content
+		msg.Content = fmt.Sprintf("### %s\n\n%d alerts were suppressed in the last %s.\n\n- %s",
+			title, a.suppressedCount, a.rateLimit, strings.Join(lines, "\n- "))
+	}
+
+	a.suppressed = nil
+	a.suppressedCount = 0
+	a.lastSent = time.Now()
+	a.sendAsync(msg)
+}
+
+// Flush 立即发送尚未发送的汇总报警，停止健康状态的定时检查，并等待所有报警发送完成
+// 在进程退出前调用
+func (a *Alert) Flush() {
+	a.mu.Lock()
+	if a.timer != nil {
+		a.timer.Stop()
+	}
+	if a.healthTimer != nil {
+		a.healthTimer.Stop()
+		a.healthTimer = nil
+	}
+	a.mu.Unlock()
+
+	a.flushSummary()
+	a.wg.Wait()
 }
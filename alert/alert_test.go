@@ -1,7 +1,16 @@
 package alert
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/hmw/cos-uploader/logger"
 )
@@ -48,3 +57,99 @@ func TestSendUploadFailureAlert(t *testing.T) {
 		t.Errorf("Expected no error, got %v", err)
 	}
 }
+
+// newTestServer 创建记录收到的报警内容的钉钉模拟服务
+func newTestServer(t *testing.T) (*httptest.Server, func() []string) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var contents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg DingTalkMessage
+		json.NewDecoder(r.Body).Decode(&msg)
+		mu.Lock()
+		contents = append(contents, msg.Text.Content)
+		mu.Unlock()
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), contents...)
+	}
+}
+
+func TestNotifyUploadFailureRateLimited(t *testing.T) {
+	server, received := newTestServer(t)
+	log := &logger.Logger{}
+	log.SetWriter(io.Discard, io.Discard)
+
+	a := NewAlert(server.URL, log)
+	a.SetRateLimit(time.Hour)
+
+	for i := 0; i < 50; i++ {
+		a.NotifyUploadFailure("test-project", fmt.Sprintf("/data/file%d.txt", i), fmt.Sprintf("file%d.txt", i), errors.New("timeout"))
+	}
+	a.Flush()
+
+	// 第一条立即发送，其余合并为一条汇总
+	got := received()
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 alerts (first + summary), got %d", len(got))
+	}
+	if !strings.Contains(got[0], "/data/file0.txt") {
+		t.Errorf("First alert should contain the first file, got %q", got[0])
+	}
+	if !strings.Contains(got[1], "49 more alerts") {
+		t.Errorf("Summary should report 49 suppressed alerts, got %q", got[1])
+	}
+	if !strings.Contains(got[1], "and 39 more") {
+		t.Errorf("Summary should truncate samples, got %q", got[1])
+	}
+}
+
+func TestNotifySummaryAfterWindow(t *testing.T) {
+	server, received := newTestServer(t)
+	log := &logger.Logger{}
+	log.SetWriter(io.Discard, io.Discard)
+
+	a := NewAlert(server.URL, log)
+	a.SetRateLimit(50 * time.Millisecond)
+
+	a.NotifyWatcherError("test-project", errors.New("queue overflow"))
+	a.NotifyWatcherError("test-project", errors.New("queue overflow"))
+
+	// 窗口结束后自动发送汇总
+	deadline := time.Now().Add(2 * time.Second)
+	for len(received()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := received(); len(got) != 2 {
+		t.Fatalf("Expected summary after rate limit window, got %d alerts", len(got))
+	}
+
+	a.Flush()
+	if got := received(); len(got) != 2 {
+		t.Errorf("Flush should not send anything without suppressed alerts, got %d", len(got))
+	}
+}
+
+func TestNotifyWithoutRateLimit(t *testing.T) {
+	server, received := newTestServer(t)
+	log := &logger.Logger{}
+	log.SetWriter(io.Discard, io.Discard)
+
+	a := NewAlert(server.URL, log)
+	a.SetRateLimit(0)
+
+	for i := 0; i < 3; i++ {
+		a.NotifyUploadFailure("test-project", "/data/file.txt", "file.txt", errors.New("timeout"))
+	}
+	a.Flush()
+
+	if got := received(); len(got) != 3 {
+		t.Errorf("Expected 3 alerts without rate limit, got %d", len(got))
+	}
+}
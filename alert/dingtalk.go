@@ -0,0 +1,152 @@
+package alert
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// DingTalkMessage 钉钉消息格式
+type DingTalkMessage struct {
+	MsgType    string            `json:"msgtype"`
+	Text       TextContent       `json:"text,omitzero"`
+	Markdown   MarkdownContent   `json:"markdown,omitzero"`
+	ActionCard ActionCardContent `json:"actionCard,omitzero"`
+}
+
+// TextContent 文本内容
+type TextContent struct {
+	Content string `json:"content"`
+}
+
+// MarkdownContent Markdown 内容
+type MarkdownContent struct {
+	Title string `json:"title"`
+	Text  string `json:"text"`
+}
+
+// ActionCardContent 整体跳转的 ActionCard 内容
+type ActionCardContent struct {
+	Title       string `json:"title"`
+	Text        string `json:"text"`
+	SingleTitle string `json:"singleTitle"`
+	SingleURL   string `json:"singleURL"`
+}
+
+// DingTalkResponse 钉钉接口响应
+type DingTalkResponse struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+// DingTalkNotifier 钉钉机器人通知后端
+type DingTalkNotifier struct {
+	webhook string
+	secret  string // 加签密钥，为空时不签名
+	client  *http.Client
+}
+
+// NewDingTalkNotifier 创建钉钉机器人通知后端
+// secret 不为空时使用加签方式发送
+func NewDingTalkNotifier(webhook, secret string) *DingTalkNotifier {
+	return &DingTalkNotifier{webhook: webhook, secret: secret, client: newHTTPClient()}
+}
+
+// Name 返回后端名称
+func (n *DingTalkNotifier) Name() string {
+	return "dingtalk"
+}
+
+// Send 发送文本消息
+func (n *DingTalkNotifier) Send(title, message string) error {
+	return n.SendMessage(Message{Title: title, Content: message})
+}
+
+// SendMessage 按消息格式发送文本、Markdown 或 ActionCard 消息
+// ActionCard 消息没有链接时按 Markdown 发送
+// 钉钉拒绝消息时仍返回 HTTP 200，需要检查 errcode
+func (n *DingTalkNotifier) SendMessage(msg Message) error {
+	webhook, err := n.signedURL(time.Now())
+	if err != nil {
+		return err
+	}
+
+	body, err := postJSON(n.client, webhook, newDingTalkMessage(msg))
+	if err != nil {
+		return err
+	}
+
+	var resp DingTalkResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	if resp.ErrCode != 0 {
+		return fmt.Errorf("DingTalk API returned errcode %d: %s", resp.ErrCode, resp.ErrMsg)
+	}
+	return nil
+}
+
+// newDingTalkMessage 将报警消息转换为钉钉消息
+func newDingTalkMessage(msg Message) DingTalkMessage {
+	switch {
+	case msg.Format == "actioncard" && msg.Link != "":
+		return DingTalkMessage{
+			MsgType: "actionCard",
+			ActionCard: ActionCardContent{
+				Title:       msg.Title,
+				Text:        msg.Content,
+				SingleTitle: "View in COS Console",
+				SingleURL:   msg.Link,
+			},
+		}
+	case isMarkdownFormat(msg.Format):
+		return DingTalkMessage{
+			MsgType: "markdown",
+			Markdown: MarkdownContent{
+				Title: msg.Title,
+				Text:  msg.Content,
+			},
+		}
+	default:
+		return DingTalkMessage{
+			MsgType: "text",
+			Text: TextContent{
+				Content: formatText(msg.Title, msg.Content),
+			},
+		}
+	}
+}
+
+// signedURL 返回带 timestamp 和 sign 参数的 webhook URL
+// 签名为 HMAC-SHA256(secret, timestamp + "\n" + secret) 的 Base64 编码
+func (n *DingTalkNotifier) signedURL(now time.Time) (string, error) {
+	if n.secret == "" {
+		return n.webhook, nil
+	}
+
+	u, err := url.Parse(n.webhook)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse DingTalk webhook: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(now.UnixMilli(), 10)
+	query := u.Query()
+	query.Set("timestamp", timestamp)
+	query.Set("sign", dingTalkSign(timestamp, n.secret))
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+// dingTalkSign 计算钉钉加签签名
+func dingTalkSign(timestamp, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + secret))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
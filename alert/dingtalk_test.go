@@ -0,0 +1,68 @@
+package alert
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDingTalkSign(t *testing.T) {
+	// 与钉钉文档中的签名算法一致：Base64(HMAC-SHA256(secret, timestamp + "\n" + secret))
+	got := dingTalkSign("1577262236757", "SEC123")
+	if got != "Z/IOagKYTkrnYtxAsTKneRe0bzmlPCH3ZDJPTD2h9QA=" {
+		t.Errorf("Unexpected sign %q", got)
+	}
+}
+
+func TestDingTalkSignedURL(t *testing.T) {
+	n := NewDingTalkNotifier("https://oapi.dingtalk.com/robot/send?access_token=abc", "SEC123")
+	now := time.UnixMilli(1577262236757)
+
+	signed, err := n.signedURL(now)
+	if err != nil {
+		t.Fatalf("signedURL failed: %v", err)
+	}
+
+	u, _ := url.Parse(signed)
+	query := u.Query()
+	if query.Get("access_token") != "abc" {
+		t.Errorf("access_token should be preserved, got %s", signed)
+	}
+	if query.Get("timestamp") != "1577262236757" {
+		t.Errorf("Expected timestamp 1577262236757, got %s", query.Get("timestamp"))
+	}
+	if query.Get("sign") != dingTalkSign("1577262236757", "SEC123") {
+		t.Errorf("Unexpected sign %s", query.Get("sign"))
+	}
+
+	// 未配置密钥时不修改 URL
+	unsigned := NewDingTalkNotifier("https://oapi.dingtalk.com/robot/send?access_token=abc", "")
+	if got, _ := unsigned.signedURL(now); got != "https://oapi.dingtalk.com/robot/send?access_token=abc" {
+		t.Errorf("Unsigned URL should be unchanged, got %s", got)
+	}
+}
+
+func TestDingTalkNotifierSignedRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timestamp := r.URL.Query().Get("timestamp")
+		if timestamp == "" || r.URL.Query().Get("sign") != dingTalkSign(timestamp, "SEC123") {
+			w.Write([]byte(`{"errcode":310000,"errmsg":"sign not match"}`))
+			return
+		}
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	defer server.Close()
+
+	if err := NewDingTalkNotifier(server.URL+"?access_token=abc", "SEC123").Send("Title", "Message"); err != nil {
+		t.Errorf("Signed request should be accepted, got %v", err)
+	}
+
+	// 钉钉拒绝消息时返回 HTTP 200，需要根据 errcode 判断
+	err := NewDingTalkNotifier(server.URL+"?access_token=abc", "").Send("Title", "Message")
+	if err == nil || !strings.Contains(err.Error(), "310000") {
+		t.Errorf("Expected errcode error for unsigned request, got %v", err)
+	}
+}
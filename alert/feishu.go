@@ -0,0 +1,64 @@
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// FeishuMessage 飞书自定义机器人消息格式
+type FeishuMessage struct {
+	MsgType string        `json:"msg_type"`
+	Content FeishuContent `json:"content"`
+}
+
+// FeishuContent 飞书文本内容
+type FeishuContent struct {
+	Text string `json:"text"`
+}
+
+// FeishuResponse 飞书接口响应
+type FeishuResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// FeishuNotifier 飞书自定义机器人通知后端
+type FeishuNotifier struct {
+	webhook string
+	client  *http.Client
+}
+
+// NewFeishuNotifier 创建飞书自定义机器人通知后端
+func NewFeishuNotifier(webhook string) *FeishuNotifier {
+	return &FeishuNotifier{webhook: webhook, client: newHTTPClient()}
+}
+
+// Name 返回后端名称
+func (n *FeishuNotifier) Name() string {
+	return "feishu"
+}
+
+// Send 发送文本消息
+// 飞书拒绝消息时仍可能返回 HTTP 200，需要检查 code
+func (n *FeishuNotifier) Send(title, message string) error {
+	msg := FeishuMessage{
+		MsgType: "text",
+		Content: FeishuContent{
+			Text: formatText(title, message),
+		},
+	}
+	body, err := postJSON(n.client, n.webhook, msg)
+	if err != nil {
+		return err
+	}
+
+	var resp FeishuResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	if resp.Code != 0 {
+		return fmt.Errorf("Feishu API returned code %d: %s", resp.Code, resp.Msg)
+	}
+	return nil
+}
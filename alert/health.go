@@ -0,0 +1,154 @@
+package alert
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hmw/cos-uploader/config"
+)
+
+// HealthState 项目健康状态
+type HealthState int
+
+const (
+	HealthHealthy  HealthState = iota // 正常
+	HealthDegraded                    // 部分上传失败
+	HealthFailing                     // 大部分上传失败
+)
+
+// String 返回状态名称
+func (s HealthState) String() string {
+	switch s {
+	case HealthHealthy:
+		return "healthy"
+	case HealthDegraded:
+		return "degraded"
+	case HealthFailing:
+		return "failing"
+	default:
+		return fmt.Sprintf("HealthState(%d)", int(s))
+	}
+}
+
+// uploadResult 一次上传尝试的结果
+type uploadResult struct {
+	at     time.Time
+	failed bool
+}
+
+// healthTracker 根据时间窗口内的上传失败率计算项目健康状态
+type healthTracker struct {
+	window       time.Duration
+	degradedRate float64
+	failingRate  float64
+	minSamples   int
+	hysteresis   float64 // 状态变好时失败率需低于进入阈值减去该值
+
+	results []uploadResult
+	state   HealthState
+}
+
+// newHealthTracker 根据配置创建健康状态跟踪器
+func newHealthTracker(cfg config.HealthConfig) *healthTracker {
+	t := &healthTracker{
+		window:       time.Duration(cfg.Window) * time.Second,
+		degradedRate: cfg.DegradedRate,
+		failingRate:  cfg.FailingRate,
+		minSamples:   cfg.MinSamples,
+		hysteresis:   cfg.Hysteresis,
+	}
+	// 未经 Config.Validate 的配置使用默认值
+	if t.window <= 0 {
+		t.window = 5 * time.Minute
+	}
+	if t.degradedRate <= 0 {
+		t.degradedRate = 0.2
+	}
+	if t.failingRate <= 0 {
+		t.failingRate = 0.5
+	}
+	if t.minSamples <= 0 {
+		t.minSamples = 5
+	}
+	if t.hysteresis == 0 {
+		t.hysteresis = min(0.1, t.degradedRate/2)
+	}
+	if t.hysteresis < 0 || t.hysteresis >= t.degradedRate {
+		t.hysteresis = 0
+	}
+	return t
+}
+
+// observe 记录一次上传结果，返回记录前后的状态
+func (t *healthTracker) observe(now time.Time, failed bool) (from, to HealthState) {
+	t.results = append(t.results, uploadResult{at: now, failed: failed})
+	return t.evaluate(now)
+}
+
+// evaluate 丢弃窗口外的结果并重新计算状态，返回计算前后的状态
+// 样本数不足 minSamples 时不会变差，窗口内没有失败时恢复为 healthy；
+// 状态变好时失败率需低于进入阈值减去 hysteresis
+func (t *healthTracker) evaluate(now time.Time) (from, to HealthState) {
+	// 丢弃窗口外的结果
+	cutoff := now.Add(-t.window)
+	i := 0
+	for i < len(t.results) && t.results[i].at.Before(cutoff) {
+		i++
+	}
+	t.results = t.results[i:]
+
+	from = t.state
+	if len(t.results) < t.minSamples {
+		if t.failures() == 0 {
+			t.state = HealthHealthy
+		}
+	} else {
+		rate := t.failureRate()
+		state := HealthHealthy
+		switch {
+		case rate >= t.failingRate:
+			state = HealthFailing
+		case rate >= t.degradedRate:
+			state = HealthDegraded
+		}
+		// 失败率刚好降到阈值以下时保持原状态
+		if state < t.state {
+			switch {
+			case t.state == HealthFailing && rate >= t.failingRate-t.hysteresis:
+				state = HealthFailing
+			case rate >= t.degradedRate-t.hysteresis:
+				state = HealthDegraded
+			}
+		}
+		t.state = state
+	}
+	return from, t.state
+}
+
+// nextCheck 返回距离窗口内最早的结果过期还有多久，状态为 healthy 时返回 false
+// 上传停止后窗口内的失败会逐渐过期，需要在此时重新计算状态
+func (t *healthTracker) nextCheck(now time.Time) (time.Duration, bool) {
+	if t.state == HealthHealthy || len(t.results) == 0 {
+		return 0, false
+	}
+	return max(t.results[0].at.Add(t.window).Sub(now), 0) + time.Millisecond, true
+}
+
+// failureRate 返回窗口内的失败率
+func (t *healthTracker) failureRate() float64 {
+	if len(t.results) == 0 {
+		return 0
+	}
+	return float64(t.failures()) / float64(len(t.results))
+}
+
+// failures 返回窗口内的失败次数
+func (t *healthTracker) failures() int {
+	n := 0
+	for _, r := range t.results {
+		if r.failed {
+			n++
+		}
+	}
+	return n
+}
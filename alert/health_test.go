@@ -0,0 +1,149 @@
+package alert
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hmw/cos-uploader/config"
+)
+
+func TestHealthTrackerTransitions(t *testing.T) {
+	tracker := newHealthTracker(config.HealthConfig{Window: 60, DegradedRate: 0.2, FailingRate: 0.5, MinSamples: 4})
+	now := time.Now()
+
+	// 样本不足时保持 healthy
+	for i := 0; i < 3; i++ {
+		if _, to := tracker.observe(now, true); to != HealthHealthy {
+			t.Fatalf("Expected healthy before min samples, got %s", to)
+		}
+	}
+
+	// 4 次中 3 次失败
+	if from, to := tracker.observe(now, false); from != HealthHealthy || to != HealthFailing {
+		t.Fatalf("Expected healthy -> failing, got %s -> %s", from, to)
+	}
+
+	// 成功逐渐拉低失败率：3/8 -> degraded
+	for i := 0; i < 4; i++ {
+		tracker.observe(now, false)
+	}
+	if tracker.state != HealthDegraded {
+		t.Errorf("Expected degraded, got %s", tracker.state)
+	}
+
+	// 窗口外的失败被丢弃后恢复
+	if _, to := tracker.observe(now.Add(2*time.Minute), false); to != HealthHealthy {
+		t.Errorf("Expected healthy after window, got %s", to)
+	}
+}
+
+func TestHealthTrackerHysteresis(t *testing.T) {
+	tracker := newHealthTracker(config.HealthConfig{Window: 60, DegradedRate: 0.2, FailingRate: 0.5, MinSamples: 10, Hysteresis: 0.1})
+	now := time.Now()
+
+	// 2/10 -> degraded
+	for i := 0; i < 10; i++ {
+		tracker.observe(now, i < 2)
+	}
+	if tracker.state != HealthDegraded {
+		t.Fatalf("Expected degraded, got %s", tracker.state)
+	}
+
+	// 2/11 低于 degraded_rate 但未低于 degraded_rate - hysteresis，保持 degraded
+	if _, to := tracker.observe(now, false); to != HealthDegraded {
+		t.Errorf("Expected degraded within hysteresis, got %s", to)
+	}
+
+	// 2/21 < 0.1 -> healthy
+	for i := 0; i < 10; i++ {
+		tracker.observe(now, false)
+	}
+	if tracker.state != HealthHealthy {
+		t.Errorf("Expected healthy below hysteresis, got %s", tracker.state)
+	}
+}
+
+func TestHealthTrackerEvaluateWithoutUploads(t *testing.T) {
+	tracker := newHealthTracker(config.HealthConfig{Window: 60, DegradedRate: 0.2, FailingRate: 0.5, MinSamples: 2})
+	now := time.Now()
+
+	tracker.observe(now, true)
+	tracker.observe(now.Add(10*time.Second), true)
+	if tracker.state != HealthFailing {
+		t.Fatalf("Expected failing, got %s", tracker.state)
+	}
+
+	delay, ok := tracker.nextCheck(now.Add(10 * time.Second))
+	if !ok || delay < 50*time.Second || delay > 51*time.Second {
+		t.Errorf("Unexpected next check: %s, %v", delay, ok)
+	}
+
+	// 上传停止后失败过期，恢复为 healthy
+	if from, to := tracker.evaluate(now.Add(2 * time.Minute)); from != HealthFailing || to != HealthHealthy {
+		t.Errorf("Expected failing -> healthy, got %s -> %s", from, to)
+	}
+	if _, ok := tracker.nextCheck(now.Add(2 * time.Minute)); ok {
+		t.Error("Healthy tracker should not schedule a check")
+	}
+}
+
+func TestObserveUploadRecoversWithoutUploads(t *testing.T) {
+	a, body := newTemplateAlert(t, config.AlertConfig{
+		Health: config.HealthConfig{Window: 1, DegradedRate: 0.2, FailingRate: 0.5, MinSamples: 1},
+	})
+
+	a.ObserveUpload("p", errors.New("timeout"))
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		a.mu.Lock()
+		state := a.health.state
+		a.mu.Unlock()
+		if state == HealthHealthy {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected recovery after the window, got %s", state)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	a.Flush()
+	if !strings.Contains(string(*body), "COS Uploader Recovered") {
+		t.Errorf("Expected a recovered alert, got %s", *body)
+	}
+}
+
+func TestObserveUploadAlertsOnStateChange(t *testing.T) {
+	a, body := newTemplateAlert(t, config.AlertConfig{
+		Health: config.HealthConfig{Window: 60, DegradedRate: 0.2, FailingRate: 0.5, MinSamples: 2},
+	})
+	var titles []string
+	record := func() {
+		a.Flush()
+		if len(*body) > 0 {
+			titles = append(titles, string(*body))
+			*body = nil
+		}
+	}
+
+	// 连续失败只发送一次报警
+	for i := 0; i < 5; i++ {
+		a.ObserveUpload("p", errors.New("timeout"))
+		record()
+	}
+	if len(titles) != 1 || !strings.Contains(titles[0], "COS Uploader Failing") {
+		t.Fatalf("Expected a single failing alert, got %v", titles)
+	}
+
+	// failing -> degraded 不发送，恢复为 healthy 时发送一次
+	for i := 0; i < 50; i++ {
+		a.ObserveUpload("p", nil)
+		record()
+	}
+	if len(titles) != 2 || !strings.Contains(titles[1], "COS Uploader Recovered") {
+		t.Errorf("Expected a recovered alert, got %v", titles)
+	}
+}
@@ -0,0 +1,138 @@
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/hmw/cos-uploader/config"
+)
+
+// Notifier 报警通知后端
+type Notifier interface {
+	// Name 返回后端名称，用于日志和错误信息
+	Name() string
+	// Send 发送一条报警
+	Send(title, message string) error
+}
+
+// Message 一条报警消息
+type Message struct {
+	Title   string
+	Content string // 消息正文
+	Format  string // 消息格式：text、markdown 或 actioncard，为空时视为 text
+	Link    string // 相关链接，actioncard 消息的按钮跳转地址
+	Text    string // 纯文本正文，Format 不是 text 时发送给不支持格式化消息的后端，为空时使用 Content
+}
+
+// MessageNotifier 支持 Markdown 等格式化消息的通知后端
+// 未实现该接口的后端收到 Message.Text（使用纯文本模板生成的正文）
+type MessageNotifier interface {
+	SendMessage(msg Message) error
+}
+
+// NewNotifier 根据通知目标配置创建通知后端
+func NewNotifier(target config.NotifierConfig) (Notifier, error) {
+	switch target.Type {
+	case "dingtalk":
+		return NewDingTalkNotifier(target.URL, target.Secret), nil
+	case "wecom":
+		return NewWeComNotifier(target.URL), nil
+	case "feishu":
+		return NewFeishuNotifier(target.URL), nil
+	case "slack":
+		return NewSlackNotifier(target.URL), nil
+	case "webhook":
+		return NewWebhookNotifier(target.URL), nil
+	default:
+		return nil, fmt.Errorf("unknown notifier type '%s'", target.Type)
+	}
+}
+
+// newHTTPClient 创建通知后端使用的HTTP客户端
+func newHTTPClient() *http.Client {
+	return &http.Client{
+		Timeout: 10 * time.Second,
+	}
+}
+
+// formatText 将标题和内容格式化为纯文本报警
+func formatText(title, message string) string {
+	return fmt.Sprintf("%s\n\n%s\n\nTime: %s", title, message, time.Now().Format(time.RFC3339))
+}
+
+// postJSON 以JSON格式POST消息，返回响应体
+func postJSON(client *http.Client, url string, payload interface{}) ([]byte, error) {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	resp, err := client.Post(url, "application/json", bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to send alert: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return body, nil
+}
+
+// WebhookMessage 通用 webhook 消息格式
+type WebhookMessage struct {
+	Title   string `json:"title"`
+	Message string `json:"message"`
+	Time    string `json:"time"`
+}
+
+// WebhookNotifier 通用 webhook 通知后端，POST JSON 格式的标题和内容
+// 任何 2xx 状态码视为成功
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookNotifier 创建通用 webhook 通知后端
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{url: url, client: newHTTPClient()}
+}
+
+// Name 返回后端名称
+func (n *WebhookNotifier) Name() string {
+	return "webhook"
+}
+
+// Send 发送报警
+func (n *WebhookNotifier) Send(title, message string) error {
+	jsonData, err := json.Marshal(WebhookMessage{
+		Title:   title,
+		Message: message,
+		Time:    time.Now().Format(time.RFC3339),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	resp, err := n.client.Post(n.url, "application/json", bytes.NewReader(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to send alert: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("webhook returned status %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
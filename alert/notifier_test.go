@@ -0,0 +1,168 @@
+package alert
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hmw/cos-uploader/config"
+	"github.com/hmw/cos-uploader/logger"
+)
+
+// captureServer 创建记录请求体并返回指定响应的模拟服务
+func captureServer(t *testing.T, status int, response string) (*httptest.Server, *[]byte) {
+	t.Helper()
+
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server, &body
+}
+
+func TestDingTalkNotifier(t *testing.T) {
+	server, body := captureServer(t, http.StatusOK, `{"errcode":0,"errmsg":"ok"}`)
+
+	if err := NewDingTalkNotifier(server.URL, "").Send("Title", "Message"); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	var msg DingTalkMessage
+	json.Unmarshal(*body, &msg)
+	if msg.MsgType != "text" || !strings.Contains(msg.Text.Content, "Title\n\nMessage") {
+		t.Errorf("Unexpected DingTalk payload: %s", *body)
+	}
+}
+
+func TestWeComNotifier(t *testing.T) {
+	server, body := captureServer(t, http.StatusOK, `{"errcode":0,"errmsg":"ok"}`)
+
+	if err := NewWeComNotifier(server.URL).Send("Title", "Message"); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	var msg WeComMessage
+	json.Unmarshal(*body, &msg)
+	if msg.MsgType != "text" || !strings.Contains(msg.Text.Content, "Message") {
+		t.Errorf("Unexpected WeCom payload: %s", *body)
+	}
+}
+
+func TestWeComNotifierErrCode(t *testing.T) {
+	server, _ := captureServer(t, http.StatusOK, `{"errcode":93000,"errmsg":"invalid webhook url"}`)
+
+	err := NewWeComNotifier(server.URL).Send("Title", "Message")
+	if err == nil || !strings.Contains(err.Error(), "93000") {
+		t.Errorf("Expected errcode error, got %v", err)
+	}
+}
+
+func TestFeishuNotifier(t *testing.T) {
+	server, body := captureServer(t, http.StatusOK, `{"code":0,"msg":"success"}`)
+
+	if err := NewFeishuNotifier(server.URL).Send("Title", "Message"); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	var msg FeishuMessage
+	json.Unmarshal(*body, &msg)
+	if msg.MsgType != "text" || !strings.Contains(msg.Content.Text, "Message") {
+		t.Errorf("Unexpected Feishu payload: %s", *body)
+	}
+}
+
+func TestFeishuNotifierErrCode(t *testing.T) {
+	server, _ := captureServer(t, http.StatusOK, `{"code":19021,"msg":"sign match fail"}`)
+
+	err := NewFeishuNotifier(server.URL).Send("Title", "Message")
+	if err == nil || !strings.Contains(err.Error(), "19021") {
+		t.Errorf("Expected code error, got %v", err)
+	}
+}
+
+func TestSlackNotifier(t *testing.T) {
+	server, body := captureServer(t, http.StatusOK, "ok")
+
+	if err := NewSlackNotifier(server.URL).Send("Title", "Message"); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	var msg SlackMessage
+	json.Unmarshal(*body, &msg)
+	if !strings.HasPrefix(msg.Text, "*Title*\nMessage") {
+		t.Errorf("Unexpected Slack payload: %s", *body)
+	}
+}
+
+func TestSlackNotifierErrorStatus(t *testing.T) {
+	server, _ := captureServer(t, http.StatusForbidden, "invalid_token")
+
+	if err := NewSlackNotifier(server.URL).Send("Title", "Message"); err == nil {
+		t.Error("Expected error for non-200 status")
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	server, body := captureServer(t, http.StatusAccepted, "")
+
+	if err := NewWebhookNotifier(server.URL).Send("Title", "Message"); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	var msg WebhookMessage
+	json.Unmarshal(*body, &msg)
+	if msg.Title != "Title" || msg.Message != "Message" || msg.Time == "" {
+		t.Errorf("Unexpected webhook payload: %s", *body)
+	}
+}
+
+func TestNewNotifier(t *testing.T) {
+	for _, typ := range []string{"dingtalk", "wecom", "feishu", "slack", "webhook"} {
+		notifier, err := NewNotifier(config.NotifierConfig{Type: typ, URL: "https://example.com"})
+		if err != nil {
+			t.Errorf("NewNotifier(%s) failed: %v", typ, err)
+			continue
+		}
+		if notifier.Name() != typ {
+			t.Errorf("Expected notifier name %s, got %s", typ, notifier.Name())
+		}
+	}
+
+	if _, err := NewNotifier(config.NotifierConfig{Type: "pager", URL: "https://example.com"}); err == nil {
+		t.Error("Expected error for unknown notifier type")
+	}
+}
+
+func TestAlertSendsToAllNotifiers(t *testing.T) {
+	slack, slackBody := captureServer(t, http.StatusOK, "ok")
+	feishu, _ := captureServer(t, http.StatusOK, `{"code":9499,"msg":"bad request"}`)
+
+	log := &logger.Logger{}
+	log.SetWriter(io.Discard, io.Discard)
+
+	a, err := NewAlertFromConfig(config.AlertConfig{
+		Enabled: true,
+		Notifiers: []config.NotifierConfig{
+			{Type: "feishu", URL: feishu.URL},
+			{Type: "slack", URL: slack.URL},
+		},
+	}, log)
+	if err != nil {
+		t.Fatalf("NewAlertFromConfig failed: %v", err)
+	}
+
+	// 飞书失败不影响 Slack，错误中包含失败的后端
+	err = a.SendAlert("Title", "Message")
+	if err == nil || !strings.Contains(err.Error(), "feishu") {
+		t.Errorf("Expected feishu error, got %v", err)
+	}
+	if len(*slackBody) == 0 {
+		t.Error("Slack should still receive the alert")
+	}
+}
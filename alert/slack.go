@@ -0,0 +1,37 @@
+package alert
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SlackMessage Slack incoming webhook 消息格式
+type SlackMessage struct {
+	Text string `json:"text"`
+}
+
+// SlackNotifier Slack incoming webhook 通知后端
+type SlackNotifier struct {
+	webhook string
+	client  *http.Client
+}
+
+// NewSlackNotifier 创建 Slack 通知后端
+func NewSlackNotifier(webhook string) *SlackNotifier {
+	return &SlackNotifier{webhook: webhook, client: newHTTPClient()}
+}
+
+// Name 返回后端名称
+func (n *SlackNotifier) Name() string {
+	return "slack"
+}
+
+// Send 发送消息，标题使用 Slack 的粗体格式
+func (n *SlackNotifier) Send(title, message string) error {
+	msg := SlackMessage{
+		Text: fmt.Sprintf("*%s*\n%s\nTime: %s", title, message, time.Now().Format(time.RFC3339)),
+	}
+	_, err := postJSON(n.client, n.webhook, msg)
+	return err
+}
@@ -0,0 +1,304 @@
+package alert
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"text/template"
+
+	"github.com/hmw/cos-uploader/config"
+)
+
+// UploadFailureData 上传失败报警的模板数据
+type UploadFailureData struct {
+	Project    string // 项目名称
+	FilePath   string // 本地文件路径
+	RemotePath string // 远程COS路径
+	Error      string // 失败原因
+	Time       string // 报警时间
+	ConsoleURL string // 对象在 COS 控制台中的链接，未配置存储桶时为空
+}
+
+// FullUploadReportData 全量上传报告的模板数据
+type FullUploadReportData struct {
+	Project       string
+	TotalFiles    int64
+	UploadedFiles int64
+	SkippedFiles  int64
+	FailedFiles   int64
+	TotalSize     string // 已格式化的总大小
+	UploadedSize  string // 已格式化的上传大小
+	Duration      string
+	Failures      []FileFailureData // 失败的文件列表
+	Time          string
+}
+
+// FileFailureData 全量上传报告中失败的文件
+type FileFailureData struct {
+	FilePath   string
+	RemotePath string
+	Error      string
+	ConsoleURL string
+}
+
+// SummaryReportData 定时汇总报告的模板数据
+type SummaryReportData struct {
+	Project       string
+	Since         string // 统计周期开始时间
+	Period        string // 统计周期时长
+	UploadedFiles int64
+	FailedFiles   int64
+	UploadedSize  string            // 已格式化的上传大小
+	Backlog       int64             // 尚未完成的任务数
+	Slowest       []SlowUploadData  // 耗时最长的上传
+	Failures      []FileFailureData // 失败的文件（最多列出部分）
+	Time          string
+}
+
+// SlowUploadData 汇总报告中耗时较长的上传
+type SlowUploadData struct {
+	FilePath   string
+	RemotePath string
+	Size       string
+	Duration   string
+	ConsoleURL string
+}
+
+// HealthChangeData 健康状态变化报警的模板数据
+type HealthChangeData struct {
+	Project     string
+	From        string // 变化前的状态
+	To          string // 变化后的状态
+	Recovered   bool   // 是否恢复为 healthy
+	FailureRate string // 窗口内的失败率，如 "35%"
+	Failures    int    // 窗口内失败的上传尝试次数
+	Total       int    // 窗口内的上传尝试次数
+	Window      string // 统计窗口
+	Time        string
+}
+
+// WatcherErrorData 文件监听错误报警的模板数据
+type WatcherErrorData struct {
+	Project string
+	Error   string
+	Time    string
+}
+
+// 纯文本格式的内置模板
+const (
+	textUploadFailureTemplate = `Project: {{.Project}}
+File: {{.FilePath}}
+Error: {{.Error}}`
+
+	textFullUploadReportTemplate = `Project: {{.Project}}
+Total Files: {{.TotalFiles}}
+Uploaded: {{.UploadedFiles}}
+Skipped: {{.SkippedFiles}}
+Failed: {{.FailedFiles}}
+Total Size: {{.TotalSize}}
+Upload Size: {{.UploadedSize}}
+Duration: {{.Duration}}{{range .Failures}}
+- {{.FilePath}}: {{.Error}}{{end}}`
+
+	textWatcherErrorTemplate = `Project: {{.Project}}
+Error: {{.Error}}`
+
+	textSummaryReportTemplate = `Project: {{.Project}}
+Since: {{.Since}} ({{.Period}})
+Uploaded: {{.UploadedFiles}} ({{.UploadedSize}})
+Failed: {{.FailedFiles}}
+Backlog: {{.Backlog}}{{if .Slowest}}
+Slowest uploads:{{range .Slowest}}
+- {{.FilePath}}: {{.Duration}} ({{.Size}}){{end}}{{end}}{{if .Failures}}
+Failures:{{range .Failures}}
+- {{.FilePath}}: {{.Error}}{{end}}{{end}}`
+
+	textHealthChangeTemplate = `Project: {{.Project}}
+State: {{.From}} -> {{.To}}
+Failure Rate: {{.FailureRate}} ({{.Failures}}/{{.Total}} in {{.Window}})`
+)
+
+// Markdown 格式的内置模板
+const (
+	markdownUploadFailureTemplate = `### COS Upload Failed
+
+**Project:** {{.Project}}
+
+| File | Remote |
+| --- | --- |
+| {{.FilePath}} | {{if .ConsoleURL}}[{{.RemotePath}}]({{.ConsoleURL}}){{else}}{{.RemotePath}}{{end}} |
+
+**Error:** {{.Error}}
+
+**Time:** {{.Time}}`
+
+	markdownFullUploadReportTemplate = `### COS Full Upload Report
+
+**Project:** {{.Project}}
+
+| Total | Uploaded | Skipped | Failed |
+| --- | --- | --- | --- |
+| {{.TotalFiles}} | {{.UploadedFiles}} | {{.SkippedFiles}} | {{.FailedFiles}} |
+
+**Total Size:** {{.TotalSize}}
+
+**Upload Size:** {{.UploadedSize}}
+
+**Duration:** {{.Duration}}
+{{- if .Failures}}
+
+#### Failed Files
+
+| File | Error |
+| --- | --- |
+{{- range .Failures}}
+| {{if .ConsoleURL}}[{{.FilePath}}]({{.ConsoleURL}}){{else}}{{.FilePath}}{{end}} | {{.Error}} |
+{{- end}}
+{{- end}}
+
+**Time:** {{.Time}}`
+
+	markdownWatcherErrorTemplate = `### COS Uploader Watcher Error
+
+**Project:** {{.Project}}
+
+**Error:** {{.Error}}
+
+**Time:** {{.Time}}`
+
+	markdownSummaryReportTemplate = `### COS Uploader Summary Report
+
+**Project:** {{.Project}}
+
+**Since:** {{.Since}} ({{.Period}})
+
+| Uploaded | Size | Failed | Backlog |
+| --- | --- | --- | --- |
+| {{.UploadedFiles}} | {{.UploadedSize}} | {{.FailedFiles}} | {{.Backlog}} |
+{{- if .Slowest}}
+
+#### Slowest Uploads
+
+| File | Size | Duration |
+| --- | --- | --- |
+{{- range .Slowest}}
+| {{if .ConsoleURL}}[{{.FilePath}}]({{.ConsoleURL}}){{else}}{{.FilePath}}{{end}} | {{.Size}} | {{.Duration}} |
+{{- end}}
+{{- end}}
+{{- if .Failures}}
+
+#### Failed Files
+
+| File | Error |
+| --- | --- |
+{{- range .Failures}}
+| {{if .ConsoleURL}}[{{.FilePath}}]({{.ConsoleURL}}){{else}}{{.FilePath}}{{end}} | {{.Error}} |
+{{- end}}
+{{- end}}
+
+**Time:** {{.Time}}`
+
+	markdownHealthChangeTemplate = `### {{if .Recovered}}COS Uploader Recovered{{else}}COS Uploader {{.To}}{{end}}
+
+**Project:** {{.Project}}
+
+**State:** {{.From}} → {{.To}}
+
+**Failure Rate:** {{.FailureRate}} ({{.Failures}}/{{.Total}} in {{.Window}})
+
+**Time:** {{.Time}}`
+)
+
+// Templates 已解析的报警消息模板
+type Templates struct {
+	markdown         bool
+	uploadFailure    *template.Template
+	fullUploadReport *template.Template
+	watcherError     *template.Template
+	summaryReport    *template.Template
+	healthChange     *template.Template
+}
+
+// ParseTemplates 解析报警消息模板，未配置的模板使用对应格式的内置模板
+// format 为 markdown 或 actioncard 时使用 Markdown 内置模板
+func ParseTemplates(cfg config.AlertTemplates, format string) (*Templates, error) {
+	t := &Templates{markdown: isMarkdownFormat(format)}
+
+	defaults := [5]string{textUploadFailureTemplate, textFullUploadReportTemplate, textWatcherErrorTemplate, textSummaryReportTemplate, textHealthChangeTemplate}
+	if t.markdown {
+		defaults = [5]string{markdownUploadFailureTemplate, markdownFullUploadReportTemplate, markdownWatcherErrorTemplate, markdownSummaryReportTemplate, markdownHealthChangeTemplate}
+	}
+
+	var err error
+	if t.uploadFailure, err = parseTemplate("upload_failure", cfg.UploadFailure, defaults[0]); err != nil {
+		return nil, err
+	}
+	if t.fullUploadReport, err = parseTemplate("full_upload_report", cfg.FullUploadReport, defaults[1]); err != nil {
+		return nil, err
+	}
+	if t.watcherError, err = parseTemplate("watcher_error", cfg.WatcherError, defaults[2]); err != nil {
+		return nil, err
+	}
+	if t.summaryReport, err = parseTemplate("summary_report", cfg.SummaryReport, defaults[3]); err != nil {
+		return nil, err
+	}
+	if t.healthChange, err = parseTemplate("health_change", cfg.HealthChange, defaults[4]); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// defaultTemplates 返回指定格式的内置模板
+func defaultTemplates(format string) *Templates {
+	t, err := ParseTemplates(config.AlertTemplates{}, format)
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in alert template: %v", err))
+	}
+	return t
+}
+
+// parseTemplate 解析单个模板，text 为空时使用内置模板
+func parseTemplate(name, text, fallback string) (*template.Template, error) {
+	if text == "" {
+		text = fallback
+	}
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s template: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// render 执行模板
+func render(tmpl *template.Template, data interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render %s template: %w", tmpl.Name(), err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// isMarkdownFormat 消息格式是否为 Markdown 类型
+func isMarkdownFormat(format string) bool {
+	return format == "markdown" || format == "actioncard"
+}
+
+// ConsoleURL 返回对象所在目录在 COS 控制台中的链接
+func ConsoleURL(bucket, region, remotePath string) string {
+	if bucket == "" || remotePath == "" {
+		return ""
+	}
+
+	dir := path.Dir("/" + strings.TrimPrefix(remotePath, "/"))
+	if !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+
+	query := url.Values{}
+	query.Set("bucket", bucket)
+	query.Set("region", region)
+	query.Set("path", dir)
+	return "https://console.cloud.tencent.com/cos/bucket?" + query.Encode()
+}
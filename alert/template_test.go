@@ -0,0 +1,201 @@
+package alert
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hmw/cos-uploader/config"
+	"github.com/hmw/cos-uploader/logger"
+)
+
+// newTemplateAlert 创建发送到钉钉模拟服务的报警器
+func newTemplateAlert(t *testing.T, cfg config.AlertConfig) (*Alert, *[]byte) {
+	t.Helper()
+
+	server, body := captureServer(t, http.StatusOK, `{"errcode":0,"errmsg":"ok"}`)
+	cfg.DingTalkWebhook = server.URL
+
+	log := &logger.Logger{}
+	log.SetWriter(io.Discard, io.Discard)
+
+	a, err := NewAlertFromConfig(cfg, log)
+	if err != nil {
+		t.Fatalf("NewAlertFromConfig failed: %v", err)
+	}
+	a.SetBucket("bucket-1250000000", "ap-shanghai")
+	return a, body
+}
+
+func TestDefaultTemplates(t *testing.T) {
+	data := UploadFailureData{Project: "p", FilePath: "/data/a.txt", RemotePath: "a.txt", Error: "timeout"}
+
+	for _, format := range []string{"text", "markdown", "actioncard"} {
+		content, err := render(defaultTemplates(format).uploadFailure, data)
+		if err != nil {
+			t.Fatalf("render %s failed: %v", format, err)
+		}
+		if !strings.Contains(content, "/data/a.txt") || !strings.Contains(content, "timeout") {
+			t.Errorf("Unexpected %s content: %q", format, content)
+		}
+	}
+}
+
+func TestParseTemplatesInvalid(t *testing.T) {
+	_, err := ParseTemplates(config.AlertTemplates{WatcherError: "{{.Project"}, "text")
+	if err == nil || !strings.Contains(err.Error(), "watcher_error") {
+		t.Errorf("Expected watcher_error parse error, got %v", err)
+	}
+}
+
+func TestConsoleURL(t *testing.T) {
+	got := ConsoleURL("bucket-1250000000", "ap-shanghai", "uploads/logs/a.txt")
+	want := "https://console.cloud.tencent.com/cos/bucket?bucket=bucket-1250000000&path=%2Fuploads%2Flogs%2F&region=ap-shanghai"
+	if got != want {
+		t.Errorf("ConsoleURL = %s, want %s", got, want)
+	}
+
+	if got := ConsoleURL("", "ap-shanghai", "a.txt"); got != "" {
+		t.Errorf("Expected empty URL without bucket, got %s", got)
+	}
+}
+
+func TestMarkdownUploadFailure(t *testing.T) {
+	a, body := newTemplateAlert(t, config.AlertConfig{Format: "markdown", RateLimit: -1})
+
+	a.NotifyUploadFailure("p", "/data/a.txt", "uploads/a.txt", errors.New("timeout"))
+	a.Flush()
+
+	var msg DingTalkMessage
+	json.Unmarshal(*body, &msg)
+	if msg.MsgType != "markdown" || msg.Markdown.Title != "COS Upload Failed" {
+		t.Fatalf("Unexpected DingTalk payload: %s", *body)
+	}
+	if !strings.Contains(msg.Markdown.Text, "**Project:** p") ||
+		!strings.Contains(msg.Markdown.Text, "[uploads/a.txt](https://console.cloud.tencent.com/cos/bucket?") {
+		t.Errorf("Unexpected markdown text: %s", msg.Markdown.Text)
+	}
+	if strings.Contains(string(*body), `"text":{`) {
+		t.Errorf("Markdown message should not carry text content: %s", *body)
+	}
+}
+
+func TestMarkdownPlainTextNotifier(t *testing.T) {
+	slack, slackBody := captureServer(t, http.StatusOK, "ok")
+	a, body := newTemplateAlert(t, config.AlertConfig{
+		Format:    "markdown",
+		RateLimit: -1,
+		Notifiers: []config.NotifierConfig{{Type: "slack", URL: slack.URL}},
+	})
+
+	a.NotifyUploadFailure("p", "/data/a.txt", "uploads/a.txt", errors.New("timeout"))
+	a.Flush()
+
+	// 钉钉收到 Markdown，Slack 收到纯文本模板生成的内容
+	if !strings.Contains(string(*body), "**Project:** p") {
+		t.Errorf("Unexpected DingTalk payload: %s", *body)
+	}
+	text := string(*slackBody)
+	if !strings.Contains(text, "/data/a.txt") || strings.Contains(text, "###") || strings.Contains(text, "**") {
+		t.Errorf("Slack should receive plain text, got %s", text)
+	}
+}
+
+func TestActionCardUploadFailure(t *testing.T) {
+	a, body := newTemplateAlert(t, config.AlertConfig{Format: "actioncard", RateLimit: -1})
+
+	a.NotifyUploadFailure("p", "/data/a.txt", "uploads/a.txt", errors.New("timeout"))
+	a.Flush()
+
+	var msg DingTalkMessage
+	json.Unmarshal(*body, &msg)
+	if msg.MsgType != "actionCard" || !strings.HasPrefix(msg.ActionCard.SingleURL, "https://console.cloud.tencent.com/") {
+		t.Errorf("Unexpected DingTalk payload: %s", *body)
+	}
+}
+
+func TestCustomTemplate(t *testing.T) {
+	a, body := newTemplateAlert(t, config.AlertConfig{
+		RateLimit: -1,
+		Templates: config.AlertTemplates{WatcherError: "watcher of {{.Project}} failed: {{.Error}}"},
+	})
+
+	a.NotifyWatcherError("p", errors.New("too many open files"))
+	a.Flush()
+
+	var msg DingTalkMessage
+	json.Unmarshal(*body, &msg)
+	if !strings.Contains(msg.Text.Content, "watcher of p failed: too many open files") {
+		t.Errorf("Unexpected text content: %s", msg.Text.Content)
+	}
+}
+
+func TestTemplateRenderFallback(t *testing.T) {
+	a, body := newTemplateAlert(t, config.AlertConfig{
+		RateLimit: -1,
+		Templates: config.AlertTemplates{WatcherError: "{{.Missing}}"},
+	})
+
+	a.NotifyWatcherError("p", errors.New("boom"))
+	a.Flush()
+
+	var msg DingTalkMessage
+	json.Unmarshal(*body, &msg)
+	if !strings.Contains(msg.Text.Content, "Project: p\nError: boom") {
+		t.Errorf("Expected built-in template after render failure, got %q", msg.Text.Content)
+	}
+}
+
+func TestSendFullUploadReport(t *testing.T) {
+	a, body := newTemplateAlert(t, config.AlertConfig{Format: "markdown"})
+
+	err := a.SendFullUploadReport(FullUploadReportData{
+		Project:       "p",
+		TotalFiles:    3,
+		UploadedFiles: 1,
+		SkippedFiles:  1,
+		FailedFiles:   1,
+		Failures:      []FileFailureData{{FilePath: "/data/b.txt", RemotePath: "b.txt", Error: "denied"}},
+	})
+	if err != nil {
+		t.Fatalf("SendFullUploadReport failed: %v", err)
+	}
+
+	var msg DingTalkMessage
+	json.Unmarshal(*body, &msg)
+	if msg.Markdown.Title != "COS Full Upload Report: 1 failed" {
+		t.Errorf("Unexpected title: %s", msg.Markdown.Title)
+	}
+	if !strings.Contains(msg.Markdown.Text, "| 3 | 1 | 1 | 1 |") ||
+		!strings.Contains(msg.Markdown.Text, "[/data/b.txt](https://console.cloud.tencent.com/") {
+		t.Errorf("Unexpected report text: %s", msg.Markdown.Text)
+	}
+}
+
+func TestSendSummaryReport(t *testing.T) {
+	a, body := newTemplateAlert(t, config.AlertConfig{})
+
+	err := a.SendSummaryReport(SummaryReportData{
+		Project:       "p",
+		Since:         "2026-01-01T09:00:00Z",
+		Period:        "24h0m0s",
+		UploadedFiles: 12,
+		UploadedSize:  "3.00 MB",
+		Backlog:       2,
+		Slowest:       []SlowUploadData{{FilePath: "/data/big.bin", Size: "2.00 MB", Duration: "8s"}},
+	})
+	if err != nil {
+		t.Fatalf("SendSummaryReport failed: %v", err)
+	}
+
+	var msg DingTalkMessage
+	json.Unmarshal(*body, &msg)
+	for _, want := range []string{"Uploaded: 12 (3.00 MB)", "Backlog: 2", "- /data/big.bin: 8s (2.00 MB)"} {
+		if !strings.Contains(msg.Text.Content, want) {
+			t.Errorf("Summary report missing %q: %s", want, msg.Text.Content)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// WeComMessage 企业微信群机器人消息格式
+type WeComMessage struct {
+	MsgType string      `json:"msgtype"`
+	Text    TextContent `json:"text"`
+}
+
+// WeComResponse 企业微信接口响应
+type WeComResponse struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+// WeComNotifier 企业微信群机器人通知后端
+type WeComNotifier struct {
+	webhook string
+	client  *http.Client
+}
+
+// NewWeComNotifier 创建企业微信群机器人通知后端
+func NewWeComNotifier(webhook string) *WeComNotifier {
+	return &WeComNotifier{webhook: webhook, client: newHTTPClient()}
+}
+
+// Name 返回后端名称
+func (n *WeComNotifier) Name() string {
+	return "wecom"
+}
+
+// Send 发送文本消息
+// 企业微信拒绝消息时仍返回 HTTP 200，需要检查 errcode
+func (n *WeComNotifier) Send(title, message string) error {
+	msg := WeComMessage{
+		MsgType: "text",
+		Text: TextContent{
+			Content: formatText(title, message),
+		},
+	}
+	body, err := postJSON(n.client, n.webhook, msg)
+	if err != nil {
+		return err
+	}
+
+	var resp WeComResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	if resp.ErrCode != 0 {
+		return fmt.Errorf("WeCom API returned errcode %d: %s", resp.ErrCode, resp.ErrMsg)
+	}
+	return nil
+}
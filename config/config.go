@@ -3,7 +3,12 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/robfig/cron/v3"
 	"gopkg.in/yaml.v3"
 )
 
@@ -17,9 +22,25 @@ type Config struct {
 type ProjectConfig struct {
 	Name        string            `yaml:"name"`
 	Directories []string          `yaml:"directories"` // 监控的本地目录
+	RootIDs     map[string]string `yaml:"root_ids"`    // 监控目录的根标识（目录 -> 标识），索引按 根标识/相对路径 记录文件，未配置的目录使用目录名，目录名重复时加上上级目录名
 	COSConfig   COSConfig         `yaml:"cos"`
 	Watcher     WatcherConfig     `yaml:"watcher"`
 	Alert       AlertConfig       `yaml:"alert"`
+
+	Include []string `yaml:"include"` // 需要上传的文件（doublestar glob，相对监控目录），为空时包含所有文件
+	Exclude []string `yaml:"exclude"` // 不上传的文件和目录，未配置时为 ["**/.*", "**/*.tmp"]，配置为 [] 表示不排除
+
+	Symlinks string `yaml:"symlinks"` // 符号链接处理方式：follow、skip 或 preserve，默认: follow
+
+	Scan ScanConfig `yaml:"scan"` // 全量扫描配置
+}
+
+// ScanConfig 全量扫描配置
+type ScanConfig struct {
+	HashWorkers  int `yaml:"hash_workers"`   // 并行计算哈希的协程数，默认: 4
+	HashBufferMB int `yaml:"hash_buffer_mb"` // 每个协程的读取缓冲区（MB），缓冲区共占用 hash_workers × hash_buffer_mb，默认: 8
+
+	HashAlgorithm string `yaml:"hash_algorithm"` // 索引使用的哈希算法：md5、sha256、crc64ecma 或 xxhash，默认: md5
 }
 
 // COSConfig COS云存储配置
@@ -29,18 +50,70 @@ type COSConfig struct {
 	Region     string `yaml:"region"`      // 默认: ap-shanghai
 	Bucket     string `yaml:"bucket"`      // bucket名称
 	PathPrefix string `yaml:"path_prefix"` // 上传路径前缀
+
+	// 分块上传配置
+	MultipartThresholdMB int `yaml:"multipart_threshold_mb"` // 超过该大小（MB）的文件使用分块上传，默认: 64
+	PartSizeMB           int `yaml:"part_size_mb"`           // 分块大小（MB），默认: 16
+	PartConcurrency      int `yaml:"part_concurrency"`       // 单个文件的分块并发数，默认: 4
+	PartTimeout          int `yaml:"part_timeout"`           // 单个分块上传超时（秒），默认: 120
 }
 
 // WatcherConfig 文件监听配置
 type WatcherConfig struct {
 	Events   []string `yaml:"events"`     // 监听的事件类型: create, write, remove, rename, chmod
 	PoolSize int      `yaml:"pool_size"` // 上传工作池大小
+
+	DebounceMs    int `yaml:"debounce_ms"`    // 事件合并窗口（毫秒），同一文件在窗口内的多个事件只上传一次，默认: 500，-1 表示不合并
+	StableSeconds int `yaml:"stable_seconds"` // 文件大小和修改时间保持不变该秒数后才上传，默认: 5，-1 表示不检查
+
+	Mode         string `yaml:"mode"`          // 监听方式：fsnotify 或 poll（定期扫描，用于 NFS/SMB/FUSE 等不支持 inotify 的挂载），默认: fsnotify
+	PollInterval int    `yaml:"poll_interval"` // poll 方式的扫描间隔（秒），默认: 10
+
+	DeleteRemote bool `yaml:"delete_remote"` // 本地文件删除时同时删除 COS 对象和远程索引条目，默认: false
+	DeleteLimit  int  `yaml:"delete_limit"`  // 每分钟最多删除的 COS 对象数，超出的删除被跳过并报警，默认: 100，-1 表示不限制
 }
 
 // AlertConfig 报警配置
 type AlertConfig struct {
 	DingTalkWebhook string `yaml:"dingtalk_webhook"` // 钉钉webhook URL
+	DingTalkSecret  string `yaml:"dingtalk_secret"`  // 钉钉机器人加签密钥（可选）
 	Enabled         bool   `yaml:"enabled"`          // 是否启用报警
+	RateLimit       int    `yaml:"rate_limit"`       // 报警限流窗口（秒），窗口内的报警合并为一条汇总，默认: 300，-1 表示不限流
+
+	Notifiers []NotifierConfig `yaml:"notifiers"` // 其他通知目标
+
+	Format    string         `yaml:"format"`    // 消息格式：text、markdown 或 actioncard，默认: text
+	Templates AlertTemplates `yaml:"templates"` // 各类报警的消息模板，为空时使用内置模板
+
+	ReportSchedule string `yaml:"report_schedule"` // 汇总报告的发送时间（cron 表达式），如 "0 9 * * *" 表示每天 9 点，为空时不发送
+
+	Health HealthConfig `yaml:"health"` // 项目健康状态判定
+}
+
+// HealthConfig 项目健康状态配置
+// 根据时间窗口内上传尝试的失败率判定状态：healthy → degraded → failing
+type HealthConfig struct {
+	Window       int     `yaml:"window"`        // 统计窗口（秒），默认: 300
+	DegradedRate float64 `yaml:"degraded_rate"` // 失败率达到该值时进入 degraded，默认: 0.2
+	FailingRate  float64 `yaml:"failing_rate"`  // 失败率达到该值时进入 failing，默认: 0.5
+	MinSamples   int     `yaml:"min_samples"`   // 窗口内至少有该数量的上传尝试才判定状态，默认: 5
+	Hysteresis   float64 `yaml:"hysteresis"`    // 状态变好时失败率需低于进入阈值减去该值，避免在阈值附近反复报警，默认: 0.1（不超过 degraded_rate 的一半），小于 0 表示不使用
+}
+
+// AlertTemplates 报警消息模板，使用 Go text/template 语法
+type AlertTemplates struct {
+	UploadFailure    string `yaml:"upload_failure"`     // 上传失败
+	FullUploadReport string `yaml:"full_upload_report"` // 全量上传报告
+	WatcherError     string `yaml:"watcher_error"`      // 文件监听错误
+	SummaryReport    string `yaml:"summary_report"`     // 定时汇总报告
+	HealthChange     string `yaml:"health_change"`      // 健康状态变化
+}
+
+// NotifierConfig 通知目标配置
+type NotifierConfig struct {
+	Type   string `yaml:"type"`   // 通知类型: dingtalk, wecom, feishu, slack, webhook
+	URL    string `yaml:"url"`    // webhook URL
+	Secret string `yaml:"secret"` // 加签密钥（仅 dingtalk）
 }
 
 // LoadConfig 从YAML文件加载配置
@@ -77,6 +150,9 @@ func (c *Config) Validate() error {
 		if len(proj.Directories) == 0 {
 			return fmt.Errorf("project '%s' has no directories", proj.Name)
 		}
+		if err := proj.validateRootIDs(); err != nil {
+			return err
+		}
 		if proj.COSConfig.Bucket == "" {
 			return fmt.Errorf("project '%s' missing COS bucket", proj.Name)
 		}
@@ -84,17 +160,214 @@ func (c *Config) Validate() error {
 			return fmt.Errorf("project '%s' missing COS credentials", proj.Name)
 		}
 
+		for j, notifier := range proj.Alert.Notifiers {
+			switch notifier.Type {
+			case "dingtalk", "wecom", "feishu", "slack", "webhook":
+			default:
+				return fmt.Errorf("project '%s' notifier %d has unknown type '%s'", proj.Name, j, notifier.Type)
+			}
+			if notifier.URL == "" {
+				return fmt.Errorf("project '%s' notifier %d missing url", proj.Name, j)
+			}
+		}
+
+		switch proj.Alert.Format {
+		case "", "text", "markdown", "actioncard":
+		default:
+			return fmt.Errorf("project '%s' has unknown alert format '%s'", proj.Name, proj.Alert.Format)
+		}
+		switch proj.Symlinks {
+		case "", "follow", "skip", "preserve":
+		default:
+			return fmt.Errorf("project '%s' has unknown symlinks policy '%s'", proj.Name, proj.Symlinks)
+		}
+		switch proj.Scan.HashAlgorithm {
+		case "", "md5", "sha256", "crc64ecma", "xxhash":
+		default:
+			return fmt.Errorf("project '%s' has unknown hash algorithm '%s'", proj.Name, proj.Scan.HashAlgorithm)
+		}
+		switch proj.Watcher.Mode {
+		case "", "fsnotify", "poll":
+		default:
+			return fmt.Errorf("project '%s' has unknown watcher mode '%s'", proj.Name, proj.Watcher.Mode)
+		}
+		if proj.Alert.ReportSchedule != "" {
+			if _, err := cron.ParseStandard(proj.Alert.ReportSchedule); err != nil {
+				return fmt.Errorf("project '%s' has invalid report schedule: %w", proj.Name, err)
+			}
+		}
+
+		for _, pattern := range append(append([]string(nil), proj.Include...), proj.Exclude...) {
+			if !doublestar.ValidatePattern(pattern) {
+				return fmt.Errorf("project '%s' has invalid glob pattern '%s'", proj.Name, pattern)
+			}
+		}
+
 		// 设置默认值
 		if proj.COSConfig.Region == "" {
 			proj.COSConfig.Region = "ap-shanghai"
 		}
+		if proj.COSConfig.MultipartThresholdMB == 0 {
+			proj.COSConfig.MultipartThresholdMB = 64
+		}
+		if proj.COSConfig.PartSizeMB == 0 {
+			proj.COSConfig.PartSizeMB = 16
+		}
+		if proj.COSConfig.PartConcurrency == 0 {
+			proj.COSConfig.PartConcurrency = 4
+		}
+		if proj.COSConfig.PartTimeout == 0 {
+			proj.COSConfig.PartTimeout = 120
+		}
 		if proj.Watcher.PoolSize == 0 {
 			proj.Watcher.PoolSize = 5
 		}
+		if proj.Watcher.DebounceMs == 0 {
+			proj.Watcher.DebounceMs = 500
+		}
+		if proj.Watcher.StableSeconds == 0 {
+			proj.Watcher.StableSeconds = 5
+		}
+		if proj.Symlinks == "" {
+			proj.Symlinks = "follow"
+		}
+		if proj.Watcher.Mode == "" {
+			proj.Watcher.Mode = "fsnotify"
+		}
+		if proj.Watcher.PollInterval <= 0 {
+			proj.Watcher.PollInterval = 10
+		}
+		if proj.Watcher.DeleteLimit == 0 {
+			proj.Watcher.DeleteLimit = 100
+		}
+		if proj.Scan.HashWorkers <= 0 {
+			proj.Scan.HashWorkers = 4
+		}
+		if proj.Scan.HashBufferMB <= 0 {
+			proj.Scan.HashBufferMB = 8
+		}
+		if proj.Scan.HashAlgorithm == "" {
+			proj.Scan.HashAlgorithm = "md5"
+		}
+		if proj.Alert.RateLimit == 0 {
+			proj.Alert.RateLimit = 300
+		}
+		if proj.Alert.Format == "" {
+			proj.Alert.Format = "text"
+		}
+		if proj.Alert.Health.Window == 0 {
+			proj.Alert.Health.Window = 300
+		}
+		if proj.Alert.Health.DegradedRate == 0 {
+			proj.Alert.Health.DegradedRate = 0.2
+		}
+		if proj.Alert.Health.FailingRate == 0 {
+			proj.Alert.Health.FailingRate = 0.5
+		}
+		if proj.Alert.Health.MinSamples == 0 {
+			proj.Alert.Health.MinSamples = 5
+		}
+		if proj.Alert.Health.Hysteresis == 0 {
+			proj.Alert.Health.Hysteresis = min(0.1, proj.Alert.Health.DegradedRate/2)
+		}
+		if proj.Alert.Health.DegradedRate > proj.Alert.Health.FailingRate || proj.Alert.Health.FailingRate > 1 {
+			return fmt.Errorf("project '%s' health rates must satisfy degraded_rate <= failing_rate <= 1", proj.Name)
+		}
+		if proj.Alert.Health.Hysteresis >= proj.Alert.Health.DegradedRate {
+			return fmt.Errorf("project '%s' health hysteresis must be less than degraded_rate", proj.Name)
+		}
 		if len(proj.Watcher.Events) == 0 {
 			proj.Watcher.Events = []string{"create", "write"}
 		}
+		if proj.Watcher.DeleteRemote && !slices.Contains(proj.Watcher.Events, "remove") {
+			proj.Watcher.Events = append(proj.Watcher.Events, "remove")
+		}
+	}
+
+	return nil
+}
+
+// RootID 返回监控目录的根标识，未在 root_ids 中配置时使用目录名
+// 目录名重复时 Validate 已在 root_ids 中为其生成带上级目录名的标识
+func (p *ProjectConfig) RootID(dir string) string {
+	if id, ok := p.RootIDs[dir]; ok {
+		return id
+	}
+	return filepath.Base(filepath.Clean(dir))
+}
+
+// validateRootIDs 检查每个监控目录的根标识非空、不含路径分隔符且互不相同
+// 未配置的目录名重复时先生成不重复的根标识
+func (p *ProjectConfig) validateRootIDs() error {
+	for dir := range p.RootIDs {
+		if !slices.Contains(p.Directories, dir) {
+			return fmt.Errorf("project '%s' root_ids references unknown directory '%s'", p.Name, dir)
+		}
 	}
+	p.deriveRootIDs()
 
+	seen := make(map[string]string)
+	for _, dir := range p.Directories {
+		id := p.RootID(dir)
+		if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+			return fmt.Errorf("project '%s' directory '%s' has invalid root id '%s', set it in root_ids", p.Name, dir, id)
+		}
+		if other, ok := seen[id]; ok {
+			return fmt.Errorf("project '%s' directories '%s' and '%s' have the same root id '%s', set root_ids", p.Name, other, dir, id)
+		}
+		seen[id] = dir
+	}
 	return nil
 }
+
+// deriveRootIDs 为未在 root_ids 中配置且目录名与其他目录重复的目录生成根标识
+// 依次加上上级目录名，如 /a/data 和 /b/data 分别为 a-data 和 b-data，直到互不相同；
+// 生成的标识写入 RootIDs，目录名不重复的目录仍使用目录名
+func (p *ProjectConfig) deriveRootIDs() {
+	var dirs []string
+	var parts [][]string
+	for _, dir := range p.Directories {
+		if _, ok := p.RootIDs[dir]; ok {
+			continue
+		}
+		dirs = append(dirs, dir)
+		parts = append(parts, strings.FieldsFunc(filepath.Clean(dir), func(r rune) bool {
+			return r == '/' || r == filepath.Separator
+		}))
+	}
+
+	depth := make([]int, len(dirs))
+	ids := make([]string, len(dirs))
+	for i := range dirs {
+		depth[i] = 1
+	}
+	for changed := true; changed; {
+		count := make(map[string]int)
+		for _, id := range p.RootIDs {
+			count[id]++
+		}
+		for i := range dirs {
+			n := min(depth[i], len(parts[i]))
+			ids[i] = strings.Join(parts[i][len(parts[i])-n:], "-")
+			count[ids[i]]++
+		}
+
+		changed = false
+		for i := range dirs {
+			if count[ids[i]] > 1 && depth[i] < len(parts[i]) {
+				depth[i]++
+				changed = true
+			}
+		}
+	}
+
+	for i, dir := range dirs {
+		if depth[i] == 1 {
+			continue
+		}
+		if p.RootIDs == nil {
+			p.RootIDs = make(map[string]string)
+		}
+		p.RootIDs[dir] = ids[i]
+	}
+}
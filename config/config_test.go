@@ -57,6 +57,14 @@ func TestLoadConfig(t *testing.T) {
 	if proj.Watcher.PoolSize != 5 {
 		t.Errorf("Expected pool size 5, got %d", proj.Watcher.PoolSize)
 	}
+
+	if proj.Scan.HashWorkers != 4 || proj.Scan.HashBufferMB != 8 {
+		t.Errorf("Expected default scan settings 4 workers and 8 MB, got %d and %d", proj.Scan.HashWorkers, proj.Scan.HashBufferMB)
+	}
+
+	if proj.Scan.HashAlgorithm != "md5" {
+		t.Errorf("Expected default hash algorithm 'md5', got '%s'", proj.Scan.HashAlgorithm)
+	}
 }
 
 func TestValidateConfig(t *testing.T) {
@@ -134,6 +142,271 @@ func TestValidateConfig(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "unknown notifier type",
+			config: &Config{
+				Projects: []ProjectConfig{
+					{
+						Name:        "test",
+						Directories: []string{"/tmp"},
+						COSConfig: COSConfig{
+							SecretID:  "id",
+							SecretKey: "key",
+							Bucket:    "bucket",
+						},
+						Alert: AlertConfig{
+							Notifiers: []NotifierConfig{{Type: "pager", URL: "https://example.com"}},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "notifier missing url",
+			config: &Config{
+				Projects: []ProjectConfig{
+					{
+						Name:        "test",
+						Directories: []string{"/tmp"},
+						COSConfig: COSConfig{
+							SecretID:  "id",
+							SecretKey: "key",
+							Bucket:    "bucket",
+						},
+						Alert: AlertConfig{
+							Notifiers: []NotifierConfig{{Type: "slack"}},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown alert format",
+			config: &Config{
+				Projects: []ProjectConfig{
+					{
+						Name:        "test",
+						Directories: []string{"/tmp"},
+						COSConfig: COSConfig{
+							SecretID:  "id",
+							SecretKey: "key",
+							Bucket:    "bucket",
+						},
+						Alert: AlertConfig{
+							Format: "html",
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown symlinks policy",
+			config: &Config{
+				Projects: []ProjectConfig{
+					{
+						Name:        "test",
+						Directories: []string{"/tmp"},
+						COSConfig: COSConfig{
+							SecretID:  "id",
+							SecretKey: "key",
+							Bucket:    "bucket",
+						},
+						Symlinks: "copy",
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown hash algorithm",
+			config: &Config{
+				Projects: []ProjectConfig{
+					{
+						Name:        "test",
+						Directories: []string{"/tmp"},
+						COSConfig: COSConfig{
+							SecretID:  "id",
+							SecretKey: "key",
+							Bucket:    "bucket",
+						},
+						Scan: ScanConfig{HashAlgorithm: "sha1"},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate directory names",
+			config: &Config{
+				Projects: []ProjectConfig{
+					{
+						Name:        "test",
+						Directories: []string{"/a/data", "/b/data"},
+						COSConfig: COSConfig{
+							SecretID:  "id",
+							SecretKey: "key",
+							Bucket:    "bucket",
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "duplicate root id",
+			config: &Config{
+				Projects: []ProjectConfig{
+					{
+						Name:        "test",
+						Directories: []string{"/a/data", "/b/data"},
+						RootIDs:     map[string]string{"/a/data": "data", "/b/data": "data"},
+						COSConfig: COSConfig{
+							SecretID:  "id",
+							SecretKey: "key",
+							Bucket:    "bucket",
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "root ids resolve duplicate names",
+			config: &Config{
+				Projects: []ProjectConfig{
+					{
+						Name:        "test",
+						Directories: []string{"/a/data", "/b/data"},
+						RootIDs:     map[string]string{"/b/data": "data-b"},
+						COSConfig: COSConfig{
+							SecretID:  "id",
+							SecretKey: "key",
+							Bucket:    "bucket",
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "root id for unknown directory",
+			config: &Config{
+				Projects: []ProjectConfig{
+					{
+						Name:        "test",
+						Directories: []string{"/tmp"},
+						RootIDs:     map[string]string{"/var": "var"},
+						COSConfig: COSConfig{
+							SecretID:  "id",
+							SecretKey: "key",
+							Bucket:    "bucket",
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown watcher mode",
+			config: &Config{
+				Projects: []ProjectConfig{
+					{
+						Name:        "test",
+						Directories: []string{"/tmp"},
+						COSConfig: COSConfig{
+							SecretID:  "id",
+							SecretKey: "key",
+							Bucket:    "bucket",
+						},
+						Watcher: WatcherConfig{
+							Mode: "inotify",
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid report schedule",
+			config: &Config{
+				Projects: []ProjectConfig{
+					{
+						Name:        "test",
+						Directories: []string{"/tmp"},
+						COSConfig: COSConfig{
+							SecretID:  "id",
+							SecretKey: "key",
+							Bucket:    "bucket",
+						},
+						Alert: AlertConfig{
+							ReportSchedule: "every morning",
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "degraded rate above failing rate",
+			config: &Config{
+				Projects: []ProjectConfig{
+					{
+						Name:        "test",
+						Directories: []string{"/tmp"},
+						COSConfig: COSConfig{
+							SecretID:  "id",
+							SecretKey: "key",
+							Bucket:    "bucket",
+						},
+						Alert: AlertConfig{
+							Health: HealthConfig{DegradedRate: 0.8, FailingRate: 0.5},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "health hysteresis above degraded rate",
+			config: &Config{
+				Projects: []ProjectConfig{
+					{
+						Name:        "test",
+						Directories: []string{"/tmp"},
+						COSConfig: COSConfig{
+							SecretID:  "id",
+							SecretKey: "key",
+							Bucket:    "bucket",
+						},
+						Alert: AlertConfig{
+							Health: HealthConfig{DegradedRate: 0.2, FailingRate: 0.5, Hysteresis: 0.3},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid exclude pattern",
+			config: &Config{
+				Projects: []ProjectConfig{
+					{
+						Name:        "test",
+						Directories: []string{"/tmp"},
+						COSConfig: COSConfig{
+							SecretID:  "id",
+							SecretKey: "key",
+							Bucket:    "bucket",
+						},
+						Exclude: []string{"[a-"},
+					},
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "missing name",
 			config: &Config{
@@ -162,6 +435,71 @@ func TestValidateConfig(t *testing.T) {
 	}
 }
 
+func TestValidateDeleteRemote(t *testing.T) {
+	cfg := &Config{
+		Projects: []ProjectConfig{
+			{
+				Name:        "test",
+				Directories: []string{"/tmp"},
+				COSConfig: COSConfig{
+					SecretID:  "id",
+					SecretKey: "key",
+					Bucket:    "bucket",
+				},
+				Watcher: WatcherConfig{
+					DeleteRemote: true,
+				},
+			},
+		},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	watcher := cfg.Projects[0].Watcher
+	if len(watcher.Events) != 3 || watcher.Events[2] != "remove" {
+		t.Errorf("Expected remove event to be watched, got %v", watcher.Events)
+	}
+	if watcher.DeleteLimit != 100 {
+		t.Errorf("Expected default delete limit 100, got %d", watcher.DeleteLimit)
+	}
+}
+
+func TestValidateDerivesRootIDs(t *testing.T) {
+	cfg := &Config{
+		Projects: []ProjectConfig{
+			{
+				Name:        "test",
+				Directories: []string{"/mnt/a/photos", "/mnt/b/photos", "/srv/b/photos", "/data/docs", "/data/other"},
+				RootIDs:     map[string]string{"/data/other": "docs"},
+				COSConfig: COSConfig{
+					SecretID:  "id",
+					SecretKey: "key",
+					Bucket:    "bucket",
+				},
+			},
+		},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	// 目录名重复时依次加上上级目录名，与 root_ids 中的标识重复时同样处理
+	proj := &cfg.Projects[0]
+	want := map[string]string{
+		"/mnt/a/photos": "a-photos",
+		"/mnt/b/photos": "mnt-b-photos",
+		"/srv/b/photos": "srv-b-photos",
+		"/data/docs":    "data-docs",
+		"/data/other":   "docs",
+	}
+	for dir, id := range want {
+		if got := proj.RootID(dir); got != id {
+			t.Errorf("RootID(%s) = %q, want %q", dir, got, id)
+		}
+	}
+}
+
 func TestLoadConfigFileNotFound(t *testing.T) {
 	cfg, err := LoadConfig("/nonexistent/path/config.yaml")
 	if err == nil {
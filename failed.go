@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hmw/cos-uploader/config"
+	uploaderModule "github.com/hmw/cos-uploader/uploader"
+)
+
+// failedUsage failed 子命令用法
+const failedUsage = `Usage:
+  cos-uploader [-config config.yaml] failed list [project]
+  cos-uploader [-config config.yaml] failed retry <project> [id...]
+  cos-uploader [-config config.yaml] failed purge <project> [id...]`
+
+// runFailedCommand 执行 failed 子命令，返回进程退出码
+// list 列出失败任务；retry 重新上传失败任务；purge 删除失败任务。
+// retry 和 purge 不指定ID时作用于项目的全部失败任务
+func runFailedCommand(args []string, cfg *config.Config, uploaderSvc *uploaderModule.Uploader) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, failedUsage)
+		return 2
+	}
+
+	action := args[0]
+	args = args[1:]
+
+	switch action {
+	case "list":
+		projects := make([]string, 0, len(cfg.Projects))
+		if len(args) > 0 {
+			projects = append(projects, args[0])
+		} else {
+			for _, proj := range cfg.Projects {
+				projects = append(projects, proj.Name)
+			}
+		}
+		return listFailed(uploaderSvc, projects)
+
+	case "retry", "purge":
+		if len(args) == 0 {
+			fmt.Fprintln(os.Stderr, failedUsage)
+			return 2
+		}
+		if action == "retry" {
+			return retryFailed(uploaderSvc, args[0], args[1:])
+		}
+		return purgeFailed(uploaderSvc, args[0], args[1:])
+
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown failed action: %s\n%s\n", action, failedUsage)
+		return 2
+	}
+}
+
+// listFailed 打印项目的失败任务
+func listFailed(uploaderSvc *uploaderModule.Uploader, projects []string) int {
+	total := 0
+	for _, project := range projects {
+		store, err := uploaderSvc.DeadLetters(project)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		entries, err := store.List()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read failed tasks for project %s: %v\n", project, err)
+			return 1
+		}
+
+		for _, entry := range entries {
+			fmt.Println("=" + strings.Repeat("=", 78) + "=")
+			fmt.Printf("ID:            %s\n", entry.ID)
+			fmt.Printf("Project:       %s\n", entry.ProjectName)
+			fmt.Printf("Operation:     %s\n", failedOp(entry))
+			fmt.Printf("File:          %s\n", entry.FilePath)
+			fmt.Printf("Remote:        %s\n", entry.RemotePath)
+			fmt.Printf("Attempts:      %d\n", entry.Attempts)
+			fmt.Printf("First Failed:  %s\n", entry.FirstFailedAt)
+			fmt.Printf("Last Failed:   %s\n", entry.LastFailedAt)
+			fmt.Printf("Error:         %s\n", entry.Error)
+		}
+		total += len(entries)
+	}
+
+	if total > 0 {
+		fmt.Println("=" + strings.Repeat("=", 78) + "=")
+	}
+	fmt.Printf("%d failed task(s)\n", total)
+	return 0
+}
+
+// failedOp 返回失败任务的操作类型，旧版本记录的任务没有操作类型，均为上传
+func failedOp(entry *uploaderModule.FailedTask) string {
+	if entry.Op == "" {
+		return "upload"
+	}
+	return entry.Op
+}
+
+// retryFailed 重新上传项目的失败任务
+func retryFailed(uploaderSvc *uploaderModule.Uploader, project string, ids []string) int {
+	succeeded, failed, err := uploaderSvc.RetryFailed(project, ids)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Printf("Retried: %d succeeded, %d failed\n", succeeded, failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// purgeFailed 删除项目的失败任务
+func purgeFailed(uploaderSvc *uploaderModule.Uploader, project string, ids []string) int {
+	store, err := uploaderSvc.DeadLetters(project)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	removed, err := store.Remove(ids...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to purge failed tasks: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("Purged %d failed task(s)\n", removed)
+	return 0
+}
@@ -0,0 +1,84 @@
+package filter
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// Filter 根据 include/exclude glob 判断文件是否需要上传
+// 模式使用 doublestar 语法，匹配相对于监控目录的路径（以 / 分隔）。
+// 扫描器、文件监听和其他同步路径都应使用同一个 Filter，保证上传范围一致
+type Filter struct {
+	include []string
+	exclude []string
+}
+
+// DefaultExclude 未配置 exclude 时排除的文件：隐藏文件（目录）和临时文件
+var DefaultExclude = []string{"**/.*", "**/*.tmp"}
+
+// New 创建过滤器，include 为空时包含所有文件，exclude 为 nil 时使用 DefaultExclude
+func New(include, exclude []string) (*Filter, error) {
+	if exclude == nil {
+		exclude = DefaultExclude
+	}
+
+	for _, pattern := range append(append([]string(nil), include...), exclude...) {
+		if !doublestar.ValidatePattern(pattern) {
+			return nil, fmt.Errorf("invalid glob pattern '%s'", pattern)
+		}
+	}
+	return &Filter{include: include, exclude: exclude}, nil
+}
+
+// Match 判断相对路径的文件是否需要上传：未被 exclude 排除，且 include 为空或匹配 include
+// nil 过滤器匹配所有文件
+func (f *Filter) Match(relPath string) bool {
+	if f == nil {
+		return true
+	}
+
+	relPath = filepath.ToSlash(relPath)
+	if matchAny(f.exclude, relPath) {
+		return false
+	}
+	return len(f.include) == 0 || matchAny(f.include, relPath)
+}
+
+// SkipDir 判断相对路径的目录是否被 exclude 排除，排除的目录不再遍历和监听
+// include 只作用于文件，不会剪枝目录
+func (f *Filter) SkipDir(relPath string) bool {
+	if f == nil {
+		return false
+	}
+
+	relPath = filepath.ToSlash(relPath)
+	if relPath == "." || relPath == "" {
+		return false
+	}
+	return matchAny(f.exclude, relPath)
+}
+
+// Rel 返回 path 相对于包含它的根目录的路径，不在任何根目录下时返回 false
+func Rel(roots []string, path string) (string, bool) {
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		return rel, true
+	}
+	return "", false
+}
+
+// matchAny 判断路径是否匹配任一模式
+func matchAny(patterns []string, relPath string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := doublestar.Match(pattern, relPath); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package filter
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestFilterMatch(t *testing.T) {
+	f, err := New([]string{"**/*.jpg", "**/*.mp4"}, []string{"cache/**", "**/*.part.mp4"})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"a.jpg", true},
+		{"2026/10/b.mp4", true},
+		{"notes.txt", false},
+		{"cache/c.jpg", false},
+		{"2026/d.part.mp4", false},
+	}
+	for _, tt := range tests {
+		if got := f.Match(tt.path); got != tt.want {
+			t.Errorf("Match(%s) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	if !f.SkipDir("cache") || f.SkipDir("2026") || f.SkipDir(".") {
+		t.Error("Unexpected SkipDir result")
+	}
+}
+
+func TestFilterDefaultExclude(t *testing.T) {
+	f, err := New(nil, nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if f.Match(".hidden") || f.Match("a/b.tmp") || !f.Match("a/b.txt") {
+		t.Error("Default exclude should skip hidden and temporary files")
+	}
+	if !f.SkipDir("a/.git") {
+		t.Error("Default exclude should prune hidden directories")
+	}
+
+	// 显式配置为空列表时不排除
+	f, _ = New(nil, []string{})
+	if !f.Match(".hidden") {
+		t.Error("Empty exclude should match all files")
+	}
+}
+
+func TestFilterInvalidPattern(t *testing.T) {
+	if _, err := New([]string{"[a-"}, nil); err == nil {
+		t.Error("Expected error for invalid pattern")
+	}
+}
+
+func TestNilFilter(t *testing.T) {
+	var f *Filter
+	if !f.Match(".hidden") || f.SkipDir("cache") {
+		t.Error("Nil filter should match everything")
+	}
+}
+
+func TestRel(t *testing.T) {
+	roots := []string{filepath.FromSlash("/data/a"), filepath.FromSlash("/data/b")}
+
+	rel, ok := Rel(roots, filepath.FromSlash("/data/b/x/y.txt"))
+	if !ok || filepath.ToSlash(rel) != "x/y.txt" {
+		t.Errorf("Rel = %s, %v", rel, ok)
+	}
+	if _, ok := Rel(roots, filepath.FromSlash("/data/ab/y.txt")); ok {
+		t.Error("Path outside roots should not match")
+	}
+}
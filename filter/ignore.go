@@ -0,0 +1,206 @@
+package filter
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// IgnoreFileName 忽略规则文件名
+const IgnoreFileName = ".cosignore"
+
+// ignoreCheckInterval 重新检查 .cosignore 是否修改的最小间隔
+const ignoreCheckInterval = time.Second
+
+// ignoreRule 一条 gitignore 语法的规则
+type ignoreRule struct {
+	pattern string // doublestar 模式，相对于 .cosignore 所在目录
+	negate  bool   // ! 开头，重新包含之前忽略的路径
+	dirOnly bool   // / 结尾，只匹配目录
+}
+
+// ignoreFile 目录中已解析的 .cosignore 文件
+type ignoreFile struct {
+	exists    bool // 目录中是否有 .cosignore
+	rules     []ignoreRule
+	modTime   time.Time
+	size      int64
+	checkedAt time.Time
+}
+
+// Ignore 按监控目录中的 .cosignore 文件判断路径是否被忽略
+// 规则使用 gitignore 语法：支持 # 注释、! 取反、/ 结尾只匹配目录、
+// 包含 / 的模式相对于 .cosignore 所在目录，否则匹配任意层级。
+// 子目录中的 .cosignore 覆盖上级目录的规则，目录被忽略时其中的文件都被忽略。
+// .cosignore 修改后自动重新读取，无需重启
+type Ignore struct {
+	roots []string
+	mu    sync.Mutex
+	files map[string]*ignoreFile // 目录 -> 规则
+}
+
+// NewIgnore 创建读取 roots 下 .cosignore 文件的忽略规则
+func NewIgnore(roots []string) *Ignore {
+	return &Ignore{
+		roots: roots,
+		files: make(map[string]*ignoreFile),
+	}
+}
+
+// Ignored 判断路径是否被忽略，不在监控目录下的路径不会被忽略
+// nil 忽略规则不忽略任何路径
+func (ig *Ignore) Ignored(path string, isDir bool) bool {
+	if ig == nil {
+		return false
+	}
+
+	root, rel, ok := ig.locate(path)
+	if !ok || rel == "." {
+		return false
+	}
+
+	// 任一上级目录被忽略时，路径也被忽略
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for i := 1; i < len(parts); i++ {
+		if ig.match(root, parts[:i], true) {
+			return true
+		}
+	}
+	return ig.match(root, parts, isDir)
+}
+
+// locate 返回包含 path 的监控目录和相对路径
+func (ig *Ignore) locate(path string) (string, string, bool) {
+	for _, root := range ig.roots {
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		return root, rel, true
+	}
+	return "", "", false
+}
+
+// match 按从监控目录到上级目录的顺序应用 .cosignore 规则，最后匹配的规则生效
+func (ig *Ignore) match(root string, parts []string, isDir bool) bool {
+	ignored := false
+	dir := root
+	for i := 0; i < len(parts); i++ {
+		if i > 0 {
+			dir = filepath.Join(dir, parts[i-1])
+		}
+		file := ig.load(dir)
+		if file == nil {
+			continue
+		}
+
+		rel := strings.Join(parts[i:], "/")
+		for _, rule := range file.rules {
+			if rule.dirOnly && !isDir {
+				continue
+			}
+			if ok, _ := doublestar.Match(rule.pattern, rel); ok {
+				ignored = !rule.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// load 返回目录中已解析的 .cosignore，目录中没有 .cosignore 时返回 nil
+// 每个目录最多每 ignoreCheckInterval 检查一次文件是否修改
+func (ig *Ignore) load(dir string) *ignoreFile {
+	ig.mu.Lock()
+	defer ig.mu.Unlock()
+
+	now := time.Now()
+	cached, ok := ig.files[dir]
+	if !ok || now.Sub(cached.checkedAt) >= ignoreCheckInterval {
+		cached = ig.refresh(dir, cached, now)
+		ig.files[dir] = cached
+	}
+
+	if !cached.exists {
+		return nil
+	}
+	return cached
+}
+
+// refresh 重新检查目录中的 .cosignore，未修改时沿用已解析的规则（调用者需持有锁）
+func (ig *Ignore) refresh(dir string, cached *ignoreFile, now time.Time) *ignoreFile {
+	path := filepath.Join(dir, IgnoreFileName)
+	info, err := os.Stat(path)
+	if err != nil {
+		return &ignoreFile{checkedAt: now}
+	}
+	if cached != nil && cached.exists && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		cached.checkedAt = now
+		return cached
+	}
+
+	rules, err := parseIgnoreFile(path)
+	if err != nil {
+		return &ignoreFile{checkedAt: now}
+	}
+	return &ignoreFile{exists: true, rules: rules, modTime: info.ModTime(), size: info.Size(), checkedAt: now}
+}
+
+// parseIgnoreFile 解析 .cosignore 文件
+func parseIgnoreFile(path string) ([]ignoreRule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreLine(scanner.Text()); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, scanner.Err()
+}
+
+// parseIgnoreLine 将一行 gitignore 规则转换为 doublestar 模式
+func parseIgnoreLine(line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	var rule ignoreRule
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	// 包含 / 的模式相对于 .cosignore 所在目录，否则匹配任意层级
+	if strings.Contains(line, "/") {
+		line = strings.TrimPrefix(line, "/")
+	} else {
+		line = "**/" + line
+	}
+
+	if !doublestar.ValidatePattern(line) {
+		return ignoreRule{}, false
+	}
+	rule.pattern = line
+	return rule, true
+}
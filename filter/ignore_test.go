@@ -0,0 +1,85 @@
+package filter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeIgnore 在目录中写入 .cosignore
+func writeIgnore(t *testing.T, dir, content string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, IgnoreFileName), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", IgnoreFileName, err)
+	}
+}
+
+func TestIgnored(t *testing.T) {
+	root := t.TempDir()
+	writeIgnore(t, root, "# comment\n*.log\n!keep.log\nbuild/\n/top.txt\ndocs/*.md\n\\#hash\n")
+	writeIgnore(t, filepath.Join(root, "sub"), "!*.log\nlocal.txt\n")
+
+	ig := NewIgnore([]string{root})
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"a.log", false, true},
+		{"deep/dir/a.log", false, true},
+		{"keep.log", false, false},
+		{"a.txt", false, false},
+		{"build", true, true},
+		{"build/out.bin", false, true},
+		{"build", false, false}, // build/ 只匹配目录
+		{"top.txt", false, true},
+		{"deep/top.txt", false, false},
+		{"docs/readme.md", false, true},
+		{"docs/api/readme.md", false, false},
+		{"#hash", false, true},
+		{"sub/a.log", false, false},
+		{"sub/local.txt", false, true},
+		{"local.txt", false, false},
+		{".", true, false},
+	}
+	for _, tt := range tests {
+		if got := ig.Ignored(filepath.Join(root, tt.path), tt.isDir); got != tt.want {
+			t.Errorf("Ignored(%s, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+
+	if ig.Ignored(filepath.Join(t.TempDir(), "a.log"), false) {
+		t.Error("Paths outside the roots should not be ignored")
+	}
+
+	var nilIgnore *Ignore
+	if nilIgnore.Ignored(filepath.Join(root, "a.log"), false) {
+		t.Error("Nil Ignore should not ignore any path")
+	}
+}
+
+func TestIgnoredReload(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "a.log")
+
+	ig := NewIgnore([]string{root})
+	if ig.Ignored(path, false) {
+		t.Fatal("a.log should not be ignored without .cosignore")
+	}
+
+	writeIgnore(t, root, "*.log\n")
+	time.Sleep(ignoreCheckInterval + 100*time.Millisecond)
+	if !ig.Ignored(path, false) {
+		t.Fatal("a.log should be ignored after .cosignore is created")
+	}
+
+	writeIgnore(t, root, "*.tmp\n!*.log\n")
+	time.Sleep(ignoreCheckInterval + 100*time.Millisecond)
+	if ig.Ignored(path, false) {
+		t.Error("a.log should not be ignored after .cosignore is edited")
+	}
+}
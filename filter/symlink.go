@@ -0,0 +1,123 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// SymlinkPolicy 符号链接的处理方式
+type SymlinkPolicy string
+
+const (
+	SymlinkFollow   SymlinkPolicy = "follow"   // 跟随链接：文件上传目标内容，目录递归遍历
+	SymlinkSkip     SymlinkPolicy = "skip"     // 忽略所有符号链接
+	SymlinkPreserve SymlinkPolicy = "preserve" // 保留链接本身：上传为空对象，链接目标保存在元数据中
+)
+
+// ErrSymlinkLoop 跟随符号链接时发现链接指向自身所在的上级目录
+var ErrSymlinkLoop = errors.New("symlink loop detected")
+
+// ParseSymlinkPolicy 解析配置中的符号链接处理方式，空字符串表示 follow
+func ParseSymlinkPolicy(s string) (SymlinkPolicy, error) {
+	switch SymlinkPolicy(s) {
+	case "", SymlinkFollow:
+		return SymlinkFollow, nil
+	case SymlinkSkip, SymlinkPreserve:
+		return SymlinkPolicy(s), nil
+	default:
+		return "", fmt.Errorf("unknown symlink policy '%s'", s)
+	}
+}
+
+// IsSymlink 判断路径本身是否为符号链接
+func IsSymlink(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.Mode()&os.ModeSymlink != 0
+}
+
+// Walk 按符号链接处理方式递归遍历目录，回调参数与 filepath.Walk 相同
+// follow：链接按目标的信息回调，指向目录时在链接路径下继续遍历，遇到链接循环时以错误回调且不再深入；
+// skip：链接不回调；preserve：链接以自身的信息回调，不深入。
+// root 本身是链接时总是跟随
+func Walk(root string, policy SymlinkPolicy, fn filepath.WalkFunc) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walk(root, info, policy, make(map[string]bool), fn)
+	}
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+// walk 遍历 path，ancestors 记录当前路径上各级目录的真实路径，用于发现链接循环
+func walk(path string, info fs.FileInfo, policy SymlinkPolicy, ancestors map[string]bool, fn filepath.WalkFunc) error {
+	if info.Mode()&os.ModeSymlink != 0 {
+		switch policy {
+		case SymlinkSkip:
+			return nil
+		case SymlinkPreserve:
+			return fn(path, info, nil)
+		}
+		target, err := os.Stat(path)
+		if err != nil {
+			return fn(path, info, err)
+		}
+		info = target
+	}
+
+	if !info.IsDir() {
+		return fn(path, info, nil)
+	}
+
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return skipDirErr(fn(path, info, err))
+	}
+	if ancestors[real] {
+		return skipDirErr(fn(path, info, fmt.Errorf("%w: %s -> %s", ErrSymlinkLoop, path, real)))
+	}
+
+	if err := fn(path, info, nil); err != nil {
+		return skipDirErr(err)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return skipDirErr(fn(path, info, err))
+	}
+
+	ancestors[real] = true
+	defer delete(ancestors, real)
+
+	for _, entry := range entries {
+		child := filepath.Join(path, entry.Name())
+		childInfo, err := entry.Info()
+		if err != nil {
+			err = fn(child, nil, err)
+		} else {
+			err = walk(child, childInfo, policy, ancestors, fn)
+		}
+		if err == filepath.SkipDir {
+			// 文件回调返回 SkipDir 时跳过所在目录的其余条目
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skipDirErr 目录回调返回 SkipDir 时只跳过该目录
+func skipDirErr(err error) error {
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
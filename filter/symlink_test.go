@@ -0,0 +1,97 @@
+package filter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// walkPaths 遍历目录，返回相对路径列表和链接循环错误的数量
+func walkPaths(t *testing.T, root string, policy SymlinkPolicy) ([]string, int) {
+	t.Helper()
+	var paths []string
+	loops := 0
+	err := Walk(root, policy, func(path string, info os.FileInfo, err error) error {
+		if errors.Is(err, ErrSymlinkLoop) {
+			loops++
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		if info.Mode()&os.ModeSymlink != 0 {
+			rel += "@"
+		} else if info.IsDir() {
+			rel += "/"
+		}
+		paths = append(paths, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	slices.Sort(paths)
+	return paths, loops
+}
+
+func TestParseSymlinkPolicy(t *testing.T) {
+	for _, s := range []string{"", "follow", "skip", "preserve"} {
+		if _, err := ParseSymlinkPolicy(s); err != nil {
+			t.Errorf("ParseSymlinkPolicy(%q) failed: %v", s, err)
+		}
+	}
+	if _, err := ParseSymlinkPolicy("copy"); err == nil {
+		t.Error("Expected error for unknown policy")
+	}
+}
+
+func TestWalkSymlinks(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "dir"), 0755)
+	os.WriteFile(filepath.Join(root, "dir", "a.txt"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(outside, "b.txt"), []byte("b"), 0644)
+	if err := os.Symlink(outside, filepath.Join(root, "linked")); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+	os.Symlink(filepath.Join(root, "dir", "a.txt"), filepath.Join(root, "alias.txt"))
+	// 指向上级目录的链接
+	os.Symlink(root, filepath.Join(root, "dir", "loop"))
+
+	tests := []struct {
+		policy SymlinkPolicy
+		want   []string
+		loops  int
+	}{
+		{SymlinkFollow, []string{"./", "alias.txt", "dir/", "dir/a.txt", "linked/", "linked/b.txt"}, 1},
+		{SymlinkSkip, []string{"./", "dir/", "dir/a.txt"}, 0},
+		{SymlinkPreserve, []string{"./", "alias.txt@", "dir/", "dir/a.txt", "dir/loop@", "linked@"}, 0},
+	}
+	for _, tt := range tests {
+		paths, loops := walkPaths(t, root, tt.policy)
+		if !slices.Equal(paths, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.policy, paths, tt.want)
+		}
+		if loops != tt.loops {
+			t.Errorf("%s: got %d loop errors, want %d", tt.policy, loops, tt.loops)
+		}
+	}
+}
+
+func TestWalkSymlinkRoot(t *testing.T) {
+	target := t.TempDir()
+	os.WriteFile(filepath.Join(target, "a.txt"), []byte("a"), 0644)
+	root := filepath.Join(t.TempDir(), "root")
+	if err := os.Symlink(target, root); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+
+	// 监控目录本身是链接时总是跟随
+	paths, _ := walkPaths(t, root, SymlinkSkip)
+	if len(paths) != 2 || paths[1] != "a.txt" {
+		t.Errorf("Expected root symlink to be followed, got %v", paths)
+	}
+}
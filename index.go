@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hmw/cos-uploader/config"
+	uploaderModule "github.com/hmw/cos-uploader/uploader"
+)
+
+// indexUsage index 子命令用法
+const indexUsage = `Usage:
+  cos-uploader [-config config.yaml] index export <project> <file>
+  cos-uploader [-config config.yaml] index import <project> <file>`
+
+// runIndexCommand 执行 index 子命令，返回进程退出码
+// export 将项目的本地索引导出为 JSON 文件；import 用 JSON 文件替换项目的本地索引。
+// 以本地绝对路径为键的旧版本索引按项目配置的监控目录转换
+func runIndexCommand(args []string, cfg *config.Config) int {
+	if len(args) != 3 {
+		fmt.Fprintln(os.Stderr, indexUsage)
+		return 2
+	}
+
+	action, project, file := args[0], args[1], args[2]
+	var roots *uploaderModule.IndexRoots
+	for _, proj := range cfg.Projects {
+		if proj.Name == project {
+			roots = uploaderModule.NewIndexRoots(proj)
+		}
+	}
+	if roots == nil {
+		fmt.Fprintf(os.Stderr, "Project '%s' not found\n", project)
+		return 1
+	}
+
+	switch action {
+	case "export":
+		store, err := uploaderModule.OpenLocalIndexStore(project, roots, false)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if store == nil {
+			fmt.Fprintf(os.Stderr, "No local index for project %s\n", project)
+			return 1
+		}
+		defer store.Close()
+
+		if err := store.ExportFile(file); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("Exported local index of project %s to %s\n", project, file)
+		return 0
+
+	case "import":
+		store, err := uploaderModule.OpenLocalIndexStore(project, roots, true)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer store.Close()
+
+		if err := store.ImportFile(file); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("Imported %s into local index of project %s\n", file, project)
+		return 0
+
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown index action: %s\n%s\n", action, indexUsage)
+		return 2
+	}
+}
@@ -9,9 +9,11 @@ import (
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/hmw/cos-uploader/alert"
 	"github.com/hmw/cos-uploader/config"
+	"github.com/hmw/cos-uploader/filter"
 	"github.com/hmw/cos-uploader/logger"
 	uploaderModule "github.com/hmw/cos-uploader/uploader"
 	"github.com/hmw/cos-uploader/watcher"
@@ -21,6 +23,7 @@ func main() {
 	configPath := flag.String("config", "config.yaml", "Path to config file")
 	version := flag.Bool("version", false, "Show version information")
 	fullUpload := flag.String("full-upload", "", "Execute full upload for specified project")
+	rehash := flag.Bool("rehash", false, "Recompute hashes of all files during full upload instead of reusing unchanged ones")
 	flag.Parse()
 
 	// 如果指定了 --version，输出版本后退出
@@ -55,10 +58,33 @@ func main() {
 		os.Exit(1)
 	}
 
+	// 创建报警器，上传最终失败时通过项目的报警器通知
+	alerts := make(map[string]*alert.Alert)
+	for _, proj := range cfg.Projects {
+		if !proj.Alert.Enabled {
+			continue
+		}
+		a, err := alert.NewAlertFromConfig(proj.Alert, log)
+		if err != nil {
+			log.Error("Failed to create alert", "project", proj.Name, "error", err)
+			os.Exit(1)
+		}
+		if !a.HasNotifiers() {
+			continue
+		}
+		a.SetBucket(proj.COSConfig.Bucket, proj.COSConfig.Region)
+		alerts[proj.Name] = a
+		uploaderSvc.SetNotifier(proj.Name, a)
+	}
+
 	// 如果指定了全量上传，执行后退出
 	if *fullUpload != "" {
-		log.Info("Executing full upload", "project", *fullUpload)
-		stats, err := uploaderSvc.ExecuteFullUpload(*fullUpload)
+		log.Info("Executing full upload", "project", *fullUpload, "rehash", *rehash)
+		stats, err := uploaderSvc.ExecuteFullUpload(*fullUpload, *rehash)
+		if err == nil {
+			sendFullUploadReport(alerts[*fullUpload], stats, log)
+		}
+		flushAlerts(alerts)
 		if err != nil {
 			log.Error("Full upload failed", "project", *fullUpload, "error", err)
 			os.Exit(1)
@@ -82,12 +108,32 @@ func main() {
 		os.Exit(0)
 	}
 
-	// 创建报警器
-	alerts := make(map[string]*alert.Alert)
-	for _, proj := range cfg.Projects {
-		if proj.Alert.Enabled && proj.Alert.DingTalkWebhook != "" {
-			alerts[proj.Name] = alert.NewAlert(proj.Alert.DingTalkWebhook, log)
+	// failed 子命令：查看、重试或清除最终失败的上传任务
+	// index 子命令：导入或导出本地索引
+	if args := flag.Args(); len(args) > 0 {
+		var code int
+		switch args[0] {
+		case "failed":
+			code = runFailedCommand(args[1:], cfg, uploaderSvc)
+		case "index":
+			code = runIndexCommand(args[1:], cfg)
+		default:
+			log.Error("Unknown command", "command", args[0])
+			os.Exit(2)
 		}
+		flushAlerts(alerts)
+		os.Exit(code)
+	}
+
+	// 打开任务日志，恢复上次未完成的上传任务
+	if err := uploaderSvc.OpenJournal(uploaderModule.GetQueueJournalPath()); err != nil {
+		log.Error("Failed to open task journal", "error", err)
+		os.Exit(1)
+	}
+
+	// 在后台将停机期间新增或修改的文件加入上传队列
+	for _, proj := range cfg.Projects {
+		uploaderSvc.CatchUp(proj.Name)
 	}
 
 	// 启动文件监听和上传
@@ -96,12 +142,43 @@ func main() {
 
 	for _, proj := range cfg.Projects {
 		// 为每个项目创建文件监听器
-		w, err := watcher.NewWatcher(proj.Directories, proj.Watcher.Events, log)
+		var w *watcher.Watcher
+		var err error
+		if proj.Watcher.Mode == "poll" {
+			w, err = watcher.NewPollingWatcher(proj.Directories, proj.Watcher.Events, time.Duration(proj.Watcher.PollInterval)*time.Second, log)
+		} else {
+			w, err = watcher.NewWatcher(proj.Directories, proj.Watcher.Events, log)
+		}
 		if err != nil {
 			log.Error("Failed to create watcher", "project", proj.Name, "error", err)
+			if a, ok := alerts[proj.Name]; ok {
+				a.NotifyWatcherError(proj.Name, err)
+			}
 			continue
 		}
 
+		fileFilter, err := filter.New(proj.Include, proj.Exclude)
+		if err != nil {
+			log.Error("Invalid include/exclude patterns", "project", proj.Name, "error", err)
+			os.Exit(1)
+		}
+		w.SetFilter(fileFilter)
+		w.SetSymlinkPolicy(filter.SymlinkPolicy(proj.Symlinks))
+
+		if proj.Watcher.DebounceMs > 0 {
+			w.SetDebounce(time.Duration(proj.Watcher.DebounceMs) * time.Millisecond)
+		}
+		if proj.Watcher.StableSeconds > 0 {
+			w.SetStabilityWait(time.Duration(proj.Watcher.StableSeconds) * time.Second)
+		}
+
+		if a, ok := alerts[proj.Name]; ok {
+			projectName := proj.Name
+			w.SetErrorHandler(func(err error) {
+				a.NotifyWatcherError(projectName, err)
+			})
+		}
+
 		watchers = append(watchers, w)
 
 		// 启动监听
@@ -112,14 +189,17 @@ func main() {
 			defer watcherGroup.Done()
 
 			for event := range w.Events() {
-				// 检查文件是否存在
+				// 计算远程路径
+				remotePath := calculateRemotePath(event.FilePath, proj)
+
+				// 检查文件是否存在，启用 delete_remote 时删除已删除文件的远程对象
 				if _, err := os.Stat(event.FilePath); os.IsNotExist(err) {
+					if proj.Watcher.DeleteRemote && event.Type == "remove" {
+						deleteRemote(uploaderSvc, alerts[proj.Name], proj.Name, event.FilePath, remotePath, log)
+					}
 					continue
 				}
 
-				// 计算远程路径
-				remotePath := calculateRemotePath(event.FilePath, proj)
-
 				// 创建上传任务
 				task := &uploaderModule.UploadTask{
 					FilePath:    event.FilePath,
@@ -127,8 +207,13 @@ func main() {
 					ProjectName: proj.Name,
 					Retry:       0,
 				}
+				// 改名或移动的文件内容未变时在 COS 服务端复制
+				if event.OldPath != "" {
+					task.OldPath = event.OldPath
+					task.OldRemotePath = calculateRemotePath(event.OldPath, proj)
+				}
 
-				log.Info("Adding upload task", "project", proj.Name, "file", event.FilePath, "remote", remotePath)
+				log.Info("Adding upload task", "project", proj.Name, "file", event.FilePath, "remote", remotePath, "events", event.Count)
 				uploaderSvc.AddTask(task)
 			}
 		}(proj, w)
@@ -137,6 +222,9 @@ func main() {
 	// 启动上传器
 	uploaderSvc.Start()
 
+	// 定时发送汇总报告
+	reportScheduler := startReportScheduler(cfg.Projects, alerts, uploaderSvc, log)
+
 	// 优雅关闭
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -146,6 +234,11 @@ func main() {
 
 	log.Info("Shutting down...")
 
+	// 停止汇总报告，等待正在发送的报告完成
+	if reportScheduler != nil {
+		<-reportScheduler.Stop().Done()
+	}
+
 	// 关闭所有监听器
 	for _, w := range watchers {
 		w.Close()
@@ -157,9 +250,65 @@ func main() {
 	// 关闭上传器
 	uploaderSvc.Stop()
 
+	// 发送尚未发送的汇总报警
+	flushAlerts(alerts)
+
 	log.Info("COS uploader stopped")
 }
 
+// flushAlerts 发送所有报警器中尚未发送的汇总报警
+func flushAlerts(alerts map[string]*alert.Alert) {
+	for _, a := range alerts {
+		a.Flush()
+	}
+}
+
+// sendFullUploadReport 通过项目的报警器发送全量上传报告，项目未启用报警时不发送
+func sendFullUploadReport(a *alert.Alert, stats *uploaderModule.FullUploadStats, log *logger.Logger) {
+	if a == nil {
+		return
+	}
+
+	data := alert.FullUploadReportData{
+		Project:       stats.ProjectName,
+		TotalFiles:    stats.TotalFiles,
+		UploadedFiles: stats.UploadedFiles,
+		SkippedFiles:  stats.SkippedFiles,
+		FailedFiles:   stats.FailedFiles,
+		TotalSize:     uploaderModule.FormatBytes(stats.TotalSize),
+		UploadedSize:  uploaderModule.FormatBytes(stats.UploadedSize),
+		Duration:      stats.Duration.String(),
+	}
+	for _, failure := range stats.Failures {
+		data.Failures = append(data.Failures, alert.FileFailureData{
+			FilePath:   failure.FilePath,
+			RemotePath: failure.RemotePath,
+			Error:      failure.Error,
+		})
+	}
+
+	if err := a.SendFullUploadReport(data); err != nil {
+		log.Error("Failed to send full upload report", "project", stats.ProjectName, "error", err)
+	}
+}
+
+// deleteRemote 添加删除远程对象的任务，超出删除上限时记录并报警
+func deleteRemote(uploaderSvc *uploaderModule.Uploader, a *alert.Alert, projectName, filePath, remotePath string, log *logger.Logger) {
+	task := &uploaderModule.UploadTask{
+		FilePath:    filePath,
+		RemotePath:  remotePath,
+		ProjectName: projectName,
+	}
+	if err := uploaderSvc.AddDeleteTask(task); err != nil {
+		log.Warn("Remote delete skipped", "project", projectName, "file", filePath, "remote", remotePath, "error", err)
+		if a != nil {
+			a.NotifyWatcherError(projectName, err)
+		}
+		return
+	}
+	log.Info("Adding delete task", "project", projectName, "file", filePath, "remote", remotePath)
+}
+
 // calculateRemotePath 计算远程COS路径
 func calculateRemotePath(localPath string, proj config.ProjectConfig) string {
 	// 获取相对于监控目录的相对路径
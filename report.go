@@ -0,0 +1,80 @@
+package main
+
+import (
+	"time"
+
+	"github.com/hmw/cos-uploader/alert"
+	"github.com/hmw/cos-uploader/config"
+	"github.com/hmw/cos-uploader/logger"
+	uploaderModule "github.com/hmw/cos-uploader/uploader"
+	"github.com/robfig/cron/v3"
+)
+
+// startReportScheduler 按项目的 report_schedule 定时发送汇总报告
+// 没有项目配置汇总报告时返回 nil
+func startReportScheduler(projects []config.ProjectConfig, alerts map[string]*alert.Alert, uploaderSvc *uploaderModule.Uploader, log *logger.Logger) *cron.Cron {
+	var scheduler *cron.Cron
+
+	for _, proj := range projects {
+		a, ok := alerts[proj.Name]
+		if !ok || proj.Alert.ReportSchedule == "" {
+			continue
+		}
+
+		if scheduler == nil {
+			scheduler = cron.New()
+		}
+		projectName := proj.Name
+		_, err := scheduler.AddFunc(proj.Alert.ReportSchedule, func() {
+			sendSummaryReport(a, uploaderSvc, projectName, log)
+		})
+		if err != nil {
+			log.Error("Failed to schedule summary report", "project", projectName, "error", err)
+			continue
+		}
+		log.Info("Summary report scheduled", "project", projectName, "schedule", proj.Alert.ReportSchedule)
+	}
+
+	if scheduler != nil {
+		scheduler.Start()
+	}
+	return scheduler
+}
+
+// sendSummaryReport 发送项目自上次报告以来的上传汇总
+func sendSummaryReport(a *alert.Alert, uploaderSvc *uploaderModule.Uploader, projectName string, log *logger.Logger) {
+	stats, err := uploaderSvc.TakeReportStats(projectName)
+	if err != nil {
+		log.Error("Failed to collect summary report", "project", projectName, "error", err)
+		return
+	}
+
+	data := alert.SummaryReportData{
+		Project:       stats.ProjectName,
+		Since:         stats.Since.Format(time.RFC3339),
+		Period:        stats.Duration.Round(time.Second).String(),
+		UploadedFiles: stats.UploadedFiles,
+		FailedFiles:   stats.FailedFiles,
+		UploadedSize:  uploaderModule.FormatBytes(stats.UploadedSize),
+		Backlog:       stats.Backlog,
+	}
+	for _, timing := range stats.Slowest {
+		data.Slowest = append(data.Slowest, alert.SlowUploadData{
+			FilePath:   timing.FilePath,
+			RemotePath: timing.RemotePath,
+			Size:       uploaderModule.FormatBytes(timing.Size),
+			Duration:   timing.Duration.Round(time.Millisecond).String(),
+		})
+	}
+	for _, failure := range stats.Failures {
+		data.Failures = append(data.Failures, alert.FileFailureData{
+			FilePath:   failure.FilePath,
+			RemotePath: failure.RemotePath,
+			Error:      failure.Error,
+		})
+	}
+
+	if err := a.SendSummaryReport(data); err != nil {
+		log.Error("Failed to send summary report", "project", projectName, "error", err)
+	}
+}
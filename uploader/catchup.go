@@ -0,0 +1,156 @@
+package uploader
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// localIndexState 守护进程运行期间维护的本地索引
+// 启动追赶扫描对比完本地索引后，上传成功和删除远程对象的文件立即更新到本地索引数据库，
+// 使下次启动时不会把运行期间已经上传的文件当作停机期间的修改。
+// 数据库只在读写时打开，不妨碍同时执行 --full-upload 等命令
+type localIndexState struct {
+	mu    sync.Mutex
+	ready bool // 追赶扫描对比完本地索引前为 false，不记录上传结果
+}
+
+// CatchUp 在后台扫描项目目录，将停机期间新增或修改的文件加入上传队列
+// 按文件大小和修改时间对比本地索引数据库（GetLocalIndexStorePath）中的条目，不计算哈希。
+// 本地索引不存在时（从未执行过全量上传）跳过扫描
+func (u *Uploader) CatchUp(projectName string) {
+	u.replayWG.Add(1)
+	go func() {
+		defer u.replayWG.Done()
+
+		startTime := time.Now()
+		queued, err := u.catchUp(projectName)
+		if err != nil {
+			u.logger.Error("Catch-up scan failed", "project", projectName, "error", err)
+			return
+		}
+		u.logger.Info("Catch-up scan completed", "project", projectName, "queued", queued, "duration", time.Since(startTime).String())
+	}()
+}
+
+// catchUp 执行追赶扫描，返回加入队列的文件数
+func (u *Uploader) catchUp(projectName string) (int, error) {
+	projectConfig, ok := u.configs[projectName]
+	if !ok {
+		return 0, fmt.Errorf("project '%s' not found", projectName)
+	}
+	state := u.localIndexes[projectName]
+	roots := u.roots[projectName]
+
+	// 打开数据库只用于导入或转换旧版本的索引，扫描时按批打开，避免长时间阻塞其他进程
+	store, err := OpenLocalIndexStore(projectName, roots, false)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open local index: %w", err)
+	}
+	if store == nil {
+		u.logger.Info("Local index not found, skipping catch-up scan; run --full-upload to create it", "project", projectName)
+		return 0, nil
+	}
+	localIndex := NewLocalIndex(store.Path())
+	store.Close()
+
+	scanner := NewDirectoryScanner(projectConfig, nil, u.logger)
+	changed, backfill, err := scanner.ScanChanges(localIndex)
+	if err != nil {
+		return 0, err
+	}
+
+	// 补充旧条目的修改时间，之后开始记录上传结果。新增和修改的文件上传成功后才由 recordUpload 写入，
+	// 上传最终失败时下次启动仍会重新发现
+	state.mu.Lock()
+	u.writeLocalIndex(projectName, func(tx *IndexTx) error {
+		for key, entry := range backfill {
+			// 扫描期间条目可能已被更新
+			if current := tx.GetEntry(key); current == nil || current.ModTime != "" || current.Size != entry.Size {
+				continue
+			}
+			if err := tx.Put(key, entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	state.ready = true
+	state.mu.Unlock()
+
+	for _, key := range slices.Sorted(maps.Keys(changed)) {
+		path, _ := roots.LocalPath(key)
+		task := &UploadTask{
+			FilePath:    path,
+			RemotePath:  changed[key].RemotePath,
+			ProjectName: projectName,
+		}
+		u.logger.Debug("File changed while stopped", "project", projectName, "file", path)
+		if c, ok := u.stats[projectName]; ok {
+			c.queued()
+		}
+		added, err := u.queue.AddUntil(task, u.done)
+		if err != nil {
+			u.logger.Warn("Failed to write task journal", "file", task.FilePath, "error", err)
+		}
+		if !added {
+			// 未入队的修改下次启动时重新发现
+			return 0, fmt.Errorf("uploader stopped during catch-up scan")
+		}
+	}
+
+	return len(changed), nil
+}
+
+// recordUpload 将上传成功的文件更新到本地索引，info 为上传前的文件信息
+func (u *Uploader) recordUpload(task *UploadTask, info os.FileInfo) {
+	key, ok := u.indexKey(task.ProjectName, task.FilePath)
+	if !ok {
+		return
+	}
+	u.updateLocalIndex(task.ProjectName, func(tx *IndexTx) error {
+		modTime := FormatModTime(info.ModTime())
+		entry := tx.GetEntry(key)
+		if entry == nil || entry.Size != info.Size() || entry.ModTime != modTime {
+			entry = &FileEntry{Size: info.Size(), ModTime: modTime}
+		}
+		entry.RemotePath = task.RemotePath
+		entry.UploadedTime = time.Now().UTC().Format(time.RFC3339)
+		return tx.Put(key, entry)
+	})
+}
+
+// forgetLocalEntry 从本地索引中删除已删除远程对象的文件
+func (u *Uploader) forgetLocalEntry(task *UploadTask) {
+	key, ok := u.indexKey(task.ProjectName, task.FilePath)
+	if !ok {
+		return
+	}
+	u.updateLocalIndex(task.ProjectName, func(tx *IndexTx) error {
+		return tx.Delete(key)
+	})
+}
+
+// updateLocalIndex 追赶扫描完成后在一个事务中修改项目的本地索引，失败时只记录日志
+func (u *Uploader) updateLocalIndex(projectName string, fn func(tx *IndexTx) error) {
+	state, ok := u.localIndexes[projectName]
+	if !ok {
+		return
+	}
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if state.ready {
+		u.writeLocalIndex(projectName, fn)
+	}
+}
+
+// writeLocalIndex 打开项目的本地索引并在一个事务中执行 fn，调用者持有 localIndexState.mu
+func (u *Uploader) writeLocalIndex(projectName string, fn func(tx *IndexTx) error) {
+	if err := NewLocalIndex(GetLocalIndexStorePath(projectName)).Update(fn); err != nil {
+		u.logger.Warn("Failed to update local index", "project", projectName, "error", err)
+	}
+}
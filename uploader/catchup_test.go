@@ -0,0 +1,168 @@
+package uploader
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/hmw/cos-uploader/config"
+)
+
+// exportLocalIndex 读取项目本地索引数据库中的全部条目
+func exportLocalIndex(t *testing.T, projectName string) *FileIndex {
+	t.Helper()
+
+	store, err := OpenIndexStore(GetLocalIndexStorePath(projectName))
+	if err != nil {
+		t.Fatalf("OpenIndexStore failed: %v", err)
+	}
+	defer store.Close()
+
+	idx, err := store.Export()
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	return idx
+}
+
+func TestCatchUp(t *testing.T) {
+	dir := t.TempDir()
+	proj := config.ProjectConfig{
+		Name:        "catchup",
+		Directories: []string{dir},
+		RootIDs:     map[string]string{dir: "data"},
+		COSConfig:   config.COSConfig{PathPrefix: "uploads/"},
+	}
+	u := newTestUploader(t, newFakeCOS(), proj)
+
+	unchanged := filepath.Join(dir, "unchanged.txt")
+	modified := filepath.Join(dir, "modified.txt")
+	legacy := filepath.Join(dir, "legacy.txt")
+	created := filepath.Join(dir, "sub", "created.txt")
+	for _, path := range []string{unchanged, modified, legacy} {
+		os.WriteFile(path, []byte("data"), 0644)
+	}
+
+	// 停机前的本地索引（旧版本以绝对路径为键的 JSON 格式，追赶扫描时转换后导入数据库）
+	idx := NewFileIndex()
+	idx.Version = "1.0"
+	for _, path := range []string{unchanged, modified} {
+		info, _ := os.Stat(path)
+		idx.AddEntry(path, "hash", info.Size(), "uploads/"+filepath.Base(path))
+		idx.Files[path].ModTime = FormatModTime(info.ModTime())
+	}
+	idx.AddEntry(legacy, "hash", 4, "uploads/legacy.txt") // 旧版本索引没有修改时间
+	if err := idx.SaveToFile(GetLocalIndexPath(proj.Name)); err != nil {
+		t.Fatalf("SaveToFile failed: %v", err)
+	}
+
+	// 停机期间的修改
+	os.WriteFile(modified, []byte("more data"), 0644)
+	os.MkdirAll(filepath.Dir(created), 0755)
+	os.WriteFile(created, []byte("data"), 0644)
+
+	queued, err := u.catchUp(proj.Name)
+	if err != nil {
+		t.Fatalf("catchUp failed: %v", err)
+	}
+	if queued != 2 {
+		t.Fatalf("Expected 2 queued files, got %d", queued)
+	}
+
+	var paths []string
+	var tasks []*UploadTask
+	remotes := make(map[string]string)
+	for i := 0; i < queued; i++ {
+		task := <-u.queue.Tasks()
+		paths = append(paths, task.FilePath)
+		remotes[task.FilePath] = task.RemotePath
+		tasks = append(tasks, task)
+	}
+	sort.Strings(paths)
+	if paths[0] != modified || paths[1] != created {
+		t.Errorf("Unexpected queued files: %v", paths)
+	}
+	if remotes[created] != "uploads/sub/created.txt" {
+		t.Errorf("Unexpected remote path: %s", remotes[created])
+	}
+
+	// 索引只补充旧条目的修改时间，修改和新增的文件在上传成功前不记录
+	saved := exportLocalIndex(t, proj.Name)
+	if saved.Version != IndexVersion || len(saved.Files) != 3 {
+		t.Errorf("Unexpected local index: version %s, %d entries", saved.Version, len(saved.Files))
+	}
+	if entry := saved.Files["data/unchanged.txt"]; entry == nil || entry.Hash != "hash" {
+		t.Errorf("Unchanged entry not migrated: %+v", entry)
+	}
+	if saved.Files["data/legacy.txt"].ModTime == "" || saved.Files["data/modified.txt"].Size != 4 {
+		t.Errorf("Unexpected local index: %+v %+v", saved.Files["data/legacy.txt"], saved.Files["data/modified.txt"])
+	}
+	if saved.Files["data/sub/created.txt"] != nil {
+		t.Error("Created file recorded in local index before upload")
+	}
+
+	// 上传失败时再次扫描仍能发现
+	if queued, _ := u.catchUp(proj.Name); queued != 2 {
+		t.Errorf("Expected not uploaded files to be found again, got %d", queued)
+	}
+	for i := 0; i < 2; i++ {
+		<-u.queue.Tasks()
+	}
+
+	// 上传成功后再次扫描没有修改
+	for _, task := range tasks {
+		info, _ := os.Stat(task.FilePath)
+		u.recordUpload(task, info)
+	}
+	if queued, _ := u.catchUp(proj.Name); queued != 0 {
+		t.Errorf("Expected no changes after uploads, got %d", queued)
+	}
+}
+
+func TestCatchUpWithoutLocalIndex(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("data"), 0644)
+
+	proj := config.ProjectConfig{Name: "catchup", Directories: []string{dir}}
+	u := newTestUploader(t, newFakeCOS(), proj)
+
+	queued, err := u.catchUp(proj.Name)
+	if err != nil || queued != 0 {
+		t.Errorf("Expected catch-up to be skipped, got %d, %v", queued, err)
+	}
+}
+
+func TestRecordUpload(t *testing.T) {
+	dir := t.TempDir()
+	proj := config.ProjectConfig{Name: "catchup", Directories: []string{dir}, RootIDs: map[string]string{dir: "data"}}
+	u := newTestUploader(t, newFakeCOS(), proj)
+
+	path := filepath.Join(dir, "a.txt")
+	os.WriteFile(path, []byte("data"), 0644)
+	info, _ := os.Stat(path)
+	task := &UploadTask{FilePath: path, RemotePath: "a.txt", ProjectName: proj.Name}
+
+	// 追赶扫描之前不记录
+	u.recordUpload(task, info)
+	if _, err := os.Stat(GetLocalIndexStorePath(proj.Name)); !os.IsNotExist(err) {
+		t.Fatalf("Local index should not be written before catch-up scan")
+	}
+
+	store, err := OpenLocalIndexStore(proj.Name, u.roots[proj.Name], true)
+	if err != nil {
+		t.Fatalf("OpenLocalIndexStore failed: %v", err)
+	}
+	store.Close()
+	if _, err := u.catchUp(proj.Name); err != nil {
+		t.Fatalf("catchUp failed: %v", err)
+	}
+	<-u.queue.Tasks()
+
+	u.recordUpload(task, info)
+
+	entry := exportLocalIndex(t, proj.Name).GetEntry("data/a.txt")
+	if entry == nil || entry.UploadedTime == "" || entry.ModTime != FormatModTime(info.ModTime()) {
+		t.Errorf("Unexpected local index entry: %+v", entry)
+	}
+}
@@ -0,0 +1,110 @@
+package uploader
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// checkpointPart 已完成的分块
+type checkpointPart struct {
+	PartNumber int    `json:"part_number"`
+	ETag       string `json:"etag"`
+}
+
+// multipartCheckpoint 分块上传断点信息
+// 进程重启后据此从最后完成的分块继续上传
+type multipartCheckpoint struct {
+	FilePath   string           `json:"file_path"`   // 本地文件路径
+	RemotePath string           `json:"remote_path"` // 远程COS路径
+	UploadID   string           `json:"upload_id"`   // COS 分块上传 ID
+	FileSize   int64            `json:"file_size"`   // 开始上传时的文件大小
+	ModTime    int64            `json:"mod_time"`    // 开始上传时的文件修改时间（纳秒）
+	PartSize   int64            `json:"part_size"`   // 分块大小
+	Parts      []checkpointPart `json:"parts"`       // 已完成的分块
+}
+
+// GetCheckpointDir 获取项目断点文件目录，与本地索引位于同一目录下
+func GetCheckpointDir(projectName string) string {
+	return filepath.Join(filepath.Dir(GetLocalIndexPath(projectName)), "checkpoints")
+}
+
+// GetCheckpointPath 获取远程路径对应的断点文件路径
+func GetCheckpointPath(projectName, remotePath string) string {
+	name := fmt.Sprintf("%x.json", md5.Sum([]byte(remotePath)))
+	return filepath.Join(GetCheckpointDir(projectName), name)
+}
+
+// loadCheckpoint 加载断点文件，文件不存在时返回 nil
+func loadCheckpoint(path string) (*multipartCheckpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	var cp multipartCheckpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal checkpoint: %w", err)
+	}
+	return &cp, nil
+}
+
+// save 保存断点文件（先写临时文件再重命名，避免进程中断时留下损坏的断点）
+func (cp *multipartCheckpoint) save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to rename checkpoint: %w", err)
+	}
+	return nil
+}
+
+// matches 判断断点是否仍适用于当前文件（文件路径、大小、修改时间和分块大小均未变化）
+func (cp *multipartCheckpoint) matches(filePath string, info os.FileInfo, partSize int64) bool {
+	return cp.FilePath == filePath &&
+		cp.FileSize == info.Size() &&
+		cp.ModTime == info.ModTime().UnixNano() &&
+		cp.PartSize == partSize
+}
+
+// partCount 返回文件的总分块数
+func (cp *multipartCheckpoint) partCount() int {
+	return int((cp.FileSize + cp.PartSize - 1) / cp.PartSize)
+}
+
+// addPart 记录完成的分块
+func (cp *multipartCheckpoint) addPart(partNumber int, etag string) {
+	cp.Parts = append(cp.Parts, checkpointPart{PartNumber: partNumber, ETag: etag})
+}
+
+// sortedParts 返回按分块编号排序的已完成分块
+func (cp *multipartCheckpoint) sortedParts() []checkpointPart {
+	parts := make([]checkpointPart, len(cp.Parts))
+	copy(parts, cp.Parts)
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].PartNumber < parts[j].PartNumber
+	})
+	return parts
+}
+
+// removeCheckpoint 删除断点文件
+func removeCheckpoint(path string) {
+	os.Remove(path)
+}
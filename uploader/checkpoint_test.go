@@ -0,0 +1,91 @@
+package uploader
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetCheckpointPath(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	path := GetCheckpointPath("project1", "uploads/a.bin")
+	if !strings.HasPrefix(path, filepath.Dir(GetLocalIndexPath("project1"))) {
+		t.Errorf("Checkpoint path %s should be next to local index", path)
+	}
+
+	if path == GetCheckpointPath("project1", "uploads/b.bin") {
+		t.Error("Different remote paths should use different checkpoint files")
+	}
+}
+
+func TestCheckpointSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoints", "cp.json")
+
+	cp := &multipartCheckpoint{
+		FilePath:   "/data/a.bin",
+		RemotePath: "uploads/a.bin",
+		UploadID:   "upload-1",
+		FileSize:   3 * 1024 * 1024,
+		PartSize:   1024 * 1024,
+	}
+	cp.addPart(2, "etag-2")
+	cp.addPart(1, "etag-1")
+
+	if err := cp.save(path); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+
+	loaded, err := loadCheckpoint(path)
+	if err != nil {
+		t.Fatalf("loadCheckpoint failed: %v", err)
+	}
+	if loaded.UploadID != "upload-1" {
+		t.Errorf("Expected upload ID upload-1, got %s", loaded.UploadID)
+	}
+	if loaded.partCount() != 3 {
+		t.Errorf("Expected 3 parts, got %d", loaded.partCount())
+	}
+
+	parts := loaded.sortedParts()
+	if len(parts) != 2 || parts[0].PartNumber != 1 || parts[1].ETag != "etag-2" {
+		t.Errorf("Unexpected sorted parts: %+v", parts)
+	}
+
+	// 不存在的断点返回 nil
+	missing, err := loadCheckpoint(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil || missing != nil {
+		t.Errorf("Expected nil checkpoint for missing file, got %v (err=%v)", missing, err)
+	}
+}
+
+func TestCheckpointMatches(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "a.bin")
+	if err := os.WriteFile(filePath, []byte("content"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	info, _ := os.Stat(filePath)
+
+	cp := &multipartCheckpoint{
+		FilePath: filePath,
+		FileSize: info.Size(),
+		ModTime:  info.ModTime().UnixNano(),
+		PartSize: minPartSize,
+	}
+	if !cp.matches(filePath, info, minPartSize) {
+		t.Error("Checkpoint should match unchanged file")
+	}
+
+	if cp.matches(filePath, info, 2*minPartSize) {
+		t.Error("Checkpoint should not match a different part size")
+	}
+
+	future := time.Now().Add(time.Hour)
+	os.Chtimes(filePath, future, future)
+	info, _ = os.Stat(filePath)
+	if cp.matches(filePath, info, minPartSize) {
+		t.Error("Checkpoint should not match a modified file")
+	}
+}
@@ -0,0 +1,258 @@
+package uploader
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FailedTask 死信中的失败任务
+type FailedTask struct {
+	ID            string `json:"id"`              // 死信ID
+	FilePath      string `json:"file_path"`       // 本地文件路径
+	RemotePath    string `json:"remote_path"`     // 远程COS路径
+	ProjectName   string `json:"project_name"`    // 项目名称
+	Error         string `json:"error"`           // 最后一次失败的原因
+	Attempts      int    `json:"attempts"`        // 累计上传尝试次数
+	FirstFailedAt string `json:"first_failed_at"` // 首次进入死信的时间
+	LastFailedAt  string `json:"last_failed_at"`  // 最后一次失败的时间
+	Op            string `json:"op,omitempty"`    // 操作类型，delete 表示删除远程对象
+}
+
+// Task 转换为上传任务
+func (f *FailedTask) Task() *UploadTask {
+	return &UploadTask{
+		FilePath:    f.FilePath,
+		RemotePath:  f.RemotePath,
+		ProjectName: f.ProjectName,
+		Op:          f.Op,
+	}
+}
+
+// DeadLetterStore 项目的死信存储，保存重试后仍失败的上传任务
+// 每次读写都持有文件锁（failed.json.lock）并重新读取文件，守护进程和命令行可以共用同一文件
+type DeadLetterStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// deadLetterKey 死信条目的键，同一文件的上传和删除失败分别记录
+type deadLetterKey struct {
+	op       string
+	filePath string
+}
+
+// GetDeadLetterPath 获取项目死信文件路径，与本地索引位于同一目录下
+func GetDeadLetterPath(projectName string) string {
+	return filepath.Join(filepath.Dir(GetLocalIndexPath(projectName)), "failed.json")
+}
+
+// NewDeadLetterStore 创建死信存储
+func NewDeadLetterStore(path string) *DeadLetterStore {
+	return &DeadLetterStore{path: path}
+}
+
+// Record 记录失败的任务，同一文件的同一操作再次失败时累加尝试次数
+func (s *DeadLetterStore) Record(task *UploadTask, attempts int, taskErr error) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	entries, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	errMsg := ""
+	if taskErr != nil {
+		errMsg = taskErr.Error()
+	}
+
+	key := deadLetterKey{op: task.Op, filePath: task.FilePath}
+	entry, ok := entries[key]
+	if !ok {
+		entry = &FailedTask{
+			ID:            newTaskID(),
+			FilePath:      task.FilePath,
+			ProjectName:   task.ProjectName,
+			Op:            task.Op,
+			FirstFailedAt: now,
+		}
+		entries[key] = entry
+	}
+	entry.RemotePath = task.RemotePath
+	entry.Error = errMsg
+	entry.Attempts += attempts
+	entry.LastFailedAt = now
+
+	return s.save(entries)
+}
+
+// List 返回所有失败任务，按首次失败时间排序
+func (s *DeadLetterStore) List() ([]*FailedTask, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	entries, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]*FailedTask, 0, len(entries))
+	for _, entry := range entries {
+		list = append(list, entry)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].FirstFailedAt != list[j].FirstFailedAt {
+			return list[i].FirstFailedAt < list[j].FirstFailedAt
+		}
+		return list[i].FilePath < list[j].FilePath
+	})
+	return list, nil
+}
+
+// Remove 按ID删除失败任务，不指定ID时清空全部，返回删除的数量
+func (s *DeadLetterStore) Remove(ids ...string) (int, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	entries, err := s.load()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	if len(ids) == 0 {
+		removed = len(entries)
+		entries = make(map[deadLetterKey]*FailedTask)
+	} else {
+		wanted := make(map[string]bool, len(ids))
+		for _, id := range ids {
+			wanted[id] = true
+		}
+		for key, entry := range entries {
+			if wanted[entry.ID] {
+				delete(entries, key)
+				removed++
+			}
+		}
+	}
+
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, s.save(entries)
+}
+
+// Resolve 文件上传或删除成功后移除该文件的全部失败任务，返回移除的数量
+// 最近一次成功的操作反映了文件的当前状态，之前失败的上传或删除都不再需要重试
+func (s *DeadLetterStore) Resolve(filePath string) (int, error) {
+	// 没有死信文件时不加锁，避免每次上传都锁定文件
+	if _, err := os.Stat(s.path); os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	unlock, err := s.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	entries, err := s.load()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for key := range entries {
+		if key.filePath == filePath {
+			delete(entries, key)
+			removed++
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, s.save(entries)
+}
+
+// lock 锁定死信文件，同时持有进程内的互斥锁和文件锁，返回解锁函数
+func (s *DeadLetterStore) lock() (func(), error) {
+	s.mu.Lock()
+	unlock, err := lockFile(s.path + ".lock")
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	return func() {
+		unlock()
+		s.mu.Unlock()
+	}, nil
+}
+
+// load 读取死信文件，文件不存在时返回空集合（调用者需持有锁）
+func (s *DeadLetterStore) load() (map[deadLetterKey]*FailedTask, error) {
+	entries := make(map[deadLetterKey]*FailedTask)
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return entries, nil
+		}
+		return nil, fmt.Errorf("failed to read dead-letter file: %w", err)
+	}
+
+	var list []*FailedTask
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal dead-letter file: %w", err)
+	}
+	for _, entry := range list {
+		entries[deadLetterKey{op: entry.Op, filePath: entry.FilePath}] = entry
+	}
+	return entries, nil
+}
+
+// save 写回死信文件（调用者需持有锁）
+func (s *DeadLetterStore) save(entries map[deadLetterKey]*FailedTask) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create dead-letter directory: %w", err)
+	}
+
+	list := make([]*FailedTask, 0, len(entries))
+	for _, entry := range entries {
+		list = append(list, entry)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].FilePath != list[j].FilePath {
+			return list[i].FilePath < list[j].FilePath
+		}
+		return list[i].Op < list[j].Op
+	})
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead-letter file: %w", err)
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write dead-letter file: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to rename dead-letter file: %w", err)
+	}
+	return nil
+}
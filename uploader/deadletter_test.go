@@ -0,0 +1,201 @@
+package uploader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hmw/cos-uploader/config"
+)
+
+func TestDeadLetterStoreRecord(t *testing.T) {
+	store := NewDeadLetterStore(filepath.Join(t.TempDir(), "failed.json"))
+
+	task := &UploadTask{FilePath: "/data/a.txt", RemotePath: "a.txt", ProjectName: "p"}
+	if err := store.Record(task, 4, errors.New("first error")); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	if err := store.Record(task, 3, errors.New("second error")); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	store.Record(&UploadTask{FilePath: "/data/b.txt", RemotePath: "b.txt", ProjectName: "p"}, 4, errors.New("other"))
+
+	entries, err := store.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+
+	var entry *FailedTask
+	for _, e := range entries {
+		if e.FilePath == "/data/a.txt" {
+			entry = e
+		}
+	}
+	if entry == nil {
+		t.Fatal("Entry for /data/a.txt not found")
+	}
+
+	// 同一文件再次失败时累加尝试次数并保留首次失败时间
+	if entry.Attempts != 7 {
+		t.Errorf("Expected 7 attempts, got %d", entry.Attempts)
+	}
+	if entry.Error != "second error" {
+		t.Errorf("Expected last error, got %q", entry.Error)
+	}
+	if entry.ID == "" || entry.FirstFailedAt == "" || entry.LastFailedAt == "" {
+		t.Errorf("Entry missing ID or timestamps: %+v", entry)
+	}
+}
+
+func TestDeadLetterStoreRemove(t *testing.T) {
+	store := NewDeadLetterStore(filepath.Join(t.TempDir(), "failed.json"))
+	store.Record(&UploadTask{FilePath: "/data/a.txt", ProjectName: "p"}, 4, errors.New("a"))
+	store.Record(&UploadTask{FilePath: "/data/b.txt", ProjectName: "p"}, 4, errors.New("b"))
+	store.Record(&UploadTask{FilePath: "/data/c.txt", ProjectName: "p"}, 4, errors.New("c"))
+
+	entries, _ := store.List()
+	removed, err := store.Remove(entries[0].ID)
+	if err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("Expected 1 removed, got %d", removed)
+	}
+
+	// 不指定ID时清空
+	removed, err = store.Remove()
+	if err != nil {
+		t.Fatalf("Remove all failed: %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("Expected 2 removed, got %d", removed)
+	}
+
+	entries, _ = store.List()
+	if len(entries) != 0 {
+		t.Errorf("Expected empty store, got %d entries", len(entries))
+	}
+}
+
+func TestDeadLetterStoreResolve(t *testing.T) {
+	store := NewDeadLetterStore(filepath.Join(t.TempDir(), "failed.json"))
+	if removed, err := store.Resolve("/data/a.txt"); err != nil || removed != 0 {
+		t.Fatalf("Expected nothing to resolve, got %d, %v", removed, err)
+	}
+
+	// 同一文件的上传失败和删除失败分别记录
+	store.Record(&UploadTask{FilePath: "/data/a.txt", ProjectName: "p"}, 4, errors.New("upload"))
+	store.Record(&UploadTask{FilePath: "/data/a.txt", ProjectName: "p", Op: TaskOpDelete}, 4, errors.New("delete"))
+	store.Record(&UploadTask{FilePath: "/data/b.txt", ProjectName: "p"}, 4, errors.New("upload"))
+	if entries, _ := store.List(); len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(entries))
+	}
+
+	removed, err := store.Resolve("/data/a.txt")
+	if err != nil || removed != 2 {
+		t.Fatalf("Expected 2 resolved entries, got %d, %v", removed, err)
+	}
+	entries, _ := store.List()
+	if len(entries) != 1 || entries[0].FilePath != "/data/b.txt" {
+		t.Errorf("Unexpected remaining entries: %+v", entries)
+	}
+}
+
+func TestDeadLetterStoreConcurrentStores(t *testing.T) {
+	// 两个存储对象模拟守护进程和命令行，只靠文件锁互斥
+	path := filepath.Join(t.TempDir(), "failed.json")
+	stores := []*DeadLetterStore{NewDeadLetterStore(path), NewDeadLetterStore(path)}
+
+	var wg sync.WaitGroup
+	for i, store := range stores {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				task := &UploadTask{FilePath: fmt.Sprintf("/data/%d-%d.txt", i, j), ProjectName: "p"}
+				if err := store.Record(task, 1, errors.New("failed")); err != nil {
+					t.Errorf("Record failed: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	entries, err := stores[0].List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if runtime.GOOS != "windows" && len(entries) != 40 {
+		t.Errorf("Expected 40 entries, got %d", len(entries))
+	}
+}
+
+func TestWorkerClearsDeadLetter(t *testing.T) {
+	fake := newFakeCOS()
+	u := newTestUploader(t, fake, config.ProjectConfig{Name: "test"})
+
+	filePath := filepath.Join(t.TempDir(), "a.txt")
+	os.WriteFile(filePath, []byte("content"), 0644)
+	task := &UploadTask{FilePath: filePath, RemotePath: "data/a.txt", ProjectName: "test"}
+	u.handleFinalFailure(task, 4, errors.New("network down"))
+
+	u.pool = NewWorkerPool(1, u, u.logger)
+	u.pool.Start()
+	defer u.pool.Stop()
+	u.pool.AddTask(&UploadTask{FilePath: filePath, RemotePath: "data/a.txt", ProjectName: "test"})
+
+	store, _ := u.DeadLetters("test")
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		entries, _ := store.List()
+		if len(entries) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected successful upload to clear dead-letter, got %d entries", len(entries))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRetryFailed(t *testing.T) {
+	fake := newFakeCOS()
+	u := newTestUploader(t, fake, config.ProjectConfig{Name: "test"})
+
+	filePath := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(filePath, []byte("content"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	task := &UploadTask{FilePath: filePath, RemotePath: "data/a.txt", ProjectName: "test"}
+	u.handleFinalFailure(task, 4, errors.New("network down"))
+
+	succeeded, failed, err := u.RetryFailed("test", nil)
+	if err != nil {
+		t.Fatalf("RetryFailed failed: %v", err)
+	}
+	if succeeded != 1 || failed != 0 {
+		t.Errorf("Expected 1 succeeded and 0 failed, got %d and %d", succeeded, failed)
+	}
+	if string(fake.objects["data/a.txt"]) != "content" {
+		t.Error("Retried file was not uploaded")
+	}
+
+	store, _ := u.DeadLetters("test")
+	entries, _ := store.List()
+	if len(entries) != 0 {
+		t.Errorf("Expected dead-letter to be empty after retry, got %d entries", len(entries))
+	}
+
+	if _, _, err := u.RetryFailed("missing", nil); err == nil {
+		t.Error("Expected error for unknown project")
+	}
+}
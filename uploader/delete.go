@@ -0,0 +1,138 @@
+package uploader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// TaskOpDelete 删除远程对象的任务类型
+const TaskOpDelete = "delete"
+
+// ErrDeleteLimitExceeded 超出每分钟删除上限时返回的错误
+var ErrDeleteLimitExceeded = errors.New("remote delete limit exceeded")
+
+// deleteLimiter 限制每分钟删除的远程对象数，防止误删整个目录时清空桶
+type deleteLimiter struct {
+	limit int // 小于 0 表示不限制
+
+	mu    sync.Mutex
+	times []time.Time // 最近一分钟内允许的删除
+}
+
+// newDeleteLimiter 创建删除限流器，0 使用默认值 100
+func newDeleteLimiter(limit int) *deleteLimiter {
+	if limit == 0 {
+		limit = 100
+	}
+	return &deleteLimiter{limit: limit}
+}
+
+// allow 判断一分钟内的删除数是否未超出上限，允许时记录本次删除
+func (l *deleteLimiter) allow(now time.Time) bool {
+	if l.limit < 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cutoff := now.Add(-time.Minute)
+	i := 0
+	for i < len(l.times) && !l.times[i].After(cutoff) {
+		i++
+	}
+	l.times = l.times[i:]
+
+	if len(l.times) >= l.limit {
+		return false
+	}
+	l.times = append(l.times, now)
+	return true
+}
+
+// AddDeleteTask 添加删除远程对象的任务
+// 超出项目每分钟删除上限时不入队，记录到死信并返回 ErrDeleteLimitExceeded
+func (u *Uploader) AddDeleteTask(task *UploadTask) error {
+	task.Op = TaskOpDelete
+	if err := u.limitDelete(task); err != nil {
+		return err
+	}
+	u.AddTask(task)
+	return nil
+}
+
+// limitDelete 检查项目的每分钟删除上限，超出时将任务记录到死信并返回 ErrDeleteLimitExceeded
+// 跳过的删除可以通过 failed retry 手动执行
+func (u *Uploader) limitDelete(task *UploadTask) error {
+	limiter, ok := u.deleteLimiters[task.ProjectName]
+	if !ok || limiter.allow(time.Now()) {
+		return nil
+	}
+
+	err := fmt.Errorf("%w: skipped deleting %s (limit %d per minute)", ErrDeleteLimitExceeded, task.RemotePath, limiter.limit)
+	if store, ok := u.deadLetters[task.ProjectName]; ok {
+		if recordErr := store.Record(task, 0, err); recordErr != nil {
+			u.logger.Warn("Failed to record skipped delete", "file", task.FilePath, "error", recordErr)
+		}
+	}
+	return err
+}
+
+// deleteRemote 删除本地文件对应的 COS 对象和远程索引条目
+// 本地文件（或保留的符号链接）重新出现时不删除。与同一远程路径的上传串行执行
+func (u *Uploader) deleteRemote(task *UploadTask) error {
+	unlock := u.pathLocks.lock(task.ProjectName, task.RemotePath)
+	defer unlock()
+
+	if _, err := os.Lstat(task.FilePath); err == nil {
+		u.logger.Info("File exists again, skipping remote delete", "file", task.FilePath, "remote", task.RemotePath)
+		return nil
+	}
+
+	client, ok := u.clients[task.ProjectName]
+	if !ok {
+		return fmt.Errorf("COS client not found for project %s", task.ProjectName)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if _, err := client.Object.Delete(ctx, task.RemotePath); err != nil {
+		return fmt.Errorf("failed to delete object from COS: %w", err)
+	}
+	u.logger.Info("Remote object deleted", "file", task.FilePath, "remote", task.RemotePath)
+
+	if key, ok := u.indexKey(task.ProjectName, task.FilePath); ok {
+		u.queueRemoteChange(task.ProjectName, RemoteIndexChange{Key: key})
+	}
+
+	u.forgetLocalEntry(task)
+	return nil
+}
+
+// deleteRemoteWithRetry 删除远程对象并重试指定次数
+func (u *Uploader) deleteRemoteWithRetry(task *UploadTask, maxRetries int) error {
+	var err error
+	for attempt := 0; attempt < maxRetries; attempt++ {
+		err = u.deleteRemote(task)
+		if err == nil {
+			return nil
+		}
+
+		if attempt < maxRetries-1 {
+			waitTime := time.Duration(1<<uint(attempt)) * time.Second
+			u.logger.Warn("Delete failed, retrying",
+				"file", task.FilePath,
+				"attempt", attempt+1,
+				"max_attempts", maxRetries,
+				"wait", waitTime.String(),
+				"error", err)
+			time.Sleep(waitTime)
+		}
+	}
+	return fmt.Errorf("delete failed after %d attempts: %w", maxRetries, err)
+}
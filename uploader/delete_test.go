@@ -0,0 +1,162 @@
+package uploader
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hmw/cos-uploader/config"
+)
+
+func TestDeleteLimiter(t *testing.T) {
+	l := newDeleteLimiter(2)
+	now := time.Now()
+
+	if !l.allow(now) || !l.allow(now.Add(time.Second)) {
+		t.Fatal("Expected first two deletes to be allowed")
+	}
+	if l.allow(now.Add(2 * time.Second)) {
+		t.Error("Expected third delete within a minute to be refused")
+	}
+	if !l.allow(now.Add(61 * time.Second)) {
+		t.Error("Expected delete to be allowed after the window")
+	}
+
+	unlimited := newDeleteLimiter(-1)
+	for i := 0; i < 1000; i++ {
+		if !unlimited.allow(now) {
+			t.Fatal("Unlimited limiter refused a delete")
+		}
+	}
+}
+
+func TestAddDeleteTaskLimit(t *testing.T) {
+	proj := config.ProjectConfig{
+		Name:    "delete",
+		Watcher: config.WatcherConfig{DeleteLimit: 1},
+	}
+	u := newTestUploader(t, newFakeCOS(), proj)
+
+	if err := u.AddDeleteTask(&UploadTask{FilePath: "/data/a.txt", RemotePath: "a.txt", ProjectName: proj.Name}); err != nil {
+		t.Fatalf("AddDeleteTask failed: %v", err)
+	}
+	if task := <-u.queue.Tasks(); task.Op != TaskOpDelete {
+		t.Errorf("Expected delete task, got op %q", task.Op)
+	}
+
+	err := u.AddDeleteTask(&UploadTask{FilePath: "/data/b.txt", RemotePath: "b.txt", ProjectName: proj.Name})
+	if !errors.Is(err, ErrDeleteLimitExceeded) {
+		t.Errorf("Expected ErrDeleteLimitExceeded, got %v", err)
+	}
+
+	// 跳过的删除记录到死信，可以手动重试
+	entries, _ := u.deadLetters[proj.Name].List()
+	if len(entries) != 1 || entries[0].FilePath != "/data/b.txt" || entries[0].Op != TaskOpDelete {
+		t.Errorf("Expected skipped delete in dead-letter, got %+v", entries)
+	}
+}
+
+func TestReplayPendingDeleteLimit(t *testing.T) {
+	proj := config.ProjectConfig{
+		Name:    "delete",
+		Watcher: config.WatcherConfig{DeleteLimit: 1},
+	}
+	u := newTestUploader(t, newFakeCOS(), proj)
+
+	journal, err := OpenTaskJournal(filepath.Join(t.TempDir(), "journal.log"))
+	if err != nil {
+		t.Fatalf("OpenTaskJournal failed: %v", err)
+	}
+	defer journal.Close()
+	for _, name := range []string{"a.txt", "b.txt"} {
+		journal.Enqueued(&UploadTask{FilePath: "/data/" + name, RemotePath: name, ProjectName: proj.Name, Op: TaskOpDelete})
+	}
+	u.queue.SetJournal(journal)
+
+	// 重放的删除任务同样受删除上限限制
+	u.replayPending()
+	u.replayWG.Wait()
+
+	if len(u.queue.tasks) != 1 {
+		t.Errorf("Expected 1 replayed delete task, got %d", len(u.queue.tasks))
+	}
+	entries, _ := u.deadLetters[proj.Name].List()
+	if len(entries) != 1 || entries[0].Op != TaskOpDelete {
+		t.Errorf("Expected skipped delete in dead-letter, got %+v", entries)
+	}
+	if pending := journal.Pending(); len(pending) != 1 {
+		t.Errorf("Expected skipped delete to leave the journal, got %d pending", len(pending))
+	}
+}
+
+func TestDeleteRemote(t *testing.T) {
+	fake := newFakeCOS()
+	dir := t.TempDir()
+	proj := config.ProjectConfig{
+		Name:        "delete",
+		Directories: []string{dir},
+		RootIDs:     map[string]string{dir: "data"},
+		COSConfig:   config.COSConfig{PathPrefix: "uploads/"},
+	}
+	u := newTestUploader(t, fake, proj)
+
+	removed := filepath.Join(dir, "removed.txt")
+	kept := filepath.Join(dir, "kept.txt")
+	restored := filepath.Join(dir, "restored.txt")
+	os.WriteFile(kept, []byte("data"), 0644)
+
+	fake.objects["uploads/removed.txt"] = []byte("data")
+	fake.objects["uploads/kept.txt"] = []byte("data")
+	fake.objects["uploads/restored.txt"] = []byte("data")
+
+	indexManager := NewIndexManager(u.clients[proj.Name], &proj.COSConfig, u.logger)
+	remoteIdx := NewFileIndex()
+	remoteIdx.AddEntry("data/removed.txt", "hash", 4, "uploads/removed.txt")
+	remoteIdx.AddEntry("data/kept.txt", "hash", 4, "uploads/kept.txt")
+	remoteIdx.AddEntry("data/restored.txt", "hash", 4, "uploads/restored.txt")
+	if err := indexManager.UploadRemoteIndex(context.Background(), remoteIdx, proj.Name); err != nil {
+		t.Fatalf("UploadRemoteIndex failed: %v", err)
+	}
+
+	if err := u.deleteRemote(&UploadTask{FilePath: removed, RemotePath: "uploads/removed.txt", ProjectName: proj.Name, Op: TaskOpDelete}); err != nil {
+		t.Fatalf("deleteRemote failed: %v", err)
+	}
+	// 本地文件仍然存在时不删除
+	if err := u.deleteRemote(&UploadTask{FilePath: kept, RemotePath: "uploads/kept.txt", ProjectName: proj.Name, Op: TaskOpDelete}); err != nil {
+		t.Fatalf("deleteRemote failed: %v", err)
+	}
+
+	if err := u.deleteRemote(&UploadTask{FilePath: restored, RemotePath: "uploads/restored.txt", ProjectName: proj.Name, Op: TaskOpDelete}); err != nil {
+		t.Fatalf("deleteRemote failed: %v", err)
+	}
+
+	// 远程索引条目在 flush 时批量删除，期间重新出现的文件保留条目
+	remoteIdx, err := indexManager.DownloadRemoteIndex(context.Background(), proj.Name)
+	if err != nil {
+		t.Fatalf("DownloadRemoteIndex failed: %v", err)
+	}
+	if remoteIdx.GetEntry("data/removed.txt") == nil {
+		t.Error("Remote index should not change before flush")
+	}
+	os.WriteFile(restored, []byte("data"), 0644)
+	u.flushRemoteChanges()
+
+	if _, ok := fake.objects["uploads/removed.txt"]; ok {
+		t.Error("Expected removed.txt to be deleted from COS")
+	}
+	if _, ok := fake.objects["uploads/kept.txt"]; !ok {
+		t.Error("Expected kept.txt to stay in COS")
+	}
+
+	remoteIdx, err = indexManager.DownloadRemoteIndex(context.Background(), proj.Name)
+	if err != nil {
+		t.Fatalf("DownloadRemoteIndex failed: %v", err)
+	}
+	if remoteIdx.GetEntry("data/removed.txt") != nil || remoteIdx.GetEntry("data/kept.txt") == nil ||
+		remoteIdx.GetEntry("data/restored.txt") == nil {
+		t.Errorf("Unexpected remote index entries: %v", remoteIdx.Files)
+	}
+}
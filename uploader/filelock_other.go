@@ -0,0 +1,8 @@
+//go:build !unix
+
+package uploader
+
+// lockFile 当前平台不支持 flock，只依靠进程内的互斥锁
+func lockFile(path string) (func(), error) {
+	return func() {}, nil
+}
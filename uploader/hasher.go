@@ -2,26 +2,109 @@ package uploader
 
 import (
 	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"hash"
+	"hash/crc64"
 	"io"
 	"os"
+	"strconv"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// 支持的哈希算法
+const (
+	HashMD5       = "md5"
+	HashSHA256    = "sha256"
+	HashCRC64ECMA = "crc64ecma" // 十进制表示，与 COS 返回的 x-cos-hash-crc64ecma 相同
+	HashXXHash    = "xxhash"    // XXH64
 )
 
-// FileHasher 文件哈希计算器
+// newHash 创建指定算法的哈希，空字符串表示 MD5
+func newHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case "", HashMD5:
+		return md5.New(), nil
+	case HashSHA256:
+		return sha256.New(), nil
+	case HashCRC64ECMA:
+		return crc64.New(crc64.MakeTable(crc64.ECMA)), nil
+	case HashXXHash:
+		return xxhash.New(), nil
+	default:
+		return nil, fmt.Errorf("unknown hash algorithm '%s'", algorithm)
+	}
+}
+
+// encodeHash 按算法格式化哈希值：CRC64 使用十进制，其他算法使用十六进制
+func encodeHash(algorithm string, h hash.Hash) string {
+	if algorithm == HashCRC64ECMA {
+		return strconv.FormatUint(h.(hash.Hash64).Sum64(), 10)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// FileHasher 文件哈希计算器，可在多个协程中并发使用
 type FileHasher struct {
-	bufferSize int // 读取缓冲区大小，默认 32MB
+	bufferSize int       // 读取缓冲区大小，默认 32MB
+	buffers    sync.Pool // 复用读取缓冲区，存放 *[]byte
+	algorithm  string    // ComputeHash 使用的算法，默认 MD5
 }
 
 // NewFileHasher 创建文件哈希计算器
 func NewFileHasher() *FileHasher {
-	return &FileHasher{
-		bufferSize: 32 * 1024 * 1024, // 32MB 缓冲
+	return NewFileHasherWithBuffer(32 * 1024 * 1024) // 32MB 缓冲
+}
+
+// NewFileHasherWithBuffer 创建使用指定读取缓冲区大小的文件哈希计算器
+// 并发计算时每个协程占用一个缓冲区，计算完成后放回缓冲池复用
+func NewFileHasherWithBuffer(bufferSize int) *FileHasher {
+	h := &FileHasher{bufferSize: bufferSize, algorithm: HashMD5}
+	h.buffers.New = func() any {
+		buffer := make([]byte, h.bufferSize)
+		return &buffer
+	}
+	return h
+}
+
+// SetAlgorithm 设置 ComputeHash 和 ComputeSymlinkHash 使用的哈希算法，空字符串表示 MD5
+func (h *FileHasher) SetAlgorithm(algorithm string) error {
+	if _, err := newHash(algorithm); err != nil {
+		return err
+	}
+	if algorithm == "" {
+		algorithm = HashMD5
 	}
+	h.algorithm = algorithm
+	return nil
+}
+
+// Algorithm 返回 ComputeHash 使用的哈希算法
+func (h *FileHasher) Algorithm() string {
+	return h.algorithm
 }
 
 // ComputeMD5 计算文件的 MD5 哈希
 // 使用流式计算，支持大文件
 func (h *FileHasher) ComputeMD5(filePath string) (string, int64, error) {
+	return h.ComputeHashWith(filePath, HashMD5)
+}
+
+// ComputeHash 使用设置的算法计算文件的哈希
+func (h *FileHasher) ComputeHash(filePath string) (string, int64, error) {
+	return h.ComputeHashWith(filePath, h.algorithm)
+}
+
+// ComputeHashWith 使用指定算法计算文件的哈希，用于与其他算法生成的索引条目比较
+func (h *FileHasher) ComputeHashWith(filePath, algorithm string) (string, int64, error) {
+	hash, err := newHash(algorithm)
+	if err != nil {
+		return "", 0, err
+	}
+
 	file, err := os.Open(filePath)
 	if err != nil {
 		return "", 0, fmt.Errorf("failed to open file: %w", err)
@@ -35,9 +118,9 @@ func (h *FileHasher) ComputeMD5(filePath string) (string, int64, error) {
 	}
 	fileSize := fileInfo.Size()
 
-	// 计算 MD5
-	hash := md5.New()
-	buffer := make([]byte, h.bufferSize)
+	bufferPtr := h.buffers.Get().(*[]byte)
+	defer h.buffers.Put(bufferPtr)
+	buffer := *bufferPtr
 
 	for {
 		n, err := file.Read(buffer)
@@ -52,8 +135,22 @@ func (h *FileHasher) ComputeMD5(filePath string) (string, int64, error) {
 		}
 	}
 
-	// 返回十六进制格式的哈希值
-	return fmt.Sprintf("%x", hash.Sum(nil)), fileSize, nil
+	return encodeHash(algorithm, hash), fileSize, nil
+}
+
+// ComputeSymlinkHash 使用设置的算法计算符号链接目标路径的哈希，大小为 0
+// preserve 方式下符号链接上传为空对象，链接目标变化时哈希随之变化
+func (h *FileHasher) ComputeSymlinkHash(path string) (string, int64, error) {
+	target, err := os.Readlink(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read symlink: %w", err)
+	}
+	hash, err := newHash(h.algorithm)
+	if err != nil {
+		return "", 0, err
+	}
+	hash.Write([]byte(target))
+	return encodeHash(h.algorithm, hash), 0, nil
 }
 
 // ComputeMD5Batch 批量计算多个文件的 MD5
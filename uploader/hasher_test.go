@@ -3,9 +3,15 @@ package uploader
 import (
 	"crypto/md5"
 	"fmt"
+	"hash/crc64"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+
+	"github.com/cespare/xxhash/v2"
 )
 
 func TestNewFileHasher(t *testing.T) {
@@ -264,3 +270,70 @@ func TestComputeMD5_Different_Files(t *testing.T) {
 		t.Fatal("Different files should have different hashes")
 	}
 }
+
+func TestComputeMD5_ConcurrentSmallBuffer(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// 缓冲区小于文件时分多次读取，并发计算时各协程使用各自的缓冲区
+	hasher := NewFileHasherWithBuffer(7)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		content := []byte(strings.Repeat(fmt.Sprintf("content %d ", i), 10))
+		path := filepath.Join(tmpDir, fmt.Sprintf("file%d.txt", i))
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				hash, _, err := hasher.ComputeMD5(path)
+				if err != nil {
+					t.Errorf("ComputeMD5 failed: %v", err)
+					return
+				}
+				if want := fmt.Sprintf("%x", md5.Sum(content)); hash != want {
+					t.Errorf("Expected hash %s for %s, got %s", want, path, hash)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestComputeHash_Algorithms(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test.txt")
+	if err := os.WriteFile(testFile, []byte("hello"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		algorithm string
+		want      string
+	}{
+		{"", "5d41402abc4b2a76b9719d911017c592"},
+		{HashMD5, "5d41402abc4b2a76b9719d911017c592"},
+		{HashSHA256, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"},
+		{HashCRC64ECMA, strconv.FormatUint(crc64.Checksum([]byte("hello"), crc64.MakeTable(crc64.ECMA)), 10)},
+		{HashXXHash, fmt.Sprintf("%016x", xxhash.Sum64String("hello"))},
+	}
+	for _, tt := range tests {
+		hasher := NewFileHasher()
+		if err := hasher.SetAlgorithm(tt.algorithm); err != nil {
+			t.Fatalf("SetAlgorithm(%q) failed: %v", tt.algorithm, err)
+		}
+		hash, size, err := hasher.ComputeHash(testFile)
+		if err != nil {
+			t.Fatalf("ComputeHash(%q) failed: %v", tt.algorithm, err)
+		}
+		if hash != tt.want || size != 5 {
+			t.Errorf("ComputeHash(%q) = %s, %d; want %s, 5", tt.algorithm, hash, size, tt.want)
+		}
+	}
+
+	if err := NewFileHasher().SetAlgorithm("sha1"); err == nil {
+		t.Error("Expected error for unknown algorithm")
+	}
+}
@@ -18,16 +18,43 @@ import (
 // FileEntry 索引中的文件条目
 type FileEntry struct {
 	Size           int64  `json:"size"`           // 文件大小（字节）
-	Hash           string `json:"hash"`           // 文件 MD5 哈希值
+	Hash           string `json:"hash"`           // 文件哈希值
+	HashAlgorithm  string `json:"hash_algorithm,omitempty"` // 哈希算法，为空表示 md5
 	UploadedTime   string `json:"uploaded_time"`  // 上传时间戳
 	RemotePath     string `json:"remote_path"`    // 远程路径
+	ModTime        string `json:"mod_time,omitempty"` // 文件修改时间，用于启动时发现停机期间修改的文件
+	Inode          uint64 `json:"inode,omitempty"`    // inode 号，与大小和修改时间一起判断能否复用哈希
 }
 
 // FileIndex 本地或远程文件索引
 type FileIndex struct {
 	Version   string                `json:"version"`    // 索引版本
 	Timestamp string                `json:"timestamp"`  // 索引生成时间
-	Files     map[string]*FileEntry `json:"files"`      // 本地路径 -> 文件条目
+	Files     map[string]*FileEntry `json:"files"`      // 索引键（根标识/相对路径，见 IndexRoots） -> 文件条目
+}
+
+// IndexReader 按索引键读取索引条目，条目不存在时返回 nil
+type IndexReader interface {
+	GetEntry(key string) *FileEntry
+}
+
+// IndexBatchReader 一次读取多个索引键的条目，扫描时每批文件只读取一次（如 LocalIndex）
+type IndexBatchReader interface {
+	GetEntries(keys []string) (map[string]*FileEntry, error)
+}
+
+// lookupEntries 读取多个索引键的条目，reader 实现 IndexBatchReader 时一次读取
+func lookupEntries(reader IndexReader, keys []string) (map[string]*FileEntry, error) {
+	if batch, ok := reader.(IndexBatchReader); ok {
+		return batch.GetEntries(keys)
+	}
+	entries := make(map[string]*FileEntry, len(keys))
+	for _, key := range keys {
+		if entry := reader.GetEntry(key); entry != nil {
+			entries[key] = entry
+		}
+	}
+	return entries, nil
 }
 
 // IndexManager 索引管理器
@@ -35,6 +62,7 @@ type IndexManager struct {
 	logger    *logger.Logger
 	cosClient *cos.Client
 	cosConfig *config.COSConfig
+	roots     *IndexRoots // 用于迁移旧版本的远程索引（可选）
 }
 
 // NewIndexManager 创建索引管理器
@@ -49,15 +77,15 @@ func NewIndexManager(cosClient *cos.Client, cosConfig *config.COSConfig, log *lo
 // NewFileIndex 创建新的空索引
 func NewFileIndex() *FileIndex {
 	return &FileIndex{
-		Version:   "1.0",
+		Version:   IndexVersion,
 		Timestamp: time.Now().UTC().Format(time.RFC3339),
 		Files:     make(map[string]*FileEntry),
 	}
 }
 
 // AddEntry 向索引添加条目
-func (idx *FileIndex) AddEntry(localPath, hash string, size int64, remotePath string) {
-	idx.Files[localPath] = &FileEntry{
+func (idx *FileIndex) AddEntry(key, hash string, size int64, remotePath string) {
+	idx.Files[key] = &FileEntry{
 		Size:         size,
 		Hash:         hash,
 		UploadedTime: time.Now().UTC().Format(time.RFC3339),
@@ -66,8 +94,8 @@ func (idx *FileIndex) AddEntry(localPath, hash string, size int64, remotePath st
 }
 
 // GetEntry 从索引获取条目
-func (idx *FileIndex) GetEntry(localPath string) *FileEntry {
-	return idx.Files[localPath]
+func (idx *FileIndex) GetEntry(key string) *FileEntry {
+	return idx.Files[key]
 }
 
 // SaveToFile 保存本地索引文件
@@ -111,6 +139,11 @@ func LoadFileIndexFromFile(filePath string) (*FileIndex, error) {
 	return &idx, nil
 }
 
+// FormatModTime 格式化索引中记录的文件修改时间
+func FormatModTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
 // GetLocalIndexPath 获取本地索引文件路径
 func GetLocalIndexPath(projectName string) string {
 	homeDir, err := os.UserHomeDir()
@@ -120,7 +153,14 @@ func GetLocalIndexPath(projectName string) string {
 	return filepath.Join(homeDir, ".cos-uploader", projectName, "local_index.json")
 }
 
-// DownloadRemoteIndex 从 COS 下载远程索引
+// SetRoots 设置项目的监控目录，下载到以本地绝对路径为键的旧版本远程索引时按监控目录转换
+// 转换后的索引在下次上传远程索引时写回
+func (im *IndexManager) SetRoots(roots *IndexRoots) {
+	im.roots = roots
+}
+
+// DownloadRemoteIndex 从 COS 下载远程索引，远程索引不存在时返回空索引
+// 其他错误直接返回，由调用者决定是否使用空索引继续，避免用空索引覆盖远程索引
 func (im *IndexManager) DownloadRemoteIndex(ctx context.Context, projectName string) (*FileIndex, error) {
 	// 远程索引路径
 	remoteIndexPath := im.cosConfig.PathPrefix + ".cos-uploader/" + projectName + "/remote_index.json"
@@ -133,9 +173,7 @@ func (im *IndexManager) DownloadRemoteIndex(ctx context.Context, projectName str
 			im.logger.Info("Remote index not found, creating new one", "project", projectName)
 			return NewFileIndex(), nil
 		}
-		// 其他错误
-		im.logger.Warn("Failed to download remote index", "project", projectName, "error", err)
-		return NewFileIndex(), nil // 降级处理，继续上传
+		return nil, fmt.Errorf("failed to download remote index: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -150,6 +188,11 @@ func (im *IndexManager) DownloadRemoteIndex(ctx context.Context, projectName str
 	if err := json.Unmarshal(data, &idx); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal remote index: %w", err)
 	}
+	if im.roots != nil && idx.Version != IndexVersion {
+		version := idx.Version
+		dropped := im.roots.Migrate(&idx)
+		im.logger.Info("Remote index migrated", "project", projectName, "from_version", version, "entries", len(idx.Files), "dropped", dropped)
+	}
 
 	im.logger.Info("Remote index downloaded", "project", projectName, "entries", len(idx.Files))
 	return &idx, nil
@@ -176,21 +219,85 @@ func (im *IndexManager) UploadRemoteIndex(ctx context.Context, idx *FileIndex, p
 	return nil
 }
 
+// RemoteIndexChange 远程索引中一个条目的修改
+type RemoteIndexChange struct {
+	Key        string // 删除的条目，或移动前的条目
+	NewKey     string // 移动后的索引键，为空表示删除
+	RemotePath string // 移动后的远程路径
+}
+
+// ApplyRemoteChanges 下载一次远程索引，按顺序应用 changes 后上传，返回实际修改的条目数
+// 条目都不存在时不修改远程索引，远程索引无法下载时返回错误
+func (im *IndexManager) ApplyRemoteChanges(ctx context.Context, projectName string, changes []RemoteIndexChange) (int, error) {
+	idx, err := im.DownloadRemoteIndex(ctx, projectName)
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, change := range changes {
+		entry, ok := idx.Files[change.Key]
+		if !ok {
+			continue
+		}
+		delete(idx.Files, change.Key)
+		if change.NewKey != "" {
+			entry.RemotePath = change.RemotePath
+			entry.UploadedTime = now
+			idx.Files[change.NewKey] = entry
+		}
+		applied++
+	}
+	if applied == 0 {
+		return 0, nil
+	}
+
+	idx.Timestamp = now
+	if err := im.UploadRemoteIndex(ctx, idx, projectName); err != nil {
+		return 0, err
+	}
+	return applied, nil
+}
+
+// Algorithm 返回条目的哈希算法，旧索引中没有记录算法的条目为 md5
+func (e *FileEntry) Algorithm() string {
+	if e.HashAlgorithm == "" {
+		return HashMD5
+	}
+	return e.HashAlgorithm
+}
+
 // CompareWithRemote 对比本地和远程索引，返回需要上传的文件
+// 两个条目的哈希算法不同时，大小相同的本地文件（按 roots 定位）按远程条目的算法重新计算哈希后比较，
+// 内容相同时远程条目改为本地的哈希和算法，保存远程索引后下次不必再重新计算
 // 返回值: 需要上传的文件 map，已跳过的数量
-func CompareIndices(localIdx, remoteIdx *FileIndex) (map[string]*FileEntry, int) {
+func CompareIndices(localIdx, remoteIdx *FileIndex, roots *IndexRoots) (map[string]*FileEntry, int) {
 	needsUpload := make(map[string]*FileEntry)
 	skipped := 0
+	var hasher *FileHasher
 
-	for localPath, localEntry := range localIdx.Files {
-		remoteEntry, exists := remoteIdx.Files[localPath]
+	for key, localEntry := range localIdx.Files {
+		remoteEntry, exists := remoteIdx.Files[key]
 
 		if !exists {
 			// 文件不在远程索引中，需要上传
-			needsUpload[localPath] = localEntry
+			needsUpload[key] = localEntry
+		} else if remoteEntry.Algorithm() != localEntry.Algorithm() {
+			if hasher == nil {
+				hasher = NewFileHasher()
+			}
+			localPath, ok := roots.LocalPath(key)
+			if ok && sameRemoteHash(hasher, localPath, localEntry, remoteEntry) {
+				remoteEntry.Hash = localEntry.Hash
+				remoteEntry.HashAlgorithm = localEntry.HashAlgorithm
+				skipped++
+			} else {
+				needsUpload[key] = localEntry
+			}
 		} else if remoteEntry.Hash != localEntry.Hash {
 			// 文件存在但哈希不同，需要重新上传
-			needsUpload[localPath] = localEntry
+			needsUpload[key] = localEntry
 		} else {
 			// 文件已存在且哈希相同，跳过
 			skipped++
@@ -200,15 +307,30 @@ func CompareIndices(localIdx, remoteIdx *FileIndex) (map[string]*FileEntry, int)
 	return needsUpload, skipped
 }
 
+// sameRemoteHash 按远程条目的算法计算本地普通文件的哈希并与远程条目比较
+// 大小不同、不是普通文件或无法计算时返回 false
+func sameRemoteHash(hasher *FileHasher, localPath string, localEntry, remoteEntry *FileEntry) bool {
+	if remoteEntry.Hash == "" || remoteEntry.Size != localEntry.Size {
+		return false
+	}
+	info, err := os.Lstat(localPath)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	hash, _, err := hasher.ComputeHashWith(localPath, remoteEntry.Algorithm())
+	return err == nil && hash == remoteEntry.Hash
+}
+
 // UpdateRemoteIndexWithUploads 使用上传结果更新远程索引
 func UpdateRemoteIndexWithUploads(remoteIdx *FileIndex, uploads map[string]*FileEntry) {
-	for localPath, entry := range uploads {
+	for key, entry := range uploads {
 		// 用本次上传的信息更新远程索引
-		remoteIdx.Files[localPath] = &FileEntry{
-			Size:         entry.Size,
-			Hash:         entry.Hash,
-			UploadedTime: time.Now().UTC().Format(time.RFC3339),
-			RemotePath:   entry.RemotePath,
+		remoteIdx.Files[key] = &FileEntry{
+			Size:          entry.Size,
+			Hash:          entry.Hash,
+			HashAlgorithm: entry.HashAlgorithm,
+			UploadedTime:  time.Now().UTC().Format(time.RFC3339),
+			RemotePath:    entry.RemotePath,
 		}
 	}
 	// 更新时间戳
@@ -1,10 +1,14 @@
 package uploader
 
 import (
+	"context"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/hmw/cos-uploader/config"
 )
 
 func TestNewFileIndex(t *testing.T) {
@@ -14,8 +18,8 @@ func TestNewFileIndex(t *testing.T) {
 		t.Fatal("NewFileIndex returned nil")
 	}
 
-	if idx.Version != "1.0" {
-		t.Errorf("Expected version 1.0, got %s", idx.Version)
+	if idx.Version != IndexVersion {
+		t.Errorf("Expected version %s, got %s", IndexVersion, idx.Version)
 	}
 
 	if idx.Files == nil {
@@ -172,15 +176,15 @@ func TestLoadFileIndexFromFile_NonExistent(t *testing.T) {
 
 func TestCompareIndices(t *testing.T) {
 	localIdx := NewFileIndex()
-	localIdx.AddEntry("/file1.txt", "hash1", 100, "prefix/file1.txt")
-	localIdx.AddEntry("/file2.txt", "hash2", 200, "prefix/file2.txt")
-	localIdx.AddEntry("/file3.txt", "hash3_new", 300, "prefix/file3.txt")
+	localIdx.AddEntry("data/file1.txt", "hash1", 100, "prefix/file1.txt")
+	localIdx.AddEntry("data/file2.txt", "hash2", 200, "prefix/file2.txt")
+	localIdx.AddEntry("data/file3.txt", "hash3_new", 300, "prefix/file3.txt")
 
 	remoteIdx := NewFileIndex()
-	remoteIdx.AddEntry("/file2.txt", "hash2", 200, "prefix/file2.txt")
-	remoteIdx.AddEntry("/file3.txt", "hash3_old", 300, "prefix/file3.txt")
+	remoteIdx.AddEntry("data/file2.txt", "hash2", 200, "prefix/file2.txt")
+	remoteIdx.AddEntry("data/file3.txt", "hash3_old", 300, "prefix/file3.txt")
 
-	needsUpload, skipped := CompareIndices(localIdx, remoteIdx)
+	needsUpload, skipped := CompareIndices(localIdx, remoteIdx, nil)
 
 	// file1 should be uploaded (not in remote)
 	// file2 should be skipped (exists with same hash)
@@ -194,19 +198,57 @@ func TestCompareIndices(t *testing.T) {
 		t.Errorf("Expected 1 file skipped, got %d", skipped)
 	}
 
-	if _, ok := needsUpload["/file1.txt"]; !ok {
+	if _, ok := needsUpload["data/file1.txt"]; !ok {
 		t.Fatal("file1.txt should be in needsUpload")
 	}
 
-	if _, ok := needsUpload["/file3.txt"]; !ok {
+	if _, ok := needsUpload["data/file3.txt"]; !ok {
 		t.Fatal("file3.txt should be in needsUpload (hash mismatch)")
 	}
 
-	if _, ok := needsUpload["/file2.txt"]; ok {
+	if _, ok := needsUpload["data/file2.txt"]; ok {
 		t.Fatal("file2.txt should not be in needsUpload (hash matches)")
 	}
 }
 
+func TestCompareIndices_MixedAlgorithms(t *testing.T) {
+	tmpDir := t.TempDir()
+	same := filepath.Join(tmpDir, "same.txt")
+	changed := filepath.Join(tmpDir, "changed.txt")
+	os.WriteFile(same, []byte("content"), 0644)
+	os.WriteFile(changed, []byte("updated"), 0644)
+
+	roots := NewIndexRoots(config.ProjectConfig{Directories: []string{tmpDir}, RootIDs: map[string]string{tmpDir: "data"}})
+
+	hasher := NewFileHasher()
+	hasher.SetAlgorithm(HashSHA256)
+	localIdx := NewFileIndex()
+	for _, path := range []string{same, changed} {
+		hash, size, _ := hasher.ComputeHash(path)
+		key, _ := roots.Key(path)
+		localIdx.Files[key] = &FileEntry{Size: size, Hash: hash, HashAlgorithm: HashSHA256}
+	}
+
+	// 远程索引由旧版本生成，没有记录算法（md5）
+	sameMD5, _, _ := hasher.ComputeMD5(same)
+	remoteIdx := NewFileIndex()
+	remoteIdx.Files["data/same.txt"] = &FileEntry{Size: 7, Hash: sameMD5}
+	remoteIdx.Files["data/changed.txt"] = &FileEntry{Size: 7, Hash: sameMD5}
+
+	needsUpload, skipped := CompareIndices(localIdx, remoteIdx, roots)
+	if skipped != 1 || len(needsUpload) != 1 {
+		t.Fatalf("Expected 1 skipped and 1 upload, got %d and %d", skipped, len(needsUpload))
+	}
+	if _, ok := needsUpload["data/changed.txt"]; !ok {
+		t.Error("changed.txt should be in needsUpload")
+	}
+
+	// 匹配的远程条目改为本地的哈希和算法
+	if entry := remoteIdx.Files["data/same.txt"]; entry.Hash != localIdx.Files["data/same.txt"].Hash || entry.Algorithm() != HashSHA256 {
+		t.Errorf("Expected matched remote entry to use the local hash, got %+v", entry)
+	}
+}
+
 func TestUpdateRemoteIndexWithUploads(t *testing.T) {
 	remoteIdx := NewFileIndex()
 
@@ -289,3 +331,180 @@ func TestGetLocalIndexPath(t *testing.T) {
 		t.Errorf("Path should contain .cos-uploader: %s", path)
 	}
 }
+
+func TestExecuteFullUploadSavesMatchedEntries(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "a.txt")
+	os.WriteFile(filePath, []byte("content"), 0644)
+
+	proj := config.ProjectConfig{
+		Name:        "full",
+		Directories: []string{dir},
+		RootIDs:     map[string]string{dir: "data"},
+		COSConfig:   config.COSConfig{PathPrefix: "uploads/"},
+		Scan:        config.ScanConfig{HashAlgorithm: HashSHA256},
+	}
+	u := newTestUploader(t, newFakeCOS(), proj)
+
+	// 远程索引使用 md5，内容与本地相同，不需要上传
+	md5Hash, size, _ := NewFileHasher().ComputeMD5(filePath)
+	remoteIdx := NewFileIndex()
+	remoteIdx.AddEntry("data/a.txt", md5Hash, size, "uploads/a.txt")
+	indexManager := NewIndexManager(u.clients[proj.Name], &proj.COSConfig, u.logger)
+	if err := indexManager.UploadRemoteIndex(context.Background(), remoteIdx, proj.Name); err != nil {
+		t.Fatalf("UploadRemoteIndex failed: %v", err)
+	}
+
+	stats, err := u.ExecuteFullUpload(proj.Name, false)
+	if err != nil {
+		t.Fatalf("ExecuteFullUpload failed: %v", err)
+	}
+	if stats.UploadedFiles != 0 || stats.SkippedFiles != 1 {
+		t.Fatalf("Expected the file to be skipped, got %+v", stats)
+	}
+
+	// 没有文件需要上传时仍保存远程索引，匹配的条目改为本地的算法
+	remoteIdx, err = indexManager.DownloadRemoteIndex(context.Background(), proj.Name)
+	if err != nil {
+		t.Fatalf("DownloadRemoteIndex failed: %v", err)
+	}
+	if entry := remoteIdx.GetEntry("data/a.txt"); entry == nil || entry.Algorithm() != HashSHA256 {
+		t.Errorf("Expected remote entry updated to sha256, got %+v", entry)
+	}
+}
+
+func TestExecuteFullUploadReleasesLocalIndex(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("content"), 0644)
+
+	proj := config.ProjectConfig{
+		Name:        "full",
+		Directories: []string{dir},
+		RootIDs:     map[string]string{dir: "data"},
+		COSConfig:   config.COSConfig{PathPrefix: "uploads/"},
+	}
+	fake := newFakeCOS()
+	u := newTestUploader(t, fake, proj)
+
+	// 本地索引中已删除文件的条目在全量上传后删除
+	store, err := OpenLocalIndexStore(proj.Name, u.roots[proj.Name], true)
+	if err != nil {
+		t.Fatalf("OpenLocalIndexStore failed: %v", err)
+	}
+	storePath := store.Path()
+	store.Put("data/gone.txt", &FileEntry{Size: 1, Hash: "hash"})
+	store.Close()
+
+	// 上传期间本地索引未被占用，守护进程可以写入
+	var openErr error
+	fake.onRequest = func(r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/uploads/a.txt" {
+			return
+		}
+		store, err := OpenIndexStore(storePath)
+		if err != nil {
+			openErr = err
+			return
+		}
+		store.Close()
+	}
+
+	stats, err := u.ExecuteFullUpload(proj.Name, false)
+	if err != nil {
+		t.Fatalf("ExecuteFullUpload failed: %v", err)
+	}
+	if stats.TotalFiles != 1 || stats.UploadedFiles != 1 {
+		t.Fatalf("Expected the file to be uploaded, got %+v", stats)
+	}
+	if openErr != nil {
+		t.Errorf("Local index held during upload: %v", openErr)
+	}
+
+	entries, err := NewLocalIndex(storePath).GetEntries([]string{"data/a.txt", "data/gone.txt"})
+	if err != nil {
+		t.Fatalf("GetEntries failed: %v", err)
+	}
+	if entry := entries["data/a.txt"]; entry == nil || entry.UploadedTime == "" {
+		t.Errorf("Expected uploaded entry in local index, got %+v", entry)
+	}
+	if entries["data/gone.txt"] != nil {
+		t.Error("Expected stale entry to be pruned")
+	}
+}
+
+func TestExecuteFullUploadSkipsFailedFilesInRemoteIndex(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(dir, "b.txt"), []byte("b"), 0644)
+
+	proj := config.ProjectConfig{
+		Name:        "full",
+		Directories: []string{dir},
+		RootIDs:     map[string]string{dir: "data"},
+		COSConfig:   config.COSConfig{PathPrefix: "uploads/"},
+	}
+	fake := newFakeCOS()
+	fake.failPut = "uploads/b.txt"
+	u := newTestUploader(t, fake, proj)
+
+	stats, err := u.ExecuteFullUpload(proj.Name, false)
+	if err != nil {
+		t.Fatalf("ExecuteFullUpload failed: %v", err)
+	}
+	if stats.UploadedFiles != 1 || stats.FailedFiles != 1 {
+		t.Fatalf("Expected one uploaded and one failed file, got %+v", stats)
+	}
+
+	// 上传失败的文件不写入远程索引，下次全量上传时重新上传
+	indexManager := NewIndexManager(u.clients[proj.Name], &proj.COSConfig, u.logger)
+	remoteIdx, err := indexManager.DownloadRemoteIndex(context.Background(), proj.Name)
+	if err != nil {
+		t.Fatalf("DownloadRemoteIndex failed: %v", err)
+	}
+	if remoteIdx.GetEntry("data/a.txt") == nil || remoteIdx.GetEntry("data/b.txt") != nil {
+		t.Errorf("Unexpected remote index: %v", remoteIdx.Files)
+	}
+}
+
+func TestExecuteFullUploadKeepsRemoteIndexOnDownloadError(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644)
+
+	proj := config.ProjectConfig{
+		Name:        "full",
+		Directories: []string{dir},
+		RootIDs:     map[string]string{dir: "data"},
+		COSConfig:   config.COSConfig{PathPrefix: "uploads/"},
+	}
+	fake := newFakeCOS()
+	u := newTestUploader(t, fake, proj)
+
+	remoteIdx := NewFileIndex()
+	remoteIdx.AddEntry("data/old.txt", "hash", 1, "uploads/old.txt")
+	indexManager := NewIndexManager(u.clients[proj.Name], &proj.COSConfig, u.logger)
+	if err := indexManager.UploadRemoteIndex(context.Background(), remoteIdx, proj.Name); err != nil {
+		t.Fatalf("UploadRemoteIndex failed: %v", err)
+	}
+
+	// 远程索引下载失败时返回错误，全量上传仍上传文件，但不覆盖远程索引
+	fake.failGet = "uploads/.cos-uploader/full/remote_index.json"
+	if _, err := indexManager.DownloadRemoteIndex(context.Background(), proj.Name); err == nil {
+		t.Fatal("Expected download error")
+	}
+	stats, err := u.ExecuteFullUpload(proj.Name, false)
+	if err != nil {
+		t.Fatalf("ExecuteFullUpload failed: %v", err)
+	}
+	if stats.UploadedFiles != 1 {
+		t.Fatalf("Expected the file to be uploaded, got %+v", stats)
+	}
+
+	fake.failGet = ""
+	remoteIdx, err = indexManager.DownloadRemoteIndex(context.Background(), proj.Name)
+	if err != nil {
+		t.Fatalf("DownloadRemoteIndex failed: %v", err)
+	}
+	if remoteIdx.GetEntry("data/old.txt") == nil {
+		t.Errorf("Remote index overwritten: %v", remoteIdx.Files)
+	}
+}
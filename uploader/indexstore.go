@@ -0,0 +1,536 @@
+package uploader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	indexFilesBucket = []byte("files") // 索引键 -> JSON 编码的 FileEntry
+	indexMetaBucket  = []byte("meta")  // 索引版本、更新时间和扫描代数
+	indexScanBucket  = []byte("scan")  // 索引键 -> 最后一次写入条目时的扫描代数
+)
+
+// ErrIndexStoreBusy 本地索引数据库被其他进程占用
+var ErrIndexStoreBusy = errors.New("local index is in use by another process")
+
+// indexBatchSize 分批读写本地索引时每批的条目数
+const indexBatchSize = 1000
+
+// IndexStore 基于 bbolt 的本地索引存储
+// 条目按索引键单独保存，修改单个文件不需要重写整个索引；支持按键前缀遍历和事务
+type IndexStore struct {
+	db    *bolt.DB
+	path  string
+	roots *IndexRoots // 用于迁移旧版本的索引（可选）
+}
+
+// IndexTx 本地索引的读写事务
+type IndexTx struct {
+	files      *bolt.Bucket
+	scan       *bolt.Bucket
+	generation []byte // 当前的扫描代数，写入条目时一并记录
+}
+
+// newIndexTx 创建 bbolt 事务对应的索引事务
+func newIndexTx(tx *bolt.Tx) *IndexTx {
+	generation := bytes.Clone(tx.Bucket(indexMetaBucket).Get([]byte("generation")))
+	if generation == nil {
+		generation = make([]byte, 8)
+	}
+	return &IndexTx{
+		files:      tx.Bucket(indexFilesBucket),
+		scan:       tx.Bucket(indexScanBucket),
+		generation: generation,
+	}
+}
+
+// GetLocalIndexStorePath 获取本地索引数据库路径
+func GetLocalIndexStorePath(projectName string) string {
+	return filepath.Join(filepath.Dir(GetLocalIndexPath(projectName)), "local_index.db")
+}
+
+// OpenIndexStore 打开或创建本地索引数据库
+// 数据库被其他进程打开时等待 1 秒，仍未释放则返回 ErrIndexStoreBusy
+func OpenIndexStore(path string) (*IndexStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create index directory: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf("%w: %s", ErrIndexStoreBusy, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open index store: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{indexFilesBucket, indexMetaBucket, indexScanBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize index store: %w", err)
+	}
+
+	return &IndexStore{db: db, path: path}, nil
+}
+
+// OpenLocalIndexStore 打开项目的本地索引数据库
+// 数据库不存在而旧版本的 JSON 索引（GetLocalIndexPath）存在时，创建数据库并导入 JSON 索引；
+// 两者都不存在时，create 为 true 则创建空数据库，否则返回 nil。
+// 以本地绝对路径为键的旧版本索引按 roots 转换为当前版本
+func OpenLocalIndexStore(projectName string, roots *IndexRoots, create bool) (*IndexStore, error) {
+	return openLocalIndexStore(GetLocalIndexStorePath(projectName), GetLocalIndexPath(projectName), roots, create)
+}
+
+// openLocalIndexStore 打开 dbPath 的本地索引数据库，必要时从 jsonPath 导入
+func openLocalIndexStore(dbPath, jsonPath string, roots *IndexRoots, create bool) (*IndexStore, error) {
+	if _, err := os.Stat(dbPath); err == nil {
+		store, err := OpenIndexStore(dbPath)
+		if err != nil {
+			return nil, err
+		}
+		store.roots = roots
+		if err := store.migrate(); err != nil {
+			store.Close()
+			return nil, fmt.Errorf("failed to migrate local index: %w", err)
+		}
+		return store, nil
+	}
+
+	_, err := os.Stat(jsonPath)
+	legacy := err == nil
+	if !legacy && !create {
+		return nil, nil
+	}
+
+	store, err := OpenIndexStore(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	store.roots = roots
+	if legacy {
+		if err := store.ImportFile(jsonPath); err != nil {
+			store.Close()
+			os.Remove(dbPath)
+			return nil, fmt.Errorf("failed to migrate local index: %w", err)
+		}
+	}
+	return store, nil
+}
+
+// migrate 将以本地绝对路径为键的旧版本数据库转换为当前版本，没有设置 roots 时不转换
+// 先只读取索引版本，需要转换时才导出全部条目
+func (s *IndexStore) migrate() error {
+	if s.roots == nil {
+		return nil
+	}
+	version, err := s.version()
+	if err != nil || version == "" || version == IndexVersion {
+		return err
+	}
+	idx, err := s.Export()
+	if err != nil {
+		return err
+	}
+	return s.Import(idx)
+}
+
+// version 返回数据库的索引版本，从未写入过条目的空数据库返回空字符串
+func (s *IndexStore) version() (string, error) {
+	var version string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		version = string(tx.Bucket(indexMetaBucket).Get([]byte("version")))
+		return nil
+	})
+	return version, err
+}
+
+// Path 返回数据库文件路径
+func (s *IndexStore) Path() string {
+	return s.path
+}
+
+// Close 关闭数据库
+func (s *IndexStore) Close() error {
+	return s.db.Close()
+}
+
+// Update 在一个读写事务中执行 fn，fn 返回错误时回滚
+func (s *IndexStore) Update(fn func(tx *IndexTx) error) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := fn(newIndexTx(tx)); err != nil {
+			return err
+		}
+		return touchIndexMeta(tx)
+	})
+}
+
+// View 在一个只读事务中执行 fn
+func (s *IndexStore) View(fn func(tx *IndexTx) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return fn(newIndexTx(tx))
+	})
+}
+
+// BeginScan 开始一次全量扫描，返回新的扫描代数
+// 之后写入的条目都记录为该代，扫描结束后用 LocalIndex.Prune 删除没有写入的条目
+func (s *IndexStore) BeginScan() (uint64, error) {
+	var generation uint64
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(indexMetaBucket)
+		if data := meta.Get([]byte("generation")); data != nil {
+			generation = binary.BigEndian.Uint64(data)
+		}
+		generation++
+		return meta.Put([]byte("generation"), binary.BigEndian.AppendUint64(nil, generation))
+	})
+	return generation, err
+}
+
+// Get 读取索引键的条目，不存在时返回 nil
+func (s *IndexStore) Get(key string) (*FileEntry, error) {
+	var entry *FileEntry
+	err := s.View(func(tx *IndexTx) error {
+		var err error
+		entry, err = tx.Get(key)
+		return err
+	})
+	return entry, err
+}
+
+// GetEntry 读取索引键的条目，不存在或读取失败时返回 nil
+func (s *IndexStore) GetEntry(key string) *FileEntry {
+	entry, _ := s.Get(key)
+	return entry
+}
+
+// Put 写入索引键的条目
+func (s *IndexStore) Put(key string, entry *FileEntry) error {
+	return s.Update(func(tx *IndexTx) error {
+		return tx.Put(key, entry)
+	})
+}
+
+// Delete 删除索引键的条目
+func (s *IndexStore) Delete(key string) error {
+	return s.Update(func(tx *IndexTx) error {
+		return tx.Delete(key)
+	})
+}
+
+// ForEach 按键的顺序遍历以 prefix 开头的条目，prefix 为空时遍历全部条目
+// 以 根标识/ 为前缀可以遍历一个监控目录的条目
+func (s *IndexStore) ForEach(prefix string, fn func(key string, entry *FileEntry) error) error {
+	return s.View(func(tx *IndexTx) error {
+		return tx.ForEach(prefix, fn)
+	})
+}
+
+// Import 用 idx 替换数据库中的全部条目
+// 旧版本的索引先按 roots 转换为当前版本（修改 idx），没有设置 roots 时返回错误。
+// 条目按键的顺序分批写入，每批一个事务，避免一个事务占用过多内存；全部写入后才更新索引版本
+func (s *IndexStore) Import(idx *FileIndex) error {
+	if idx.Version != IndexVersion {
+		if s.roots == nil {
+			return fmt.Errorf("index version %s requires project directories to migrate", idx.Version)
+		}
+		s.roots.Migrate(idx)
+	}
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{indexFilesBucket, indexScanBucket} {
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
+			if _, err := tx.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(idx.Files))
+	for key := range idx.Files {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for batch := range slices.Chunk(keys, indexBatchSize) {
+		err := s.db.Update(func(tx *bolt.Tx) error {
+			itx := newIndexTx(tx)
+			for _, key := range batch {
+				if err := itx.Put(key, idx.Files[key]); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.db.Update(touchIndexMeta)
+}
+
+// Export 将数据库中的全部条目导出为 FileIndex
+func (s *IndexStore) Export() (*FileIndex, error) {
+	idx := NewFileIndex()
+	err := s.db.View(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(indexMetaBucket)
+		if version := meta.Get([]byte("version")); version != nil {
+			idx.Version = string(version)
+		}
+		if updated := meta.Get([]byte("timestamp")); updated != nil {
+			idx.Timestamp = string(updated)
+		}
+		return newIndexTx(tx).ForEach("", func(key string, entry *FileEntry) error {
+			idx.Files[key] = entry
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// ImportFile 从 JSON 索引文件导入，替换数据库中的全部条目
+func (s *IndexStore) ImportFile(jsonPath string) error {
+	if _, err := os.Stat(jsonPath); err != nil {
+		return fmt.Errorf("failed to read index file: %w", err)
+	}
+	idx, err := LoadFileIndexFromFile(jsonPath)
+	if err != nil {
+		return err
+	}
+	return s.Import(idx)
+}
+
+// ExportFile 将数据库中的全部条目导出为 JSON 索引文件
+func (s *IndexStore) ExportFile(jsonPath string) error {
+	idx, err := s.Export()
+	if err != nil {
+		return err
+	}
+	return idx.SaveToFile(jsonPath)
+}
+
+// touchIndexMeta 记录索引版本和更新时间
+func touchIndexMeta(tx *bolt.Tx) error {
+	meta := tx.Bucket(indexMetaBucket)
+	if err := meta.Put([]byte("version"), []byte(IndexVersion)); err != nil {
+		return err
+	}
+	return meta.Put([]byte("timestamp"), []byte(time.Now().UTC().Format(time.RFC3339)))
+}
+
+// Get 读取索引键的条目，不存在时返回 nil
+func (tx *IndexTx) Get(key string) (*FileEntry, error) {
+	data := tx.files.Get([]byte(key))
+	if data == nil {
+		return nil, nil
+	}
+	var entry FileEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal index entry %s: %w", key, err)
+	}
+	return &entry, nil
+}
+
+// GetEntry 读取索引键的条目，不存在或读取失败时返回 nil
+func (tx *IndexTx) GetEntry(key string) *FileEntry {
+	entry, _ := tx.Get(key)
+	return entry
+}
+
+// Put 写入索引键的条目，并记录为在当前扫描中出现
+func (tx *IndexTx) Put(key string, entry *FileEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal index entry %s: %w", key, err)
+	}
+	if err := tx.files.Put([]byte(key), data); err != nil {
+		return err
+	}
+	return tx.scan.Put([]byte(key), tx.generation)
+}
+
+// Delete 删除索引键的条目
+func (tx *IndexTx) Delete(key string) error {
+	if err := tx.scan.Delete([]byte(key)); err != nil {
+		return err
+	}
+	return tx.files.Delete([]byte(key))
+}
+
+// keysAfter 按键的顺序返回 after 之后的最多 limit 个索引键，after 为空时从第一个键开始
+func (tx *IndexTx) keysAfter(after string, limit int) []string {
+	var keys []string
+	c := tx.files.Cursor()
+	k, _ := c.Seek([]byte(after))
+	if k != nil && string(k) == after {
+		k, _ = c.Next()
+	}
+	for ; k != nil && len(keys) < limit; k, _ = c.Next() {
+		keys = append(keys, string(k))
+	}
+	return keys
+}
+
+// ForEach 按键的顺序遍历以 prefix 开头的条目
+func (tx *IndexTx) ForEach(prefix string, fn func(key string, entry *FileEntry) error) error {
+	c := tx.files.Cursor()
+	p := []byte(prefix)
+	for k, v := c.Seek(p); k != nil && bytes.HasPrefix(k, p); k, v = c.Next() {
+		var entry FileEntry
+		if err := json.Unmarshal(v, &entry); err != nil {
+			return fmt.Errorf("failed to unmarshal index entry %s: %w", k, err)
+		}
+		if err := fn(string(k), &entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LocalIndex 按需打开的本地索引数据库
+// 每次读写时打开数据库，完成后立即关闭。长时间的扫描按批读写，不会一直占用数据库，
+// 守护进程可以同时记录上传结果
+type LocalIndex struct {
+	path string
+}
+
+// NewLocalIndex 创建按需打开 path 的本地索引
+func NewLocalIndex(path string) *LocalIndex {
+	return &LocalIndex{path: path}
+}
+
+// Path 返回数据库文件路径
+func (l *LocalIndex) Path() string {
+	return l.path
+}
+
+// View 打开数据库，在一个只读事务中执行 fn 后关闭
+func (l *LocalIndex) View(fn func(tx *IndexTx) error) error {
+	store, err := OpenIndexStore(l.path)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	return store.View(fn)
+}
+
+// Update 打开数据库，在一个读写事务中执行 fn 后关闭
+func (l *LocalIndex) Update(fn func(tx *IndexTx) error) error {
+	store, err := OpenIndexStore(l.path)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	return store.Update(fn)
+}
+
+// GetEntry 读取索引键的条目，不存在或读取失败时返回 nil
+func (l *LocalIndex) GetEntry(key string) *FileEntry {
+	var entry *FileEntry
+	l.View(func(tx *IndexTx) error {
+		entry = tx.GetEntry(key)
+		return nil
+	})
+	return entry
+}
+
+// GetEntries 打开一次数据库读取多个索引键的条目，不存在的键不在结果中
+func (l *LocalIndex) GetEntries(keys []string) (map[string]*FileEntry, error) {
+	entries := make(map[string]*FileEntry, len(keys))
+	err := l.View(func(tx *IndexTx) error {
+		for _, key := range keys {
+			entry, err := tx.Get(key)
+			if err != nil {
+				return err
+			}
+			if entry != nil {
+				entries[key] = entry
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// PutEntries 在一个事务中写入多个条目
+func (l *LocalIndex) PutEntries(entries map[string]*FileEntry) error {
+	return l.Update(func(tx *IndexTx) error {
+		for key, entry := range entries {
+			if err := tx.Put(key, entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// BeginScan 打开数据库开始一次全量扫描，返回新的扫描代数
+func (l *LocalIndex) BeginScan() (uint64, error) {
+	store, err := OpenIndexStore(l.path)
+	if err != nil {
+		return 0, err
+	}
+	defer store.Close()
+	return store.BeginScan()
+}
+
+// Prune 分批删除扫描代数 generation 开始后没有写入的条目，返回删除的条目数
+// 扫描期间写入的条目（包括守护进程记录的上传结果）都会保留
+func (l *LocalIndex) Prune(generation uint64) (int, error) {
+	mark := binary.BigEndian.AppendUint64(nil, generation)
+	removed := 0
+	after := ""
+	for {
+		var keys []string
+		stale := 0
+		err := l.Update(func(tx *IndexTx) error {
+			keys = tx.keysAfter(after, indexBatchSize)
+			for _, key := range keys {
+				if bytes.Equal(tx.scan.Get([]byte(key)), mark) {
+					continue
+				}
+				if err := tx.Delete(key); err != nil {
+					return err
+				}
+				stale++
+			}
+			return nil
+		})
+		if err != nil {
+			return removed, err
+		}
+		if len(keys) == 0 {
+			return removed, nil
+		}
+		removed += stale
+		after = keys[len(keys)-1]
+	}
+}
@@ -0,0 +1,278 @@
+package uploader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hmw/cos-uploader/config"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestIndexStore(t *testing.T) {
+	store, err := OpenIndexStore(filepath.Join(t.TempDir(), "index.db"))
+	if err != nil {
+		t.Fatalf("OpenIndexStore failed: %v", err)
+	}
+	defer store.Close()
+
+	for _, path := range []string{"/data/a.txt", "/data/sub/b.txt", "/other/c.txt"} {
+		if err := store.Put(path, &FileEntry{Size: 1, Hash: "hash-" + filepath.Base(path)}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	entry, err := store.Get("/data/a.txt")
+	if err != nil || entry == nil || entry.Hash != "hash-a.txt" {
+		t.Fatalf("Unexpected entry: %+v, %v", entry, err)
+	}
+	if store.GetEntry("/missing") != nil {
+		t.Error("Expected nil for missing entry")
+	}
+
+	var paths []string
+	store.ForEach("/data/", func(localPath string, entry *FileEntry) error {
+		paths = append(paths, localPath)
+		return nil
+	})
+	if len(paths) != 2 || paths[0] != "/data/a.txt" || paths[1] != "/data/sub/b.txt" {
+		t.Errorf("Unexpected prefix iteration: %v", paths)
+	}
+
+	if err := store.Delete("/data/a.txt"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if store.GetEntry("/data/a.txt") != nil {
+		t.Error("Expected entry to be deleted")
+	}
+
+	// 事务出错时所有修改回滚
+	errAbort := errors.New("abort")
+	err = store.Update(func(tx *IndexTx) error {
+		tx.Put("/data/new.txt", &FileEntry{Size: 2})
+		tx.Delete("/other/c.txt")
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("Expected abort error, got %v", err)
+	}
+	if store.GetEntry("/data/new.txt") != nil || store.GetEntry("/other/c.txt") == nil {
+		t.Error("Expected transaction to be rolled back")
+	}
+}
+
+func TestIndexStoreImportExport(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenIndexStore(filepath.Join(dir, "index.db"))
+	if err != nil {
+		t.Fatalf("OpenIndexStore failed: %v", err)
+	}
+	defer store.Close()
+
+	store.Put("/stale.txt", &FileEntry{Size: 1})
+
+	idx := NewFileIndex()
+	idx.AddEntry("/a.txt", "hash1", 100, "prefix/a.txt")
+	idx.AddEntry("/b.txt", "hash2", 200, "prefix/b.txt")
+	jsonPath := filepath.Join(dir, "index.json")
+	if err := idx.SaveToFile(jsonPath); err != nil {
+		t.Fatalf("SaveToFile failed: %v", err)
+	}
+
+	// 导入替换全部条目
+	if err := store.ImportFile(jsonPath); err != nil {
+		t.Fatalf("ImportFile failed: %v", err)
+	}
+	exported, err := store.Export()
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if len(exported.Files) != 2 || exported.Files["/b.txt"].RemotePath != "prefix/b.txt" {
+		t.Errorf("Unexpected exported index: %+v", exported.Files)
+	}
+
+	outPath := filepath.Join(dir, "out.json")
+	if err := store.ExportFile(outPath); err != nil {
+		t.Fatalf("ExportFile failed: %v", err)
+	}
+	loaded, err := LoadFileIndexFromFile(outPath)
+	if err != nil || len(loaded.Files) != 2 || loaded.Files["/a.txt"].Hash != "hash1" {
+		t.Errorf("Unexpected JSON export: %+v, %v", loaded, err)
+	}
+}
+
+func TestOpenLocalIndexStoreMigration(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "local_index.db")
+	jsonPath := filepath.Join(dir, "local_index.json")
+
+	// 两者都不存在时不创建
+	store, err := openLocalIndexStore(dbPath, jsonPath, nil, false)
+	if err != nil || store != nil {
+		t.Fatalf("Expected no store, got %v, %v", store, err)
+	}
+	if _, err := os.Stat(dbPath); !os.IsNotExist(err) {
+		t.Fatal("Database should not be created")
+	}
+
+	idx := NewFileIndex()
+	idx.AddEntry("/a.txt", "hash1", 100, "a.txt")
+	idx.SaveToFile(jsonPath)
+
+	store, err = openLocalIndexStore(dbPath, jsonPath, nil, false)
+	if err != nil || store == nil {
+		t.Fatalf("Expected migrated store, got %v", err)
+	}
+	if entry := store.GetEntry("/a.txt"); entry == nil || entry.Hash != "hash1" {
+		t.Errorf("Unexpected migrated entry: %+v", entry)
+	}
+
+	// 数据库被占用时不会一直等待
+	if _, err := OpenIndexStore(dbPath); !errors.Is(err, ErrIndexStoreBusy) {
+		t.Errorf("Expected busy error, got %v", err)
+	}
+	store.Close()
+
+	// 已有数据库时不再导入 JSON
+	NewFileIndex().SaveToFile(jsonPath)
+	store, err = openLocalIndexStore(dbPath, jsonPath, nil, false)
+	if err != nil {
+		t.Fatalf("openLocalIndexStore failed: %v", err)
+	}
+	defer store.Close()
+	if store.GetEntry("/a.txt") == nil {
+		t.Error("Expected existing database to be kept")
+	}
+}
+
+func TestOpenLocalIndexStoreKeyMigration(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "local_index.db")
+	roots := NewIndexRoots(config.ProjectConfig{
+		Directories: []string{"/data/photos"},
+		COSConfig:   config.COSConfig{PathPrefix: "backup/"},
+	})
+
+	// 旧版本的数据库以绝对路径为键
+	store, err := OpenIndexStore(dbPath)
+	if err != nil {
+		t.Fatalf("OpenIndexStore failed: %v", err)
+	}
+	store.Put("/data/photos/a.jpg", &FileEntry{Size: 1, Hash: "hash1", RemotePath: "backup/a.jpg"})
+	store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(indexMetaBucket).Put([]byte("version"), []byte("1.0"))
+	})
+	store.Close()
+
+	store, err = openLocalIndexStore(dbPath, filepath.Join(dir, "local_index.json"), roots, false)
+	if err != nil {
+		t.Fatalf("openLocalIndexStore failed: %v", err)
+	}
+	defer store.Close()
+
+	idx, err := store.Export()
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if idx.Version != IndexVersion || len(idx.Files) != 1 || idx.GetEntry("photos/a.jpg") == nil {
+		t.Errorf("Local index not migrated: %s %v", idx.Version, idx.Files)
+	}
+
+	// 当前版本的数据库打开时不重写条目
+	store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(indexMetaBucket).Put([]byte("timestamp"), []byte("2020-01-01T00:00:00Z"))
+	})
+	store.Close()
+	store, err = openLocalIndexStore(dbPath, filepath.Join(dir, "local_index.json"), roots, false)
+	if err != nil {
+		t.Fatalf("openLocalIndexStore failed: %v", err)
+	}
+	if idx, _ := store.Export(); idx.Timestamp != "2020-01-01T00:00:00Z" {
+		t.Errorf("Expected current index to be left unchanged, updated at %s", idx.Timestamp)
+	}
+
+	// 没有监控目录时不能导入旧版本的索引
+	legacy := NewFileIndex()
+	legacy.Version = "1.0"
+	if err := (&IndexStore{db: store.db}).Import(legacy); err == nil {
+		t.Error("Expected error importing legacy index without roots")
+	}
+}
+
+func TestLocalIndex(t *testing.T) {
+	local := NewLocalIndex(filepath.Join(t.TempDir(), "index.db"))
+
+	// 条目数超过一批，导入和删除都分批进行
+	entries := make(map[string]*FileEntry)
+	var keys []string
+	for i := range indexBatchSize + 10 {
+		key := fmt.Sprintf("data/%05d.txt", i)
+		entries[key] = &FileEntry{Size: int64(i), Hash: "hash"}
+		keys = append(keys, key)
+	}
+	if err := local.PutEntries(entries); err != nil {
+		t.Fatalf("PutEntries failed: %v", err)
+	}
+
+	got, err := local.GetEntries(append(keys[:2:2], "data/missing.txt"))
+	if err != nil {
+		t.Fatalf("GetEntries failed: %v", err)
+	}
+	if len(got) != 2 || got["data/00001.txt"].Size != 1 {
+		t.Errorf("Unexpected entries: %v", got)
+	}
+	if local.GetEntry("data/missing.txt") != nil {
+		t.Error("Expected nil for missing entry")
+	}
+
+	// 新的扫描中只写入编号为偶数的条目，其余条目删除
+	generation, err := local.BeginScan()
+	if err != nil {
+		t.Fatalf("BeginScan failed: %v", err)
+	}
+	even := make(map[string]*FileEntry)
+	for key, entry := range entries {
+		if entry.Size%2 == 0 {
+			even[key] = entry
+		}
+	}
+	if err := local.PutEntries(even); err != nil {
+		t.Fatalf("PutEntries failed: %v", err)
+	}
+	removed, err := local.Prune(generation)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if want := (indexBatchSize + 10) / 2; removed != want {
+		t.Errorf("Expected %d removed entries, got %d", want, removed)
+	}
+	if local.GetEntry("data/00000.txt") == nil || local.GetEntry("data/01001.txt") != nil {
+		t.Error("Unexpected entries after prune")
+	}
+
+	// 导入替换全部条目
+	idx := NewFileIndex()
+	for _, key := range keys {
+		idx.Files[key] = entries[key]
+	}
+	err = local.Update(func(tx *IndexTx) error {
+		return tx.Put("data/stale.txt", &FileEntry{Size: 1})
+	})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	store, err := OpenIndexStore(local.Path())
+	if err != nil {
+		t.Fatalf("OpenIndexStore failed: %v", err)
+	}
+	defer store.Close()
+	if err := store.Import(idx); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	exported, err := store.Export()
+	if err != nil || len(exported.Files) != len(keys) || exported.Files["data/stale.txt"] != nil {
+		t.Errorf("Unexpected imported index: %d entries, %v", len(exported.Files), err)
+	}
+}
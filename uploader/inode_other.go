@@ -0,0 +1,10 @@
+//go:build !unix
+
+package uploader
+
+import "os"
+
+// fileInode 当前平台不支持获取 inode 号，只按大小和修改时间判断
+func fileInode(info os.FileInfo) uint64 {
+	return 0
+}
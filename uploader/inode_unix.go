@@ -0,0 +1,16 @@
+//go:build unix
+
+package uploader
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileInode 返回文件的 inode 号，用于发现大小和修改时间未变但文件已被替换的情况
+func fileInode(info os.FileInfo) uint64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Ino)
+	}
+	return 0
+}
@@ -0,0 +1,305 @@
+package uploader
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 任务日志记录类型
+const (
+	journalEnqueued  = "enqueued"
+	journalStarted   = "started"
+	journalSucceeded = "succeeded"
+	journalFailed    = "failed"
+)
+
+// defaultCompactThreshold 追加多少条记录后尝试压缩任务日志
+const defaultCompactThreshold = 1000
+
+// journalRecord 任务日志中的一条记录
+type journalRecord struct {
+	Op    string      `json:"op"`
+	ID    string      `json:"id"`
+	Seq   int64       `json:"seq,omitempty"`   // 入队顺序，仅 enqueued 记录
+	Task  *UploadTask `json:"task,omitempty"`  // 任务内容，仅 enqueued 记录
+	Retry int         `json:"retry,omitempty"` // 当前重试次数，仅 started 记录
+	Error string      `json:"error,omitempty"` // 失败原因，仅 failed 记录
+	Time  string      `json:"time"`
+}
+
+// pendingTask 尚未完成的任务
+type pendingTask struct {
+	seq  int64
+	task *UploadTask
+}
+
+// TaskJournal 上传任务的预写日志（追加写入）
+// 记录任务的入队、开始、成功和失败，进程重启后重放未完成的任务
+type TaskJournal struct {
+	path             string
+	file             *os.File
+	mu               sync.Mutex
+	pending          map[string]*pendingTask // task ID -> 未完成的任务
+	seq              int64
+	records          int // 当前日志文件中的记录数
+	compactThreshold int
+}
+
+var taskIDCounter int64
+
+// newTaskID 生成任务ID
+func newTaskID() string {
+	return fmt.Sprintf("%d-%d", time.Now().UnixNano(), atomic.AddInt64(&taskIDCounter, 1))
+}
+
+// GetQueueJournalPath 获取任务日志文件路径
+func GetQueueJournalPath() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		homeDir = "/tmp"
+	}
+	return filepath.Join(homeDir, ".cos-uploader", "queue.journal")
+}
+
+// OpenTaskJournal 打开任务日志，重放已有记录并压缩
+func OpenTaskJournal(path string) (*TaskJournal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create journal directory: %w", err)
+	}
+
+	j := &TaskJournal{
+		path:             path,
+		pending:          make(map[string]*pendingTask),
+		compactThreshold: defaultCompactThreshold,
+	}
+
+	if err := j.replay(); err != nil {
+		return nil, err
+	}
+
+	// 启动时压缩，去掉已完成任务的记录
+	if err := j.rewrite(); err != nil {
+		return nil, err
+	}
+
+	return j, nil
+}
+
+// replay 读取日志文件，恢复未完成的任务
+func (j *TaskJournal) replay() error {
+	file, err := os.Open(j.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var rec journalRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// 进程中断时最后一行可能不完整，跳过
+			continue
+		}
+		j.apply(&rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	return nil
+}
+
+// apply 将一条记录应用到未完成任务集合
+func (j *TaskJournal) apply(rec *journalRecord) {
+	switch rec.Op {
+	case journalEnqueued:
+		if rec.Task == nil {
+			return
+		}
+		// 保存副本，避免与工作协程并发修改同一任务
+		task := *rec.Task
+		task.ID = rec.ID
+		j.pending[rec.ID] = &pendingTask{seq: rec.Seq, task: &task}
+		if rec.Seq > j.seq {
+			j.seq = rec.Seq
+		}
+	case journalStarted:
+		if p, ok := j.pending[rec.ID]; ok {
+			p.task.Retry = rec.Retry
+		}
+	case journalSucceeded, journalFailed:
+		delete(j.pending, rec.ID)
+	}
+}
+
+// Pending 返回按入队顺序排列的未完成任务
+func (j *TaskJournal) Pending() []*UploadTask {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	pending := make([]*pendingTask, 0, len(j.pending))
+	for _, p := range j.pending {
+		pending = append(pending, p)
+	}
+	sort.Slice(pending, func(a, b int) bool {
+		return pending[a].seq < pending[b].seq
+	})
+
+	tasks := make([]*UploadTask, 0, len(pending))
+	for _, p := range pending {
+		task := *p.task
+		tasks = append(tasks, &task)
+	}
+	return tasks
+}
+
+// Enqueued 记录任务入队，任务没有ID时自动分配
+func (j *TaskJournal) Enqueued(task *UploadTask) error {
+	if task.ID == "" {
+		task.ID = newTaskID()
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.seq++
+	rec := &journalRecord{Op: journalEnqueued, ID: task.ID, Seq: j.seq, Task: task}
+	return j.append(rec)
+}
+
+// Started 记录任务开始上传
+func (j *TaskJournal) Started(task *UploadTask) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.append(&journalRecord{Op: journalStarted, ID: task.ID, Retry: task.Retry})
+}
+
+// Succeeded 记录任务上传成功
+func (j *TaskJournal) Succeeded(task *UploadTask) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.append(&journalRecord{Op: journalSucceeded, ID: task.ID})
+}
+
+// Failed 记录任务最终失败（不再重试）
+func (j *TaskJournal) Failed(task *UploadTask, taskErr error) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	rec := &journalRecord{Op: journalFailed, ID: task.ID}
+	if taskErr != nil {
+		rec.Error = taskErr.Error()
+	}
+	return j.append(rec)
+}
+
+// append 追加一条记录，记录数过多时压缩日志（调用者需持有锁）
+func (j *TaskJournal) append(rec *journalRecord) error {
+	if j.file == nil {
+		return fmt.Errorf("journal is closed")
+	}
+
+	rec.Time = time.Now().UTC().Format(time.RFC3339)
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal journal record: %w", err)
+	}
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+
+	j.apply(rec)
+	j.records++
+
+	if j.records >= j.compactThreshold && j.records >= 2*len(j.pending) {
+		return j.rewrite()
+	}
+	return nil
+}
+
+// Compact 压缩任务日志，只保留未完成任务的入队记录
+func (j *TaskJournal) Compact() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.rewrite()
+}
+
+// rewrite 将未完成任务写入临时文件并替换原日志（调用者需持有锁）
+func (j *TaskJournal) rewrite() error {
+	tmpPath := j.path + ".tmp"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create journal: %w", err)
+	}
+
+	pending := make([]*pendingTask, 0, len(j.pending))
+	for _, p := range j.pending {
+		pending = append(pending, p)
+	}
+	sort.Slice(pending, func(a, b int) bool {
+		return pending[a].seq < pending[b].seq
+	})
+
+	writer := bufio.NewWriter(tmp)
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, p := range pending {
+		data, err := json.Marshal(&journalRecord{Op: journalEnqueued, ID: p.task.ID, Seq: p.seq, Task: p.task, Time: now})
+		if err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to marshal journal record: %w", err)
+		}
+		writer.Write(append(data, '\n'))
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync journal: %w", err)
+	}
+	tmp.Close()
+
+	if j.file != nil {
+		j.file.Close()
+		j.file = nil
+	}
+	if err := os.Rename(tmpPath, j.path); err != nil {
+		return fmt.Errorf("failed to replace journal: %w", err)
+	}
+
+	file, err := os.OpenFile(j.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	j.file = file
+	j.records = len(pending)
+	return nil
+}
+
+// Close 压缩并关闭任务日志
+func (j *TaskJournal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		return nil
+	}
+	if err := j.rewrite(); err != nil {
+		return err
+	}
+	err := j.file.Close()
+	j.file = nil
+	return err
+}
@@ -0,0 +1,153 @@
+package uploader
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTaskJournalReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.journal")
+
+	journal, err := OpenTaskJournal(path)
+	if err != nil {
+		t.Fatalf("OpenTaskJournal failed: %v", err)
+	}
+
+	done := &UploadTask{FilePath: "/data/done.txt", RemotePath: "done.txt", ProjectName: "p"}
+	failed := &UploadTask{FilePath: "/data/failed.txt", RemotePath: "failed.txt", ProjectName: "p"}
+	running := &UploadTask{FilePath: "/data/running.txt", RemotePath: "running.txt", ProjectName: "p"}
+	queued := &UploadTask{FilePath: "/data/queued.txt", RemotePath: "queued.txt", ProjectName: "p"}
+
+	for _, task := range []*UploadTask{done, failed, running, queued} {
+		if err := journal.Enqueued(task); err != nil {
+			t.Fatalf("Enqueued failed: %v", err)
+		}
+		if task.ID == "" {
+			t.Fatal("Enqueued should assign a task ID")
+		}
+	}
+
+	journal.Started(done)
+	journal.Succeeded(done)
+	journal.Started(failed)
+	journal.Failed(failed, errors.New("boom"))
+	running.Retry = 2
+	journal.Started(running)
+
+	// 模拟进程中断：不调用 Close，直接重新打开
+	reopened, err := OpenTaskJournal(path)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer reopened.Close()
+
+	pending := reopened.Pending()
+	if len(pending) != 2 {
+		t.Fatalf("Expected 2 pending tasks, got %d", len(pending))
+	}
+	if pending[0].FilePath != running.FilePath || pending[1].FilePath != queued.FilePath {
+		t.Errorf("Pending tasks not in enqueue order: %s, %s", pending[0].FilePath, pending[1].FilePath)
+	}
+	if pending[0].Retry != 2 {
+		t.Errorf("Expected retry count 2 to be restored, got %d", pending[0].Retry)
+	}
+	if pending[0].ID != running.ID {
+		t.Errorf("Expected task ID %s, got %s", running.ID, pending[0].ID)
+	}
+}
+
+func TestTaskJournalSkipsTruncatedRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.journal")
+
+	journal, err := OpenTaskJournal(path)
+	if err != nil {
+		t.Fatalf("OpenTaskJournal failed: %v", err)
+	}
+	journal.Enqueued(&UploadTask{FilePath: "/data/a.txt", ProjectName: "p"})
+
+	// 追加一条不完整的记录
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	f.WriteString(`{"op":"enqueued","id":"x","task":{"file_pa`)
+	f.Close()
+
+	reopened, err := OpenTaskJournal(path)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer reopened.Close()
+
+	if got := len(reopened.Pending()); got != 1 {
+		t.Errorf("Expected 1 pending task, got %d", got)
+	}
+}
+
+func TestTaskJournalCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.journal")
+
+	journal, err := OpenTaskJournal(path)
+	if err != nil {
+		t.Fatalf("OpenTaskJournal failed: %v", err)
+	}
+	journal.compactThreshold = 10
+
+	keep := &UploadTask{FilePath: "/data/keep.txt", ProjectName: "p"}
+	journal.Enqueued(keep)
+	for i := 0; i < 20; i++ {
+		task := &UploadTask{FilePath: "/data/tmp.txt", ProjectName: "p"}
+		journal.Enqueued(task)
+		journal.Started(task)
+		journal.Succeeded(task)
+	}
+
+	// 达到阈值后自动压缩，日志中只剩未完成任务
+	if journal.records >= 10 {
+		t.Errorf("Expected journal to be compacted, records=%d", journal.records)
+	}
+
+	if err := journal.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read journal: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], "keep.txt") {
+		t.Errorf("Expected only the pending task after compaction, got %q", string(data))
+	}
+}
+
+func TestQueueWithJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.journal")
+	journal, err := OpenTaskJournal(path)
+	if err != nil {
+		t.Fatalf("OpenTaskJournal failed: %v", err)
+	}
+
+	queue := NewQueue(10)
+	queue.SetJournal(journal)
+
+	task := &UploadTask{FilePath: "/data/a.txt", ProjectName: "p"}
+	if err := queue.Add(task); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if got := queue.Get(); got.ID == "" {
+		t.Error("Task from journaled queue should have an ID")
+	}
+
+	if got := len(queue.Pending()); got != 1 {
+		t.Errorf("Expected 1 pending task, got %d", got)
+	}
+
+	queue.MarkStarted(task)
+	queue.MarkSucceeded(task)
+	if got := len(queue.Pending()); got != 0 {
+		t.Errorf("Expected no pending tasks after success, got %d", got)
+	}
+
+	queue.Close()
+}
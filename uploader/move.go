@@ -0,0 +1,128 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"hash/crc64"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	cos "github.com/tencentyun/cos-go-sdk-v5"
+)
+
+// moveRemote 文件改名或移动后，内容未变时在 COS 服务端复制旧对象到新路径，
+// 然后删除旧对象，远程索引中的条目由 flushRemoteChanges 批量移动。返回 false 表示无法移动，需要重新上传
+func (u *Uploader) moveRemote(task *UploadTask, fileInfo os.FileInfo) bool {
+	if task.OldRemotePath == "" || task.OldRemotePath == task.RemotePath {
+		return false
+	}
+
+	client, ok := u.clients[task.ProjectName]
+	if !ok {
+		return false
+	}
+	projectConfig := u.configs[task.ProjectName]
+	indexManager := NewIndexManager(client, &projectConfig.COSConfig, u.logger)
+	indexManager.SetRoots(u.roots[task.ProjectName])
+	opts := newMultipartOptions(projectConfig.COSConfig)
+
+	// 大文件按分块复制，每个分块的超时与分块上传相同
+	partSize := opts.partSizeFor(fileInfo.Size())
+	parts := (fileInfo.Size() + partSize - 1) / partSize
+	ctx, cancel := context.WithTimeout(context.Background(), opts.partTimeout*time.Duration(parts+1))
+	defer cancel()
+
+	matched, err := u.sameContent(ctx, client, indexManager, task, fileInfo)
+	if err != nil {
+		u.logger.Warn("Failed to compare moved file with remote object, uploading instead",
+			"file", task.FilePath, "old_remote", task.OldRemotePath, "error", err)
+		return false
+	}
+	if !matched {
+		u.logger.Info("Moved file content changed, uploading instead", "file", task.FilePath, "old_remote", task.OldRemotePath)
+		return false
+	}
+
+	sourceURL := fmt.Sprintf("%s/%s", client.BaseURL.BucketURL.Host, task.OldRemotePath)
+	copyOpts := &cos.MultiCopyOptions{
+		PartSize:       partSize / (1024 * 1024),
+		ThreadPoolSize: opts.concurrency,
+	}
+	if _, _, err := client.Object.MultiCopy(ctx, task.RemotePath, sourceURL, copyOpts); err != nil {
+		u.logger.Warn("Failed to copy remote object, uploading instead",
+			"file", task.FilePath, "old_remote", task.OldRemotePath, "remote", task.RemotePath, "error", err)
+		return false
+	}
+
+	// 旧路径在复制期间重新出现（如改名后又改回或复制了一份）时保留旧对象和索引条目
+	if _, err := os.Lstat(task.OldPath); err == nil {
+		u.logger.Info("Old file exists again, keeping old remote object", "file", task.OldPath, "old_remote", task.OldRemotePath)
+		return true
+	}
+
+	oldKey, oldOK := u.indexKey(task.ProjectName, task.OldPath)
+	newKey, newOK := u.indexKey(task.ProjectName, task.FilePath)
+	if oldOK && newOK {
+		u.queueRemoteChange(task.ProjectName, RemoteIndexChange{Key: oldKey, NewKey: newKey, RemotePath: task.RemotePath})
+	}
+
+	if _, err := client.Object.Delete(ctx, task.OldRemotePath); err != nil {
+		u.logger.Warn("Failed to delete old remote object", "old_remote", task.OldRemotePath, "error", err)
+	}
+	u.forgetLocalEntry(&UploadTask{FilePath: task.OldPath, ProjectName: task.ProjectName})
+
+	u.logger.Info("File moved on COS", "file", task.FilePath, "old_remote", task.OldRemotePath, "remote", task.RemotePath)
+	return true
+}
+
+// sameContent 判断本地文件与旧对象内容是否相同
+// 优先比较 COS 记录的 CRC64，对象没有 CRC64 时按远程索引中旧路径条目的算法比较哈希
+// （远程索引可能落后于 COS 中的对象，只作为后备，使用缓存的远程索引）
+func (u *Uploader) sameContent(ctx context.Context, client *cos.Client, indexManager *IndexManager, task *UploadTask, fileInfo os.FileInfo) (bool, error) {
+	resp, err := client.Object.Head(ctx, task.OldRemotePath, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to head old object: %w", err)
+	}
+	if resp.ContentLength != fileInfo.Size() {
+		return false, nil
+	}
+	if remoteCRC := resp.Header.Get("x-cos-hash-crc64ecma"); remoteCRC != "" {
+		localCRC, err := computeCRC64(task.FilePath)
+		if err != nil {
+			return false, err
+		}
+		return remoteCRC == strconv.FormatUint(localCRC, 10), nil
+	}
+
+	remoteIdx, err := u.cachedRemoteIndexFor(ctx, indexManager, task.ProjectName)
+	if err != nil {
+		return false, err
+	}
+	oldKey, _ := u.indexKey(task.ProjectName, task.OldPath)
+	entry := remoteIdx.GetEntry(oldKey)
+	if entry == nil || entry.Hash == "" || entry.Size != fileInfo.Size() {
+		return false, nil
+	}
+	hash, _, err := NewFileHasher().ComputeHashWith(task.FilePath, entry.Algorithm())
+	if err != nil {
+		return false, err
+	}
+	return hash == entry.Hash, nil
+}
+
+// computeCRC64 计算文件的 CRC64（ECMA），与 COS 返回的 x-cos-hash-crc64ecma 一致
+func computeCRC64(filePath string) (uint64, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	hash := crc64.New(crc64.MakeTable(crc64.ECMA))
+	if _, err := io.Copy(hash, file); err != nil {
+		return 0, fmt.Errorf("failed to read file: %w", err)
+	}
+	return hash.Sum64(), nil
+}
@@ -0,0 +1,207 @@
+package uploader
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hmw/cos-uploader/config"
+)
+
+// newMoveTest 创建旧对象已上传、本地文件已移动到新路径的上传器
+func newMoveTest(t *testing.T, indexed bool) (*Uploader, *fakeCOS, *UploadTask) {
+	t.Helper()
+
+	fake := newFakeCOS()
+	dir := t.TempDir()
+	proj := config.ProjectConfig{
+		Name:        "move",
+		Directories: []string{dir},
+		RootIDs:     map[string]string{dir: "data"},
+		COSConfig:   config.COSConfig{PathPrefix: "uploads/"},
+	}
+	u := newTestUploader(t, fake, proj)
+
+	task := &UploadTask{
+		FilePath:      filepath.Join(dir, "new", "a.txt"),
+		RemotePath:    "uploads/new/a.txt",
+		ProjectName:   proj.Name,
+		OldPath:       filepath.Join(dir, "old", "a.txt"),
+		OldRemotePath: "uploads/old/a.txt",
+	}
+	os.MkdirAll(filepath.Dir(task.FilePath), 0755)
+	os.WriteFile(task.FilePath, []byte("file content"), 0644)
+	fake.objects[task.OldRemotePath] = []byte("file content")
+
+	if indexed {
+		hash, size, _ := NewFileHasher().ComputeMD5(task.FilePath)
+		remoteIdx := NewFileIndex()
+		remoteIdx.AddEntry("data/old/a.txt", hash, size, task.OldRemotePath)
+		indexManager := NewIndexManager(u.clients[proj.Name], &proj.COSConfig, u.logger)
+		if err := indexManager.UploadRemoteIndex(context.Background(), remoteIdx, proj.Name); err != nil {
+			t.Fatalf("UploadRemoteIndex failed: %v", err)
+		}
+	}
+	return u, fake, task
+}
+
+func TestMoveRemote(t *testing.T) {
+	u, fake, task := newMoveTest(t, true)
+
+	if _, err := u.uploadFile(task); err != nil {
+		t.Fatalf("uploadFile failed: %v", err)
+	}
+	u.flushRemoteChanges()
+
+	if string(fake.objects[task.RemotePath]) != "file content" {
+		t.Errorf("Expected object copied to new key, got %q", fake.objects[task.RemotePath])
+	}
+	if _, ok := fake.objects[task.OldRemotePath]; ok {
+		t.Error("Expected old object to be deleted")
+	}
+
+	indexManager := NewIndexManager(u.clients[task.ProjectName], &config.COSConfig{PathPrefix: "uploads/"}, u.logger)
+	remoteIdx, _ := indexManager.DownloadRemoteIndex(context.Background(), task.ProjectName)
+	if remoteIdx.GetEntry("data/old/a.txt") != nil {
+		t.Error("Expected old path removed from remote index")
+	}
+	if entry := remoteIdx.GetEntry("data/new/a.txt"); entry == nil || entry.RemotePath != task.RemotePath {
+		t.Errorf("Expected new path in remote index, got %+v", entry)
+	}
+}
+
+func TestMoveRemoteWithoutIndexEntry(t *testing.T) {
+	u, fake, task := newMoveTest(t, false)
+
+	info, _ := os.Stat(task.FilePath)
+	if !u.moveRemote(task, info) {
+		t.Fatal("Expected move verified by CRC64 to succeed")
+	}
+	if _, ok := fake.objects[task.OldRemotePath]; ok {
+		t.Error("Expected old object to be deleted")
+	}
+}
+
+func TestMoveRemoteContentChanged(t *testing.T) {
+	u, fake, task := newMoveTest(t, true)
+	os.WriteFile(task.FilePath, []byte("changed content"), 0644)
+
+	if _, err := u.uploadFile(task); err != nil {
+		t.Fatalf("uploadFile failed: %v", err)
+	}
+
+	// 内容变化时重新上传，旧对象保留
+	if string(fake.objects[task.RemotePath]) != "changed content" {
+		t.Errorf("Expected new content uploaded, got %q", fake.objects[task.RemotePath])
+	}
+	if _, ok := fake.objects[task.OldRemotePath]; !ok {
+		t.Error("Expected old object to be kept")
+	}
+}
+
+func TestMoveRemoteStaleIndexEntry(t *testing.T) {
+	u, _, task := newMoveTest(t, false)
+
+	// 远程索引中的哈希已过期，以 COS 记录的 CRC64 为准
+	remoteIdx := NewFileIndex()
+	remoteIdx.AddEntry("data/old/a.txt", "stale", 12, task.OldRemotePath)
+	indexManager := NewIndexManager(u.clients[task.ProjectName], &config.COSConfig{PathPrefix: "uploads/"}, u.logger)
+	if err := indexManager.UploadRemoteIndex(context.Background(), remoteIdx, task.ProjectName); err != nil {
+		t.Fatalf("UploadRemoteIndex failed: %v", err)
+	}
+
+	info, _ := os.Stat(task.FilePath)
+	if !u.moveRemote(task, info) {
+		t.Error("Expected move verified by CRC64 to succeed")
+	}
+}
+
+func TestMoveRemoteOldPathReappeared(t *testing.T) {
+	u, fake, task := newMoveTest(t, true)
+	os.MkdirAll(filepath.Dir(task.OldPath), 0755)
+	os.WriteFile(task.OldPath, []byte("file content"), 0644)
+
+	info, _ := os.Stat(task.FilePath)
+	if !u.moveRemote(task, info) {
+		t.Fatal("Expected move to succeed")
+	}
+
+	// 旧路径又出现了文件，旧对象和索引条目保留
+	if _, ok := fake.objects[task.OldRemotePath]; !ok {
+		t.Error("Expected old object to be kept")
+	}
+	if string(fake.objects[task.RemotePath]) != "file content" {
+		t.Errorf("Expected object copied to new key, got %q", fake.objects[task.RemotePath])
+	}
+	u.flushRemoteChanges()
+	indexManager := NewIndexManager(u.clients[task.ProjectName], &config.COSConfig{PathPrefix: "uploads/"}, u.logger)
+	remoteIdx, _ := indexManager.DownloadRemoteIndex(context.Background(), task.ProjectName)
+	if remoteIdx.GetEntry("data/old/a.txt") == nil {
+		t.Error("Expected old path kept in remote index")
+	}
+}
+
+func TestMoveRemoteBatchesIndexUpdates(t *testing.T) {
+	u, fake, task := newMoveTest(t, false)
+	dir := filepath.Dir(filepath.Dir(task.FilePath))
+
+	// 改名整个目录时，旧对象没有 CRC64，按远程索引比较内容
+	fake.noCRC = true
+	remoteIdx := NewFileIndex()
+	var tasks []*UploadTask
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		moved := &UploadTask{
+			FilePath:      filepath.Join(dir, "new", name),
+			RemotePath:    "uploads/new/" + name,
+			ProjectName:   task.ProjectName,
+			OldPath:       filepath.Join(dir, "old", name),
+			OldRemotePath: "uploads/old/" + name,
+		}
+		os.WriteFile(moved.FilePath, []byte("file content"), 0644)
+		fake.objects[moved.OldRemotePath] = []byte("file content")
+		hash, size, _ := NewFileHasher().ComputeMD5(moved.FilePath)
+		remoteIdx.AddEntry("data/old/"+name, hash, size, moved.OldRemotePath)
+		tasks = append(tasks, moved)
+	}
+	indexManager := NewIndexManager(u.clients[task.ProjectName], &config.COSConfig{PathPrefix: "uploads/"}, u.logger)
+	if err := indexManager.UploadRemoteIndex(context.Background(), remoteIdx, task.ProjectName); err != nil {
+		t.Fatalf("UploadRemoteIndex failed: %v", err)
+	}
+
+	var downloads, uploads int
+	fake.onRequest = func(r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/remote_index.json") {
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			downloads++
+		case http.MethodPut:
+			uploads++
+		}
+	}
+
+	for _, moved := range tasks {
+		info, _ := os.Stat(moved.FilePath)
+		if !u.moveRemote(moved, info) {
+			t.Fatalf("Expected move of %s to succeed", moved.FilePath)
+		}
+	}
+	u.flushRemoteChanges()
+
+	// 比较内容时复用缓存的远程索引，条目在 flush 时一次移动
+	if downloads != 2 || uploads != 1 {
+		t.Errorf("Expected 2 downloads and 1 upload of the remote index, got %d and %d", downloads, uploads)
+	}
+	fake.onRequest = nil
+	remoteIdx, err := indexManager.DownloadRemoteIndex(context.Background(), task.ProjectName)
+	if err != nil {
+		t.Fatalf("DownloadRemoteIndex failed: %v", err)
+	}
+	if len(remoteIdx.Files) != 3 || remoteIdx.GetEntry("data/new/b.txt") == nil {
+		t.Errorf("Unexpected remote index entries: %v", remoteIdx.Files)
+	}
+}
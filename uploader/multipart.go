@@ -0,0 +1,313 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hmw/cos-uploader/config"
+	cos "github.com/tencentyun/cos-go-sdk-v5"
+)
+
+const (
+	minPartSize  = 1024 * 1024 // COS 要求分块最小 1MB（最后一块除外）
+	maxPartCount = 10000       // COS 单次分块上传最多 10000 块
+)
+
+// multipartOptions 分块上传参数
+type multipartOptions struct {
+	threshold   int64         // 分块上传阈值（字节）
+	partSize    int64         // 分块大小（字节）
+	concurrency int           // 分块并发数
+	partTimeout time.Duration // 单个分块超时
+}
+
+// newMultipartOptions 根据COS配置生成分块上传参数，未配置的项使用默认值
+func newMultipartOptions(cosConfig config.COSConfig) multipartOptions {
+	opts := multipartOptions{
+		threshold:   64 * 1024 * 1024,
+		partSize:    16 * 1024 * 1024,
+		concurrency: 4,
+		partTimeout: 120 * time.Second,
+	}
+	if cosConfig.MultipartThresholdMB > 0 {
+		opts.threshold = int64(cosConfig.MultipartThresholdMB) * 1024 * 1024
+	}
+	if cosConfig.PartSizeMB > 0 {
+		opts.partSize = int64(cosConfig.PartSizeMB) * 1024 * 1024
+	}
+	if cosConfig.PartConcurrency > 0 {
+		opts.concurrency = cosConfig.PartConcurrency
+	}
+	if cosConfig.PartTimeout > 0 {
+		opts.partTimeout = time.Duration(cosConfig.PartTimeout) * time.Second
+	}
+	return opts
+}
+
+// partSizeFor 计算文件实际使用的分块大小，保证分块数不超过 COS 上限
+func (o multipartOptions) partSizeFor(fileSize int64) int64 {
+	partSize := o.partSize
+	if partSize < minPartSize {
+		partSize = minPartSize
+	}
+	for (fileSize+partSize-1)/partSize > maxPartCount {
+		partSize *= 2
+	}
+	return partSize
+}
+
+// multipartUpload 使用分块上传将文件上传到COS
+// 分块并发上传，每个分块单独计时。已完成的分块记录在断点文件中，
+// 上传失败或进程重启后从最后完成的分块继续；源文件变化时重新开始
+func (u *Uploader) multipartUpload(client *cos.Client, task *UploadTask, file *os.File, info os.FileInfo, opts multipartOptions) error {
+	partSize := opts.partSizeFor(info.Size())
+	cpPath := GetCheckpointPath(task.ProjectName, task.RemotePath)
+
+	cp := u.resumeCheckpoint(client, task, cpPath, info, partSize, opts.partTimeout)
+	if cp == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), opts.partTimeout)
+		result, _, err := client.Object.InitiateMultipartUpload(ctx, task.RemotePath, nil)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to initiate multipart upload: %w", err)
+		}
+
+		cp = &multipartCheckpoint{
+			FilePath:   task.FilePath,
+			RemotePath: task.RemotePath,
+			UploadID:   result.UploadID,
+			FileSize:   info.Size(),
+			ModTime:    info.ModTime().UnixNano(),
+			PartSize:   partSize,
+		}
+		if err := cp.save(cpPath); err != nil {
+			u.logger.Warn("Failed to save multipart checkpoint", "file", task.FilePath, "error", err)
+		}
+
+		u.logger.Info("Multipart upload started",
+			"file", task.FilePath,
+			"remote", task.RemotePath,
+			"upload_id", cp.UploadID,
+			"parts", cp.partCount(),
+			"part_size", FormatBytes(partSize))
+	} else {
+		u.logger.Info("Resuming multipart upload",
+			"file", task.FilePath,
+			"remote", task.RemotePath,
+			"upload_id", cp.UploadID,
+			"finished_parts", len(cp.Parts),
+			"parts", cp.partCount())
+	}
+
+	// 分块失败时保留断点，重试时继续上传
+	if err := u.uploadParts(client, task, cp, cpPath, file, opts); err != nil {
+		return err
+	}
+
+	// 上传过程中文件被修改，已上传的分块不再可用
+	if current, err := os.Stat(task.FilePath); err != nil || !cp.matches(task.FilePath, current, partSize) {
+		u.abortMultipartUpload(client, task, cp.UploadID, opts.partTimeout)
+		removeCheckpoint(cpPath)
+		return fmt.Errorf("file changed during multipart upload: %s", task.FilePath)
+	}
+
+	parts := make([]cos.Object, 0, len(cp.Parts))
+	for _, p := range cp.sortedParts() {
+		parts = append(parts, cos.Object{PartNumber: p.PartNumber, ETag: p.ETag})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.partTimeout)
+	_, _, err := client.Object.CompleteMultipartUpload(ctx, task.RemotePath, cp.UploadID, &cos.CompleteMultipartUploadOptions{
+		Parts: parts,
+	})
+	cancel()
+	if err != nil {
+		u.abortMultipartUpload(client, task, cp.UploadID, opts.partTimeout)
+		removeCheckpoint(cpPath)
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+
+	removeCheckpoint(cpPath)
+	return nil
+}
+
+// resumeCheckpoint 加载可继续使用的断点
+// 源文件已变化或COS上的分块上传已不存在时，清理旧断点并返回 nil
+func (u *Uploader) resumeCheckpoint(
+	client *cos.Client,
+	task *UploadTask,
+	cpPath string,
+	info os.FileInfo,
+	partSize int64,
+	timeout time.Duration,
+) *multipartCheckpoint {
+	cp, err := loadCheckpoint(cpPath)
+	if err != nil {
+		u.logger.Warn("Invalid multipart checkpoint, starting over", "file", task.FilePath, "error", err)
+		removeCheckpoint(cpPath)
+		return nil
+	}
+	if cp == nil {
+		return nil
+	}
+
+	if !cp.matches(task.FilePath, info, partSize) {
+		u.logger.Info("Source file changed since last attempt, restarting multipart upload", "file", task.FilePath)
+		u.abortMultipartUpload(client, task, cp.UploadID, timeout)
+		removeCheckpoint(cpPath)
+		return nil
+	}
+
+	// 确认分块上传在COS上仍然存在
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if _, _, err := client.Object.ListParts(ctx, task.RemotePath, cp.UploadID, nil); err != nil {
+		u.logger.Warn("Multipart upload no longer available, starting over",
+			"file", task.FilePath,
+			"upload_id", cp.UploadID,
+			"error", err)
+		removeCheckpoint(cpPath)
+		return nil
+	}
+
+	return cp
+}
+
+// uploadParts 并发上传断点中尚未完成的分块，每完成一个分块即更新断点文件
+func (u *Uploader) uploadParts(
+	client *cos.Client,
+	task *UploadTask,
+	cp *multipartCheckpoint,
+	cpPath string,
+	file *os.File,
+	opts multipartOptions,
+) error {
+	partCount := cp.partCount()
+	finished := make(map[int]bool, len(cp.Parts))
+	for _, p := range cp.Parts {
+		finished[p.PartNumber] = true
+	}
+
+	// 任一分块失败时通过 ctx 通知其他分块停止
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	partNumbers := make(chan int)
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+
+	workers := opts.concurrency
+	if remaining := partCount - len(finished); workers > remaining {
+		workers = remaining
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for partNumber := range partNumbers {
+				offset := int64(partNumber-1) * cp.PartSize
+				length := cp.PartSize
+				if offset+length > cp.FileSize {
+					length = cp.FileSize - offset
+				}
+
+				etag, err := uploadPart(ctx, client, task.RemotePath, cp.UploadID, partNumber,
+					io.NewSectionReader(file, offset, length), length, opts.partTimeout)
+
+				mu.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = fmt.Errorf("failed to upload part %d: %w", partNumber, err)
+						cancel()
+					}
+				} else {
+					cp.addPart(partNumber, etag)
+					if err := cp.save(cpPath); err != nil {
+						u.logger.Warn("Failed to save multipart checkpoint", "file", task.FilePath, "error", err)
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for partNumber := 1; partNumber <= partCount; partNumber++ {
+		if finished[partNumber] {
+			continue
+		}
+		select {
+		case partNumbers <- partNumber:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(partNumbers)
+	wg.Wait()
+
+	return firstErr
+}
+
+// uploadPart 上传单个分块，返回分块的 ETag
+func uploadPart(
+	parent context.Context,
+	client *cos.Client,
+	remotePath string,
+	uploadID string,
+	partNumber int,
+	r io.Reader,
+	length int64,
+	timeout time.Duration,
+) (string, error) {
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	resp, err := client.Object.UploadPart(ctx, remotePath, uploadID, partNumber, r, &cos.ObjectUploadPartOptions{
+		ContentLength: length,
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.Header.Get("ETag"), nil
+}
+
+// discardMultipartUpload 放弃文件未完成的分块上传：中止COS上的上传并删除断点
+// 在任务最终失败、不再重试时调用
+func (u *Uploader) discardMultipartUpload(task *UploadTask) {
+	cpPath := GetCheckpointPath(task.ProjectName, task.RemotePath)
+	cp, err := loadCheckpoint(cpPath)
+	if err != nil || cp == nil {
+		removeCheckpoint(cpPath)
+		return
+	}
+
+	client, ok := u.clients[task.ProjectName]
+	if ok {
+		opts := newMultipartOptions(u.configs[task.ProjectName].COSConfig)
+		u.abortMultipartUpload(client, task, cp.UploadID, opts.partTimeout)
+	}
+	removeCheckpoint(cpPath)
+}
+
+// abortMultipartUpload 中止分块上传，释放COS上已上传的分块
+func (u *Uploader) abortMultipartUpload(client *cos.Client, task *UploadTask, uploadID string, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if _, err := client.Object.AbortMultipartUpload(ctx, task.RemotePath, uploadID); err != nil {
+		u.logger.Warn("Failed to abort multipart upload",
+			"file", task.FilePath,
+			"upload_id", uploadID,
+			"error", err)
+		return
+	}
+	u.logger.Info("Multipart upload aborted", "file", task.FilePath, "upload_id", uploadID)
+}
@@ -0,0 +1,419 @@
+package uploader
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"hash/crc64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hmw/cos-uploader/config"
+	"github.com/hmw/cos-uploader/logger"
+	cos "github.com/tencentyun/cos-go-sdk-v5"
+)
+
+// fakeCOS 模拟COS服务，支持简单上传和分块上传
+type fakeCOS struct {
+	mu        sync.Mutex
+	objects   map[string][]byte
+	headers   map[string]http.Header    // 简单上传的请求头
+	uploads   map[string]map[int][]byte // uploadID -> partNumber -> data
+	aborted   map[string]bool
+	failPart  int    // 上传该编号的分块时返回错误，0 表示不失败
+	failPut   string // 简单上传该对象时返回错误，空表示不失败
+	failGet   string // 下载该对象时返回错误，空表示不失败
+	noCRC     bool   // HEAD 不返回 CRC64，模拟没有记录 CRC64 的旧对象
+	partCalls int
+	nextID    int
+	onRequest func(r *http.Request) // 处理请求前调用，nil 表示不调用
+}
+
+func newFakeCOS() *fakeCOS {
+	return &fakeCOS{
+		objects: make(map[string][]byte),
+		headers: make(map[string]http.Header),
+		uploads: make(map[string]map[int][]byte),
+		aborted: make(map[string]bool),
+	}
+}
+
+func (f *fakeCOS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.onRequest != nil {
+		f.onRequest(r)
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	query := r.URL.Query()
+	body, _ := io.ReadAll(r.Body)
+
+	// SDK 会校验上传请求返回的 CRC64
+	if r.Method == http.MethodPut {
+		w.Header().Set("x-cos-hash-crc64ecma", strconv.FormatUint(crc64.Checksum(body, crc64.MakeTable(crc64.ECMA)), 10))
+	}
+
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.nextID++
+		uploadID := fmt.Sprintf("upload-%d", f.nextID)
+		f.uploads[uploadID] = make(map[int][]byte)
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>", key, uploadID)
+
+	case r.Method == http.MethodPut && query.Has("partNumber"):
+		f.partCalls++
+		partNumber, _ := strconv.Atoi(query.Get("partNumber"))
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if partNumber == f.failPart {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		parts[partNumber] = body
+		w.Header().Set("ETag", fmt.Sprintf("\"etag-%d\"", partNumber))
+
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var req struct {
+			Parts []struct {
+				PartNumber int
+				ETag       string
+			} `xml:"Part"`
+		}
+		xml.Unmarshal(body, &req)
+		var buf bytes.Buffer
+		for _, p := range req.Parts {
+			buf.Write(parts[p.PartNumber])
+		}
+		f.objects[key] = buf.Bytes()
+		delete(f.uploads, query.Get("uploadId"))
+		fmt.Fprintf(w, "<CompleteMultipartUploadResult><Key>%s</Key><ETag>\"etag-complete\"</ETag></CompleteMultipartUploadResult>", key)
+
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		f.aborted[query.Get("uploadId")] = true
+		delete(f.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodGet && query.Has("uploadId"):
+		if _, ok := f.uploads[query.Get("uploadId")]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, "<Error><Code>NoSuchUpload</Code></Error>")
+			return
+		}
+		fmt.Fprintf(w, "<ListPartsResult><Key>%s</Key><UploadId>%s</UploadId></ListPartsResult>", key, query.Get("uploadId"))
+
+	case r.Method == http.MethodPut && r.Header.Get("x-cos-copy-source") != "":
+		source := r.Header.Get("x-cos-copy-source")
+		sourceKey, _ := url.PathUnescape(source[strings.Index(source, "/")+1:])
+		data, ok := f.objects[sourceKey]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, "<Error><Code>NoSuchKey</Code></Error>")
+			return
+		}
+		f.objects[key] = data
+		fmt.Fprint(w, "<CopyObjectResult><ETag>\"etag-copy\"</ETag></CopyObjectResult>")
+
+	case r.Method == http.MethodPut && key == f.failPut:
+		w.WriteHeader(http.StatusInternalServerError)
+
+	case r.Method == http.MethodPut:
+		f.objects[key] = body
+		f.headers[key] = r.Header.Clone()
+
+	case r.Method == http.MethodHead:
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		if f.noCRC {
+			break
+		}
+		w.Header().Set("x-cos-hash-crc64ecma", strconv.FormatUint(crc64.Checksum(data, crc64.MakeTable(crc64.ECMA)), 10))
+
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodGet && key == f.failGet:
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, "<Error><Code>AccessDenied</Code></Error>")
+
+	case r.Method == http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, "<Error><Code>NoSuchKey</Code></Error>")
+			return
+		}
+		w.Write(data)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// newTestUploader 创建连接到模拟COS服务的上传器
+func newTestUploader(t *testing.T, fake *fakeCOS, proj config.ProjectConfig) *Uploader {
+	t.Helper()
+
+	// 断点文件写入 ~/.cos-uploader，测试中使用临时目录
+	t.Setenv("HOME", t.TempDir())
+
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	bucketURL, _ := url.Parse(server.URL)
+	client := cos.NewClient(&cos.BaseURL{BucketURL: bucketURL}, &http.Client{})
+
+	log := &logger.Logger{}
+	log.SetWriter(io.Discard, io.Discard)
+
+	return &Uploader{
+		clients: map[string]*cos.Client{proj.Name: client},
+		configs: map[string]config.ProjectConfig{proj.Name: proj},
+		queue:   NewQueue(10),
+		logger:  log,
+		done:    make(chan struct{}),
+
+		deadLetters: map[string]*DeadLetterStore{proj.Name: NewDeadLetterStore(GetDeadLetterPath(proj.Name))},
+		notifiers:   make(map[string]FailureNotifier),
+		stats:       map[string]*statsCollector{proj.Name: newStatsCollector(proj.Name)},
+
+		localIndexes:   map[string]*localIndexState{proj.Name: {}},
+		roots:          map[string]*IndexRoots{proj.Name: NewIndexRoots(proj)},
+		deleteLimiters: map[string]*deleteLimiter{proj.Name: newDeleteLimiter(proj.Watcher.DeleteLimit)},
+	}
+}
+
+func TestPartSizeFor(t *testing.T) {
+	opts := multipartOptions{partSize: 1024}
+
+	// 小于最小分块大小时使用 1MB
+	if got := opts.partSizeFor(10 * 1024 * 1024); got != minPartSize {
+		t.Errorf("Expected part size %d, got %d", minPartSize, got)
+	}
+
+	// 分块数超过上限时自动增大分块
+	opts.partSize = minPartSize
+	fileSize := int64(maxPartCount+1) * minPartSize
+	got := opts.partSizeFor(fileSize)
+	if (fileSize+got-1)/got > maxPartCount {
+		t.Errorf("Part size %d produces more than %d parts", got, maxPartCount)
+	}
+}
+
+func TestUploadFile_Multipart(t *testing.T) {
+	fake := newFakeCOS()
+	proj := config.ProjectConfig{
+		Name: "test",
+		COSConfig: config.COSConfig{
+			MultipartThresholdMB: 1,
+			PartSizeMB:           1,
+			PartConcurrency:      3,
+		},
+	}
+	u := newTestUploader(t, fake, proj)
+
+	// 3.5MB 文件，应分为 4 块
+	content := make([]byte, 3*1024*1024+512*1024)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	filePath := filepath.Join(t.TempDir(), "large.bin")
+	if err := os.WriteFile(filePath, content, 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	err := u.UploadFile(&UploadTask{FilePath: filePath, RemotePath: "data/large.bin", ProjectName: "test"})
+	if err != nil {
+		t.Fatalf("UploadFile failed: %v", err)
+	}
+
+	if fake.partCalls != 4 {
+		t.Errorf("Expected 4 part uploads, got %d", fake.partCalls)
+	}
+	if !bytes.Equal(fake.objects["data/large.bin"], content) {
+		t.Error("Uploaded object content does not match local file")
+	}
+}
+
+func TestUploadFile_MultipartResumeAfterFailure(t *testing.T) {
+	fake := newFakeCOS()
+	fake.failPart = 3
+	proj := config.ProjectConfig{
+		Name: "test",
+		COSConfig: config.COSConfig{
+			MultipartThresholdMB: 1,
+			PartSizeMB:           1,
+			PartConcurrency:      1,
+		},
+	}
+	u := newTestUploader(t, fake, proj)
+
+	content := make([]byte, 4*1024*1024)
+	for i := range content {
+		content[i] = byte(i % 253)
+	}
+	filePath := filepath.Join(t.TempDir(), "large.bin")
+	if err := os.WriteFile(filePath, content, 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	task := &UploadTask{FilePath: filePath, RemotePath: "data/large.bin", ProjectName: "test"}
+
+	if err := u.UploadFile(task); err == nil {
+		t.Fatal("Expected error when a part fails, got nil")
+	}
+
+	// 失败后保留断点和COS上的分块上传
+	cp, err := loadCheckpoint(GetCheckpointPath("test", task.RemotePath))
+	if err != nil || cp == nil {
+		t.Fatalf("Expected checkpoint after failure, got %v (err=%v)", cp, err)
+	}
+	if len(cp.Parts) != 2 {
+		t.Errorf("Expected 2 finished parts in checkpoint, got %d", len(cp.Parts))
+	}
+	if len(fake.aborted) != 0 {
+		t.Errorf("Upload should not be aborted on part failure, aborted=%v", fake.aborted)
+	}
+
+	// 重试时只上传剩余分块
+	fake.failPart = 0
+	fake.partCalls = 0
+	if err := u.UploadFile(task); err != nil {
+		t.Fatalf("Resumed upload failed: %v", err)
+	}
+	if fake.partCalls != 2 {
+		t.Errorf("Expected 2 part uploads on resume, got %d", fake.partCalls)
+	}
+	if !bytes.Equal(fake.objects["data/large.bin"], content) {
+		t.Error("Uploaded object content does not match local file")
+	}
+	if _, err := os.Stat(GetCheckpointPath("test", task.RemotePath)); !os.IsNotExist(err) {
+		t.Error("Checkpoint should be removed after successful upload")
+	}
+}
+
+func TestUploadFile_MultipartRestartWhenFileChanged(t *testing.T) {
+	fake := newFakeCOS()
+	fake.failPart = 2
+	proj := config.ProjectConfig{
+		Name: "test",
+		COSConfig: config.COSConfig{
+			MultipartThresholdMB: 1,
+			PartSizeMB:           1,
+			PartConcurrency:      1,
+		},
+	}
+	u := newTestUploader(t, fake, proj)
+
+	filePath := filepath.Join(t.TempDir(), "large.bin")
+	if err := os.WriteFile(filePath, make([]byte, 3*1024*1024), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	task := &UploadTask{FilePath: filePath, RemotePath: "data/large.bin", ProjectName: "test"}
+
+	if err := u.UploadFile(task); err == nil {
+		t.Fatal("Expected error when a part fails, got nil")
+	}
+
+	// 修改源文件后应中止旧的上传并重新开始
+	content := bytes.Repeat([]byte("x"), 3*1024*1024+100)
+	if err := os.WriteFile(filePath, content, 0644); err != nil {
+		t.Fatalf("Failed to modify test file: %v", err)
+	}
+	fake.failPart = 0
+	fake.partCalls = 0
+	if err := u.UploadFile(task); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+
+	if len(fake.aborted) != 1 {
+		t.Errorf("Expected stale upload to be aborted, aborted=%v", fake.aborted)
+	}
+	if fake.partCalls != 4 {
+		t.Errorf("Expected all 4 parts uploaded again, got %d", fake.partCalls)
+	}
+	if !bytes.Equal(fake.objects["data/large.bin"], content) {
+		t.Error("Uploaded object content does not match modified file")
+	}
+}
+
+func TestDiscardMultipartUpload(t *testing.T) {
+	fake := newFakeCOS()
+	fake.failPart = 2
+	proj := config.ProjectConfig{
+		Name: "test",
+		COSConfig: config.COSConfig{
+			MultipartThresholdMB: 1,
+			PartSizeMB:           1,
+			PartConcurrency:      2,
+		},
+	}
+	u := newTestUploader(t, fake, proj)
+
+	filePath := filepath.Join(t.TempDir(), "large.bin")
+	if err := os.WriteFile(filePath, make([]byte, 3*1024*1024), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	task := &UploadTask{FilePath: filePath, RemotePath: "data/large.bin", ProjectName: "test"}
+
+	if err := u.UploadFile(task); err == nil {
+		t.Fatal("Expected error when a part fails, got nil")
+	}
+
+	// 任务最终失败时中止分块上传并删除断点
+	u.discardMultipartUpload(task)
+
+	if len(fake.aborted) != 1 {
+		t.Errorf("Expected multipart upload to be aborted, aborted=%v", fake.aborted)
+	}
+	if _, err := os.Stat(GetCheckpointPath("test", task.RemotePath)); !os.IsNotExist(err) {
+		t.Error("Checkpoint should be removed after discard")
+	}
+	if _, ok := fake.objects["data/large.bin"]; ok {
+		t.Error("Object should not exist after aborted upload")
+	}
+}
+
+func TestUploadFile_SmallFileUsesPut(t *testing.T) {
+	fake := newFakeCOS()
+	proj := config.ProjectConfig{Name: "test"}
+	u := newTestUploader(t, fake, proj)
+
+	filePath := filepath.Join(t.TempDir(), "small.txt")
+	if err := os.WriteFile(filePath, []byte("small"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	err := u.UploadFile(&UploadTask{FilePath: filePath, RemotePath: "data/small.txt", ProjectName: "test"})
+	if err != nil {
+		t.Fatalf("UploadFile failed: %v", err)
+	}
+
+	if fake.partCalls != 0 {
+		t.Errorf("Expected no part uploads for small file, got %d", fake.partCalls)
+	}
+	if string(fake.objects["data/small.txt"]) != "small" {
+		t.Errorf("Unexpected object content: %q", fake.objects["data/small.txt"])
+	}
+}
@@ -0,0 +1,46 @@
+package uploader
+
+import "sync"
+
+// pathLocks 按项目和远程路径串行执行同一对象的上传和删除
+// 工作池不对任务去重，同一文件可能同时有多个任务（如监听事件与启动扫描、任务日志重放），
+// 并发执行时会共用断点文件和分块上传 ID，互相完成或取消对方的分块上传
+type pathLocks struct {
+	mu    sync.Mutex
+	locks map[string]*pathLock
+}
+
+// pathLock 一个远程路径的锁，refs 为持有或等待该锁的任务数
+type pathLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// lock 获取项目中远程路径的锁，返回释放锁的函数
+func (l *pathLocks) lock(projectName, remotePath string) func() {
+	key := projectName + "\x00" + remotePath
+
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*pathLock)
+	}
+	pl, ok := l.locks[key]
+	if !ok {
+		pl = &pathLock{}
+		l.locks[key] = pl
+	}
+	pl.refs++
+	l.mu.Unlock()
+
+	pl.mu.Lock()
+	return func() {
+		pl.mu.Unlock()
+
+		l.mu.Lock()
+		pl.refs--
+		if pl.refs == 0 {
+			delete(l.locks, key)
+		}
+		l.mu.Unlock()
+	}
+}
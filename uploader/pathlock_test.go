@@ -0,0 +1,71 @@
+package uploader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hmw/cos-uploader/config"
+)
+
+func TestPathLocks(t *testing.T) {
+	var locks pathLocks
+
+	unlock := locks.lock("project", "uploads/a.txt")
+
+	// 其他路径和其他项目的同名路径不受影响
+	locks.lock("project", "uploads/b.txt")()
+	locks.lock("other", "uploads/a.txt")()
+
+	acquired := make(chan struct{})
+	go func() {
+		locks.lock("project", "uploads/a.txt")()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("Expected second lock on the same path to wait")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the lock to be released")
+	}
+	if len(locks.locks) != 0 {
+		t.Errorf("Expected released locks to be removed, got %d", len(locks.locks))
+	}
+}
+
+func TestUploadFileWaitsForSamePath(t *testing.T) {
+	fake := newFakeCOS()
+	proj := config.ProjectConfig{Name: "test"}
+	u := newTestUploader(t, fake, proj)
+
+	filePath := filepath.Join(t.TempDir(), "a.txt")
+	os.WriteFile(filePath, []byte("data"), 0644)
+	task := &UploadTask{FilePath: filePath, RemotePath: "data/a.txt", ProjectName: "test"}
+
+	// 同一远程路径的另一个任务正在执行时等待其完成，不共用断点和分块上传
+	unlock := u.pathLocks.lock("test", task.RemotePath)
+	done := make(chan error, 1)
+	go func() { done <- u.UploadFile(task) }()
+	select {
+	case err := <-done:
+		t.Fatalf("Expected upload to wait for the running task, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("UploadFile failed: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for upload")
+	}
+}
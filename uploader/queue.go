@@ -4,16 +4,23 @@ import "sync"
 
 // UploadTask 上传任务
 type UploadTask struct {
-	FilePath    string // 本地文件路径
-	RemotePath  string // 远程COS路径
-	ProjectName string // 项目名称
-	Retry       int    // 重试次数
+	ID          string `json:"id"`           // 任务ID（启用任务日志时分配）
+	FilePath    string `json:"file_path"`    // 本地文件路径
+	RemotePath  string `json:"remote_path"`  // 远程COS路径
+	ProjectName string `json:"project_name"` // 项目名称
+	Retry       int    `json:"retry"`        // 重试次数
+	Op          string `json:"op,omitempty"` // 操作类型：空表示上传，delete 表示删除远程对象
+
+	// 文件由 OldPath 改名或移动而来时，内容相同则在 COS 服务端复制 OldRemotePath 后删除，不重新上传
+	OldPath       string `json:"old_path,omitempty"`
+	OldRemotePath string `json:"old_remote_path,omitempty"`
 }
 
 // Queue 上传任务队列
 type Queue struct {
-	tasks chan *UploadTask
-	mu    sync.RWMutex
+	tasks   chan *UploadTask
+	journal *TaskJournal // 可选的任务日志，为 nil 时任务只保存在内存中
+	mu      sync.RWMutex
 }
 
 // NewQueue 创建新的任务队列
@@ -23,9 +30,45 @@ func NewQueue(bufferSize int) *Queue {
 	}
 }
 
+// SetJournal 为队列设置任务日志
+func (q *Queue) SetJournal(journal *TaskJournal) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.journal = journal
+}
+
+// getJournal 获取任务日志
+func (q *Queue) getJournal() *TaskJournal {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.journal
+}
+
 // Add 添加任务到队列
-func (q *Queue) Add(task *UploadTask) {
+// 启用任务日志时先写入日志再入队，保证进程重启后任务不丢失；
+// 日志写入失败时任务仍会入队，并返回错误
+func (q *Queue) Add(task *UploadTask) error {
+	var err error
+	if journal := q.getJournal(); journal != nil {
+		err = journal.Enqueued(task)
+	}
 	q.tasks <- task
+	return err
+}
+
+// AddUntil 添加任务到队列，队列已满时等待，done 关闭时放弃入队并返回 false
+// 启用任务日志时先写入日志，放弃入队的任务会在下次启动时重放
+func (q *Queue) AddUntil(task *UploadTask, done <-chan struct{}) (bool, error) {
+	var err error
+	if journal := q.getJournal(); journal != nil {
+		err = journal.Enqueued(task)
+	}
+	select {
+	case q.tasks <- task:
+		return true, err
+	case <-done:
+		return false, err
+	}
 }
 
 // Get 从队列获取任务
@@ -38,7 +81,42 @@ func (q *Queue) Tasks() <-chan *UploadTask {
 	return q.tasks
 }
 
+// Pending 返回任务日志中未完成的任务
+func (q *Queue) Pending() []*UploadTask {
+	if journal := q.getJournal(); journal != nil {
+		return journal.Pending()
+	}
+	return nil
+}
+
+// MarkStarted 记录任务开始上传
+func (q *Queue) MarkStarted(task *UploadTask) error {
+	if journal := q.getJournal(); journal != nil {
+		return journal.Started(task)
+	}
+	return nil
+}
+
+// MarkSucceeded 记录任务上传成功
+func (q *Queue) MarkSucceeded(task *UploadTask) error {
+	if journal := q.getJournal(); journal != nil {
+		return journal.Succeeded(task)
+	}
+	return nil
+}
+
+// MarkFailed 记录任务最终失败
+func (q *Queue) MarkFailed(task *UploadTask, err error) error {
+	if journal := q.getJournal(); journal != nil {
+		return journal.Failed(task, err)
+	}
+	return nil
+}
+
 // Close 关闭队列
 func (q *Queue) Close() {
 	close(q.tasks)
+	if journal := q.getJournal(); journal != nil {
+		journal.Close()
+	}
 }
@@ -0,0 +1,119 @@
+package uploader
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// remoteIndexFlushInterval 批量修改远程索引的间隔，也是查询用远程索引缓存的有效期
+// 远程索引是一个整体对象，逐个修改时每次都要下载和上传整个索引
+const remoteIndexFlushInterval = 30 * time.Second
+
+// cachedRemoteIndex 为查询缓存的远程索引
+type cachedRemoteIndex struct {
+	idx       *FileIndex
+	fetchedAt time.Time
+}
+
+// queueRemoteChange 记录远程索引的修改，由 flushRemoteChanges 按顺序批量写入
+func (u *Uploader) queueRemoteChange(projectName string, change RemoteIndexChange) {
+	u.changesMu.Lock()
+	defer u.changesMu.Unlock()
+
+	if u.changes == nil {
+		u.changes = make(map[string][]RemoteIndexChange)
+	}
+	u.changes[projectName] = append(u.changes[projectName], change)
+}
+
+// flushLoop 定期批量修改远程索引，Stop 时最后再写入一次
+func (u *Uploader) flushLoop() {
+	defer u.wg.Done()
+
+	ticker := time.NewTicker(remoteIndexFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-u.done:
+			return
+		case <-ticker.C:
+			u.flushRemoteChanges()
+		}
+	}
+}
+
+// flushRemoteChanges 将记录的删除和移动批量写入远程索引
+// 本地文件已重新出现的删除不执行；失败时保留修改，下次再试
+func (u *Uploader) flushRemoteChanges() {
+	u.changesMu.Lock()
+	pending := u.changes
+	u.changes = nil
+	u.changesMu.Unlock()
+
+	for projectName, changes := range pending {
+		roots := u.roots[projectName]
+		var apply []RemoteIndexChange
+		for _, change := range changes {
+			if change.NewKey == "" {
+				if localPath, ok := roots.LocalPath(change.Key); ok {
+					if _, err := os.Lstat(localPath); err == nil {
+						continue
+					}
+				}
+			}
+			apply = append(apply, change)
+		}
+		if len(apply) == 0 {
+			continue
+		}
+
+		client := u.clients[projectName]
+		projectConfig := u.configs[projectName]
+		indexManager := NewIndexManager(client, &projectConfig.COSConfig, u.logger)
+		indexManager.SetRoots(roots)
+
+		// 远程索引是一个整体对象，串行修改避免并发覆盖
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		u.remoteIndexMu.Lock()
+		applied, err := indexManager.ApplyRemoteChanges(ctx, projectName, apply)
+		u.remoteIndexMu.Unlock()
+		cancel()
+		if err != nil {
+			u.logger.Warn("Failed to update remote index, will retry", "project", projectName, "count", len(apply), "error", err)
+			u.changesMu.Lock()
+			if u.changes == nil {
+				u.changes = make(map[string][]RemoteIndexChange)
+			}
+			u.changes[projectName] = append(apply, u.changes[projectName]...)
+			u.changesMu.Unlock()
+			continue
+		}
+
+		u.indexCacheMu.Lock()
+		delete(u.indexCache, projectName)
+		u.indexCacheMu.Unlock()
+		u.logger.Info("Remote index updated", "project", projectName, "count", applied)
+	}
+}
+
+// cachedRemoteIndexFor 返回项目的远程索引，remoteIndexFlushInterval 内复用上次下载的结果
+// 改名整个目录时每个文件都可能需要查询远程索引，避免逐个下载整个索引。返回的索引只能读取
+func (u *Uploader) cachedRemoteIndexFor(ctx context.Context, indexManager *IndexManager, projectName string) (*FileIndex, error) {
+	u.indexCacheMu.Lock()
+	defer u.indexCacheMu.Unlock()
+
+	if cached, ok := u.indexCache[projectName]; ok && time.Since(cached.fetchedAt) < remoteIndexFlushInterval {
+		return cached.idx, nil
+	}
+	idx, err := indexManager.DownloadRemoteIndex(ctx, projectName)
+	if err != nil {
+		return nil, err
+	}
+	if u.indexCache == nil {
+		u.indexCache = make(map[string]*cachedRemoteIndex)
+	}
+	u.indexCache[projectName] = &cachedRemoteIndex{idx: idx, fetchedAt: time.Now()}
+	return idx, nil
+}
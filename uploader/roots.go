@@ -0,0 +1,122 @@
+package uploader
+
+import (
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/hmw/cos-uploader/config"
+)
+
+// IndexVersion 索引格式版本
+// 1.0 以本地绝对路径为键；2.0 以 根标识/相对路径 为键，与目录挂载的位置无关
+const IndexVersion = "2.0"
+
+// IndexRoots 项目监控目录与根标识的对应关系
+// 索引键为根标识加上相对监控目录的路径（使用 / 分隔），如 photos/2024/a.jpg，
+// 目录挂载到其他位置或数据迁移到其他主机后索引仍然有效
+type IndexRoots struct {
+	dirs   []string // 监控目录
+	ids    []string // 与 dirs 一一对应的根标识
+	prefix string   // COS 路径前缀，迁移旧索引时用于从远程路径还原相对路径
+}
+
+// NewIndexRoots 按项目配置创建监控目录与根标识的对应关系
+func NewIndexRoots(projectConfig config.ProjectConfig) *IndexRoots {
+	r := &IndexRoots{prefix: projectConfig.COSConfig.PathPrefix}
+	for _, dir := range projectConfig.Directories {
+		r.dirs = append(r.dirs, filepath.Clean(dir))
+		r.ids = append(r.ids, projectConfig.RootID(dir))
+	}
+	return r
+}
+
+// Key 返回本地路径的索引键，路径不在任何监控目录下时返回 false
+// 监控目录嵌套时使用最深的目录
+func (r *IndexRoots) Key(localPath string) (string, bool) {
+	localPath = filepath.Clean(localPath)
+	match := -1
+	var relPath string
+	for i, dir := range r.dirs {
+		rel, err := filepath.Rel(dir, localPath)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if match < 0 || len(dir) > len(r.dirs[match]) {
+			match, relPath = i, rel
+		}
+	}
+	if match < 0 {
+		return "", false
+	}
+	return r.ids[match] + "/" + filepath.ToSlash(relPath), true
+}
+
+// LocalPath 返回索引键在本机对应的本地路径，根标识不属于项目时返回 false
+func (r *IndexRoots) LocalPath(key string) (string, bool) {
+	id, relPath, ok := strings.Cut(key, "/")
+	if !ok {
+		return "", false
+	}
+	i := slices.Index(r.ids, id)
+	if i < 0 {
+		return "", false
+	}
+	return filepath.Join(r.dirs[i], filepath.FromSlash(relPath)), true
+}
+
+// Migrate 将旧版本以本地绝对路径为键的索引转换为当前版本，返回无法转换而丢弃的条目数
+// 路径在当前监控目录下的条目直接转换；目录已挂载到其他位置的条目按远程路径还原相对路径，
+// 再按原目录名匹配根标识，项目只有一个监控目录时直接使用该目录
+func (r *IndexRoots) Migrate(idx *FileIndex) int {
+	if idx.Version == IndexVersion {
+		return 0
+	}
+
+	dropped := 0
+	files := make(map[string]*FileEntry, len(idx.Files))
+	for localPath, entry := range idx.Files {
+		key, ok := r.Key(localPath)
+		if !ok {
+			key, ok = r.movedKey(localPath, entry)
+		}
+		if !ok {
+			dropped++
+			continue
+		}
+		files[key] = entry
+	}
+	idx.Files = files
+	idx.Version = IndexVersion
+	return dropped
+}
+
+// movedKey 根据远程路径推断不在当前监控目录下的旧条目的索引键
+func (r *IndexRoots) movedKey(localPath string, entry *FileEntry) (string, bool) {
+	relPath, ok := strings.CutPrefix(entry.RemotePath, r.prefix)
+	if !ok || relPath == "" {
+		return "", false
+	}
+	oldDir, ok := strings.CutSuffix(filepath.ToSlash(localPath), "/"+relPath)
+	if !ok {
+		return "", false
+	}
+
+	if id := path.Base(oldDir); slices.Contains(r.ids, id) {
+		return id + "/" + relPath, true
+	}
+	if len(r.ids) == 1 {
+		return r.ids[0] + "/" + relPath, true
+	}
+	return "", false
+}
+
+// indexKey 返回项目中本地路径的索引键，路径不在项目的监控目录下时返回 false
+func (u *Uploader) indexKey(projectName, localPath string) (string, bool) {
+	roots, ok := u.roots[projectName]
+	if !ok {
+		return "", false
+	}
+	return roots.Key(localPath)
+}
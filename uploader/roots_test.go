@@ -0,0 +1,115 @@
+package uploader
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/hmw/cos-uploader/config"
+)
+
+func TestIndexRoots(t *testing.T) {
+	base := t.TempDir()
+	photos := filepath.Join(base, "photos")
+	nested := filepath.Join(photos, "raw")
+	roots := NewIndexRoots(config.ProjectConfig{
+		Directories: []string{photos, nested},
+		RootIDs:     map[string]string{nested: "raw-photos"},
+	})
+
+	tests := []struct {
+		path string
+		key  string
+	}{
+		{filepath.Join(photos, "2024", "a.jpg"), "photos/2024/a.jpg"},
+		{filepath.Join(nested, "b.cr2"), "raw-photos/b.cr2"}, // 嵌套时使用最深的目录
+		{filepath.Join(base, "photos-old", "c.jpg"), ""},
+		{photos, ""},
+	}
+	for _, tt := range tests {
+		key, ok := roots.Key(tt.path)
+		if ok != (tt.key != "") || key != tt.key {
+			t.Errorf("Key(%s) = %q, %v; want %q", tt.path, key, ok, tt.key)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if path, ok := roots.LocalPath(key); !ok || path != tt.path {
+			t.Errorf("LocalPath(%s) = %q, %v; want %q", key, path, ok, tt.path)
+		}
+	}
+
+	if _, ok := roots.LocalPath("videos/a.mp4"); ok {
+		t.Error("Expected unknown root id to be rejected")
+	}
+}
+
+func TestIndexRootsMigrate(t *testing.T) {
+	roots := NewIndexRoots(config.ProjectConfig{
+		Directories: []string{"/mnt/nas/photos", "/data/docs"},
+		COSConfig:   config.COSConfig{PathPrefix: "backup/"},
+	})
+
+	idx := NewFileIndex()
+	idx.Version = "1.0"
+	idx.AddEntry("/mnt/nas/photos/a.jpg", "hash1", 1, "backup/a.jpg")
+	idx.AddEntry("/old/host/photos/2024/b.jpg", "hash2", 2, "backup/2024/b.jpg") // 目录挂载位置已改变
+	idx.AddEntry("/old/host/music/c.mp3", "hash3", 3, "backup/c.mp3")            // 目录已不再监控
+
+	if dropped := roots.Migrate(idx); dropped != 1 {
+		t.Errorf("Expected 1 dropped entry, got %d", dropped)
+	}
+	if idx.Version != IndexVersion || len(idx.Files) != 2 {
+		t.Fatalf("Unexpected migrated index: version %s, %v", idx.Version, idx.Files)
+	}
+	if idx.GetEntry("photos/a.jpg") == nil || idx.GetEntry("photos/2024/b.jpg") == nil {
+		t.Errorf("Unexpected migrated keys: %v", idx.Files)
+	}
+
+	// 当前版本的索引不修改
+	if dropped := roots.Migrate(idx); dropped != 0 || len(idx.Files) != 2 {
+		t.Errorf("Expected current index to be unchanged, got %v", idx.Files)
+	}
+
+	// 只有一个监控目录时，目录改名后仍然可以迁移
+	single := NewIndexRoots(config.ProjectConfig{
+		Directories: []string{"/srv/pictures"},
+		COSConfig:   config.COSConfig{PathPrefix: "backup/"},
+	})
+	idx = NewFileIndex()
+	idx.Version = "1.0"
+	idx.AddEntry("/old/host/photos/2024/b.jpg", "hash2", 2, "backup/2024/b.jpg")
+	if dropped := single.Migrate(idx); dropped != 0 || idx.GetEntry("pictures/2024/b.jpg") == nil {
+		t.Errorf("Unexpected single-root migration: %d dropped, %v", dropped, idx.Files)
+	}
+}
+
+func TestDownloadRemoteIndexMigration(t *testing.T) {
+	dir := t.TempDir()
+	proj := config.ProjectConfig{
+		Name:        "migrate",
+		Directories: []string{dir},
+		RootIDs:     map[string]string{dir: "data"},
+		COSConfig:   config.COSConfig{PathPrefix: "uploads/"},
+	}
+	u := newTestUploader(t, newFakeCOS(), proj)
+
+	// 旧版本的远程索引以绝对路径为键
+	legacy := NewFileIndex()
+	legacy.Version = "1.0"
+	legacy.AddEntry(filepath.Join(dir, "a.txt"), "hash", 4, "uploads/a.txt")
+	indexManager := NewIndexManager(u.clients[proj.Name], &proj.COSConfig, u.logger)
+	if err := indexManager.UploadRemoteIndex(context.Background(), legacy, proj.Name); err != nil {
+		t.Fatalf("UploadRemoteIndex failed: %v", err)
+	}
+
+	indexManager.SetRoots(u.roots[proj.Name])
+	remoteIdx, err := indexManager.DownloadRemoteIndex(context.Background(), proj.Name)
+	if err != nil {
+		t.Fatalf("DownloadRemoteIndex failed: %v", err)
+	}
+	if remoteIdx.Version != IndexVersion || remoteIdx.GetEntry("data/a.txt") == nil {
+		t.Errorf("Remote index not migrated: %s %v", remoteIdx.Version, remoteIdx.Files)
+	}
+}
@@ -2,13 +2,15 @@ package uploader
 
 import (
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
-	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/hmw/cos-uploader/config"
+	"github.com/hmw/cos-uploader/filter"
 	"github.com/hmw/cos-uploader/logger"
 )
 
@@ -29,9 +31,16 @@ type DirectoryScanner struct {
 	hasher        *FileHasher
 	projectConfig config.ProjectConfig
 	indexManager  *IndexManager
+	filter        *filter.Filter
+	ignore        *filter.Ignore
+	symlinks      filter.SymlinkPolicy
+	roots         *IndexRoots // 监控目录与索引根标识
+	cache         IndexReader // 上次扫描的索引，stat 信息未变的文件复用其中的哈希（可选）
+	workers       int         // 并行计算哈希的协程数
 
 	// 进度统计
 	filesScanned int64
+	filesCached  int64
 	totalSize    int64
 	mu            sync.Mutex
 }
@@ -42,79 +51,348 @@ func NewDirectoryScanner(
 	indexManager *IndexManager,
 	log *logger.Logger,
 ) *DirectoryScanner {
+	fileFilter, err := filter.New(projectConfig.Include, projectConfig.Exclude)
+	if err != nil {
+		log.Warn("Invalid include/exclude patterns, scanning all files", "project", projectConfig.Name, "error", err)
+	}
+
+	symlinks, err := filter.ParseSymlinkPolicy(projectConfig.Symlinks)
+	if err != nil {
+		log.Warn("Invalid symlinks policy, following symlinks", "project", projectConfig.Name, "error", err)
+	}
+
+	// 并发数和缓冲区大小未配置时使用默认值
+	workers := 4
+	if projectConfig.Scan.HashWorkers > 0 {
+		workers = projectConfig.Scan.HashWorkers
+	}
+	bufferSize := 8 * 1024 * 1024
+	if projectConfig.Scan.HashBufferMB > 0 {
+		bufferSize = projectConfig.Scan.HashBufferMB * 1024 * 1024
+	}
+	hasher := NewFileHasherWithBuffer(bufferSize)
+	if err := hasher.SetAlgorithm(projectConfig.Scan.HashAlgorithm); err != nil {
+		log.Warn("Invalid hash algorithm, using md5", "project", projectConfig.Name, "error", err)
+	}
+
 	return &DirectoryScanner{
 		logger:        log,
-		hasher:        NewFileHasher(),
+		hasher:        hasher,
+		workers:       workers,
 		projectConfig: projectConfig,
 		indexManager:  indexManager,
+		filter:        fileFilter,
+		ignore:        filter.NewIgnore(projectConfig.Directories),
+		symlinks:      symlinks,
+		roots:         NewIndexRoots(projectConfig),
 	}
 }
 
-// ScanDirectories 扫描所有监听目录并生成本地索引
+// SetCache 设置上次扫描生成的本地索引（FileIndex、IndexStore 或 LocalIndex）
+// 大小、修改时间和 inode 都与索引条目相同的文件直接复用其中的哈希，不重新计算；
+// 条目按批读取，nil 表示重新计算所有文件的哈希
+func (ds *DirectoryScanner) SetCache(idx IndexReader) {
+	ds.cache = idx
+}
+
+// Roots 返回扫描器使用的监控目录与根标识的对应关系
+func (ds *DirectoryScanner) Roots() *IndexRoots {
+	return ds.roots
+}
+
+// cachedHash 返回缓存条目中 stat 信息与文件一致时的哈希
+func (ds *DirectoryScanner) cachedHash(entry *FileEntry, size int64, modTime string, inode uint64) (string, bool) {
+	if entry == nil || entry.Hash == "" || entry.Algorithm() != ds.hasher.Algorithm() {
+		return "", false
+	}
+	if entry.Size != size || entry.ModTime != modTime || entry.Inode != inode {
+		return "", false
+	}
+	return entry.Hash, true
+}
+
+// scanJob 遍历协程交给哈希协程的文件
+type scanJob struct {
+	path    string
+	key     string
+	relPath string
+	info    os.FileInfo
+	cached  *FileEntry // 缓存中的条目，没有时为 nil
+}
+
+// scanResult 哈希协程计算出的索引条目
+type scanResult struct {
+	key   string
+	entry *FileEntry
+}
+
+// ScanDirectories 扫描所有监听目录并生成本地索引，条目以 根标识/相对路径 为键
 func (ds *DirectoryScanner) ScanDirectories() (*FileIndex, error) {
 	localIndex := NewFileIndex()
+	err := ds.ScanBatches(func(entries map[string]*FileEntry) {
+		maps.Copy(localIndex.Files, entries)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return localIndex, nil
+}
 
-	// 递归扫描所有目录
-	for _, dir := range ds.projectConfig.Directories {
-		ds.logger.Info("Scanning directory", "path", dir, "project", ds.projectConfig.Name)
+// ScanBatches 扫描所有监听目录，每得到 indexBatchSize 个条目调用一次 fn，不在内存中保留整个索引
+// 一个协程遍历目录并按批读取缓存，多个协程并行计算哈希，fn 只在调用者的协程中执行
+func (ds *DirectoryScanner) ScanBatches(fn func(entries map[string]*FileEntry)) error {
+	jobs := make(chan scanJob, ds.workers*2)
+	results := make(chan scanResult, ds.workers*2)
 
-		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				ds.logger.Warn("Error accessing path", "path", path, "error", err)
-				return nil // 继续扫描其他文件
-			}
+	// 遍历协程：依次递归扫描所有目录，目录无法扫描时停止
+	var walkErr error
+	go func() {
+		defer close(jobs)
 
-			// 跳过目录
-			if info.IsDir() {
-				return nil
+		var pending []scanJob
+		flush := func() {
+			ds.loadCached(pending)
+			for _, job := range pending {
+				jobs <- job
 			}
+			pending = pending[:0]
+		}
+		defer flush()
 
-			// 跳过隐藏文件和临时文件
-			if strings.HasPrefix(info.Name(), ".") || strings.HasSuffix(info.Name(), ".tmp") {
-				return nil
-			}
+		for _, dir := range ds.projectConfig.Directories {
+			ds.logger.Info("Scanning directory", "path", dir, "project", ds.projectConfig.Name)
 
-			// 计算文件 MD5
-			hash, size, err := ds.hasher.ComputeMD5(path)
+			err := ds.walkFiles(dir, func(path, key, relPath string, info os.FileInfo) {
+				pending = append(pending, scanJob{path: path, key: key, relPath: relPath, info: info})
+				if len(pending) >= indexBatchSize {
+					flush()
+				}
+			})
 			if err != nil {
-				ds.logger.Warn("Failed to compute hash", "file", path, "error", err)
-				return nil // 继续扫描其他文件
+				ds.logger.Error("Error scanning directory", "path", dir, "error", err)
+				walkErr = fmt.Errorf("failed to scan directory %s: %w", dir, err)
+				return
+			}
+		}
+	}()
+
+	// 哈希协程，读取缓冲区从哈希计算器的缓冲池中复用
+	var wg sync.WaitGroup
+	for i := 0; i < ds.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				if entry := ds.hashFile(job); entry != nil {
+					results <- scanResult{key: job.key, entry: entry}
+				}
 			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// 结果只在当前协程中汇总
+	batch := make(map[string]*FileEntry)
+	for result := range results {
+		batch[result.key] = result.entry
+		if len(batch) >= indexBatchSize {
+			fn(batch)
+			batch = make(map[string]*FileEntry)
+		}
+	}
+	if walkErr != nil {
+		return walkErr
+	}
+	if len(batch) > 0 {
+		fn(batch)
+	}
+
+	ds.logger.Info("Directory scan completed",
+		"project", ds.projectConfig.Name,
+		"files", ds.filesScanned,
+		"cached", ds.filesCached,
+		"size", FormatBytes(ds.totalSize))
+
+	return nil
+}
+
+// loadCached 一次读取一批文件的缓存条目，读取失败时这批文件重新计算哈希
+func (ds *DirectoryScanner) loadCached(jobs []scanJob) {
+	if ds.cache == nil || len(jobs) == 0 {
+		return
+	}
+	keys := make([]string, len(jobs))
+	for i, job := range jobs {
+		keys[i] = job.key
+	}
+	entries, err := lookupEntries(ds.cache, keys)
+	if err != nil {
+		ds.logger.Warn("Failed to read local index, rehashing files", "project", ds.projectConfig.Name, "error", err)
+		return
+	}
+	for i := range jobs {
+		jobs[i].cached = entries[jobs[i].key]
+	}
+}
 
-			// 计算相对路径和远程路径
-			relPath, _ := filepath.Rel(dir, path)
-			remotePath := ds.projectConfig.COSConfig.PathPrefix + relPath
+// hashFile 生成文件的索引条目，无法计算哈希时返回 nil
+func (ds *DirectoryScanner) hashFile(job scanJob) *FileEntry {
+	symlink := job.info.Mode()&os.ModeSymlink != 0
+	size := job.info.Size()
+	if symlink {
+		size = 0
+	}
+	modTime := FormatModTime(job.info.ModTime())
+	inode := fileInode(job.info)
 
-			// 标准化路径分隔符（Windows 使用 \，需要转换为 /）
-			relPath = filepath.ToSlash(relPath)
+	// stat 信息未变时复用上次的哈希，否则计算文件哈希，保留的符号链接使用链接目标计算
+	hash, cached := ds.cachedHash(job.cached, size, modTime, inode)
+	if cached {
+		atomic.AddInt64(&ds.filesCached, 1)
+	} else {
+		var err error
+		if symlink {
+			hash, size, err = ds.hasher.ComputeSymlinkHash(job.path)
+		} else {
+			hash, size, err = ds.hasher.ComputeHash(job.path)
+		}
+		if err != nil {
+			ds.logger.Warn("Failed to compute hash", "file", job.path, "error", err)
+			return nil // 继续扫描其他文件
+		}
+	}
 
-			// 添加到本地索引
-			localIndex.AddEntry(path, hash, size, remotePath)
+	// 更新统计
+	atomic.AddInt64(&ds.filesScanned, 1)
+	atomic.AddInt64(&ds.totalSize, size)
 
-			// 更新统计
-			atomic.AddInt64(&ds.filesScanned, 1)
-			atomic.AddInt64(&ds.totalSize, size)
+	return &FileEntry{
+		Size:          size,
+		Hash:          hash,
+		HashAlgorithm: ds.hasher.Algorithm(),
+		UploadedTime:  time.Now().UTC().Format(time.RFC3339),
+		RemotePath:    ds.projectConfig.COSConfig.PathPrefix + job.relPath,
+		ModTime:       modTime,
+		Inode:         inode,
+	}
+}
+
+// ScanChanges 按文件大小和修改时间对比本地索引，返回新增或修改的文件，不计算哈希
+// changed 中的条目记录文件当前的大小、修改时间和远程路径，上传成功后才应写入本地索引；
+// 没有记录修改时间的旧索引条目只比较大小，补充修改时间后放入 backfill。本地索引的条目按批读取
+func (ds *DirectoryScanner) ScanChanges(localIdx IndexReader) (changed, backfill map[string]*FileEntry, err error) {
+	changed = make(map[string]*FileEntry)
+	backfill = make(map[string]*FileEntry)
 
+	var pending []scanJob
+	compare := func() error {
+		if len(pending) == 0 {
 			return nil
-		})
+		}
+		keys := make([]string, len(pending))
+		for i, job := range pending {
+			keys[i] = job.key
+		}
+		entries, err := lookupEntries(localIdx, keys)
+		if err != nil {
+			return fmt.Errorf("failed to read local index: %w", err)
+		}
+
+		for _, job := range pending {
+			// 保留的符号链接上传为空对象
+			size := job.info.Size()
+			if job.info.Mode()&os.ModeSymlink != 0 {
+				size = 0
+			}
+
+			modTime := FormatModTime(job.info.ModTime())
+			entry := entries[job.key]
+			if entry != nil && entry.Size == size && entry.ModTime == modTime {
+				continue
+			}
+			if entry != nil && entry.Size == size && entry.ModTime == "" {
+				entry.ModTime = modTime
+				backfill[job.key] = entry
+				continue
+			}
 
+			changed[job.key] = &FileEntry{
+				Size:       size,
+				ModTime:    modTime,
+				Inode:      fileInode(job.info),
+				RemotePath: ds.projectConfig.COSConfig.PathPrefix + job.relPath,
+			}
+		}
+		pending = pending[:0]
+		return nil
+	}
+
+	for _, dir := range ds.projectConfig.Directories {
+		var compareErr error
+		err := ds.walkFiles(dir, func(path, key, relPath string, info os.FileInfo) {
+			if compareErr != nil {
+				return
+			}
+			pending = append(pending, scanJob{path: path, key: key, relPath: relPath, info: info})
+			if len(pending) >= indexBatchSize {
+				compareErr = compare()
+			}
+		})
 		if err != nil {
-			ds.logger.Error("Error scanning directory", "path", dir, "error", err)
-			return nil, fmt.Errorf("failed to scan directory %s: %w", dir, err)
+			return nil, nil, fmt.Errorf("failed to scan directory %s: %w", dir, err)
+		}
+		if compareErr != nil {
+			return nil, nil, compareErr
 		}
 	}
+	if err := compare(); err != nil {
+		return nil, nil, err
+	}
 
-	ds.logger.Info("Directory scan completed",
-		"project", ds.projectConfig.Name,
-		"files", ds.filesScanned,
-		"size", FormatBytes(ds.totalSize))
+	return changed, backfill, nil
+}
 
-	return localIndex, nil
+// walkFiles 遍历目录中需要上传的文件，按 include/exclude、.cosignore 和符号链接处理方式过滤
+// key 为索引键，relPath 使用 / 分隔；preserve 方式下符号链接以自身的信息回调；无法访问的路径记录日志后跳过
+func (ds *DirectoryScanner) walkFiles(dir string, fn func(path, key, relPath string, info os.FileInfo)) error {
+	return filter.Walk(dir, ds.symlinks, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			ds.logger.Warn("Error accessing path", "path", path, "error", err)
+			return nil // 继续扫描其他文件
+		}
+
+		relPath, _ := filepath.Rel(dir, path)
+
+		// 跳过目录，排除和 .cosignore 忽略的目录不再遍历
+		if info.IsDir() {
+			if ds.filter.SkipDir(relPath) || ds.ignore.Ignored(path, true) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// 按 include/exclude 和 .cosignore 过滤
+		if !ds.filter.Match(relPath) || ds.ignore.Ignored(path, false) {
+			return nil
+		}
+
+		key, ok := ds.roots.Key(path)
+		if !ok {
+			return nil
+		}
+
+		// 标准化路径分隔符（Windows 使用 \，需要转换为 /）
+		fn(path, key, filepath.ToSlash(relPath), info)
+		return nil
+	})
 }
 
 // AnalyzeForUpload 分析本地和远程索引，确定需要上传的文件
 func (ds *DirectoryScanner) AnalyzeForUpload(localIdx, remoteIdx *FileIndex) (map[string]*FileEntry, int64) {
-	needsUpload, skipped := CompareIndices(localIdx, remoteIdx)
+	needsUpload, skipped := CompareIndices(localIdx, remoteIdx, ds.roots)
 
 	var uploadSize int64
 	for _, entry := range needsUpload {
@@ -1,8 +1,11 @@
 package uploader
 
 import (
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/hmw/cos-uploader/config"
@@ -102,25 +105,25 @@ func TestScanDirectories(t *testing.T) {
 	}
 
 	// Verify files are in index
-	if localIndex.GetEntry(file1) == nil {
+	if localIndex.GetEntry(scanKey(scanner, file1)) == nil {
 		t.Fatal("file1.txt not found in index")
 	}
 
-	if localIndex.GetEntry(file2) == nil {
+	if localIndex.GetEntry(scanKey(scanner, file2)) == nil {
 		t.Fatal("file2.txt not found in index")
 	}
 
 	// Hidden and tmp files should not be in index
-	if localIndex.GetEntry(hiddenFile) != nil {
+	if localIndex.GetEntry(scanKey(scanner, hiddenFile)) != nil {
 		t.Fatal("Hidden file should not be in index")
 	}
 
-	if localIndex.GetEntry(tmpFile) != nil {
+	if localIndex.GetEntry(scanKey(scanner, tmpFile)) != nil {
 		t.Fatal("Tmp file should not be in index")
 	}
 
 	// Verify file entries have correct hash and size
-	entry1 := localIndex.GetEntry(file1)
+	entry1 := localIndex.GetEntry(scanKey(scanner, file1))
 	if entry1.Size != 9 {
 		t.Errorf("Expected size 9, got %d", entry1.Size)
 	}
@@ -129,7 +132,7 @@ func TestScanDirectories(t *testing.T) {
 		t.Fatal("Hash should not be empty")
 	}
 
-	entry2 := localIndex.GetEntry(file2)
+	entry2 := localIndex.GetEntry(scanKey(scanner, file2))
 	if entry2.Size != 10 {
 		t.Errorf("Expected size 10, got %d", entry2.Size)
 	}
@@ -357,7 +360,249 @@ func TestScanDirectories_NestedDirectories(t *testing.T) {
 		t.Errorf("Expected 1 file scanned, got %d", scanner.filesScanned)
 	}
 
-	if localIndex.GetEntry(deepFile) == nil {
+	if localIndex.GetEntry(scanKey(scanner, deepFile)) == nil {
 		t.Fatal("Deep file not found in index")
 	}
 }
+
+func TestScanDirectories_IncludeExclude(t *testing.T) {
+	tmpDir := t.TempDir()
+	log := &logger.Logger{}
+	log.SetWriter(io.Discard, io.Discard)
+
+	for _, name := range []string{"a.jpg", "b.txt", filepath.Join("cache", "c.jpg"), filepath.Join("2026", "d.jpg")} {
+		path := filepath.Join(tmpDir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	projectConfig := config.ProjectConfig{
+		Name:        "test-project",
+		Directories: []string{tmpDir},
+		Include:     []string{"**/*.jpg"},
+		Exclude:     []string{"cache"},
+	}
+
+	indexManager := NewIndexManager(nil, &projectConfig.COSConfig, log)
+	scanner := NewDirectoryScanner(projectConfig, indexManager, log)
+
+	localIndex, err := scanner.ScanDirectories()
+	if err != nil {
+		t.Fatalf("ScanDirectories failed: %v", err)
+	}
+
+	if len(localIndex.Files) != 2 {
+		t.Errorf("Expected 2 files, got %d", len(localIndex.Files))
+	}
+	for _, name := range []string{"a.jpg", filepath.Join("2026", "d.jpg")} {
+		if _, ok := localIndex.Files[scanKey(scanner, filepath.Join(tmpDir, name))]; !ok {
+			t.Errorf("Expected %s in index", name)
+		}
+	}
+}
+
+func TestScanDirectories_CosIgnore(t *testing.T) {
+	tmpDir := t.TempDir()
+	log := &logger.Logger{}
+	log.SetWriter(io.Discard, io.Discard)
+
+	for _, name := range []string{"a.txt", "b.log", filepath.Join("build", "c.txt"), filepath.Join("keep", "d.log")} {
+		path := filepath.Join(tmpDir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	os.WriteFile(filepath.Join(tmpDir, ".cosignore"), []byte("*.log\nbuild/\n"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "keep", ".cosignore"), []byte("!*.log\n"), 0644)
+
+	projectConfig := config.ProjectConfig{
+		Name:        "test-project",
+		Directories: []string{tmpDir},
+	}
+
+	indexManager := NewIndexManager(nil, &projectConfig.COSConfig, log)
+	scanner := NewDirectoryScanner(projectConfig, indexManager, log)
+
+	localIndex, err := scanner.ScanDirectories()
+	if err != nil {
+		t.Fatalf("ScanDirectories failed: %v", err)
+	}
+
+	if len(localIndex.Files) != 2 {
+		t.Errorf("Expected 2 files, got %d", len(localIndex.Files))
+	}
+	for _, name := range []string{"a.txt", filepath.Join("keep", "d.log")} {
+		if _, ok := localIndex.Files[scanKey(scanner, filepath.Join(tmpDir, name))]; !ok {
+			t.Errorf("Expected %s in index", name)
+		}
+	}
+}
+
+func TestScanDirectories_Symlinks(t *testing.T) {
+	tmpDir := t.TempDir()
+	outside := t.TempDir()
+	log := &logger.Logger{}
+	log.SetWriter(io.Discard, io.Discard)
+
+	os.WriteFile(filepath.Join(tmpDir, "a.txt"), []byte("data"), 0644)
+	os.WriteFile(filepath.Join(outside, "b.txt"), []byte("outside"), 0644)
+	if err := os.Symlink(outside, filepath.Join(tmpDir, "linked")); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+	os.Symlink(tmpDir, filepath.Join(tmpDir, "loop"))
+
+	tests := []struct {
+		policy string
+		want   map[string]int64 // 相对路径 -> 索引中的大小
+	}{
+		{"follow", map[string]int64{"a.txt": 4, "linked/b.txt": 7}},
+		{"skip", map[string]int64{"a.txt": 4}},
+		{"preserve", map[string]int64{"a.txt": 4, "linked": 0, "loop": 0}},
+	}
+	for _, tt := range tests {
+		projectConfig := config.ProjectConfig{
+			Name:        "test-project",
+			Directories: []string{tmpDir},
+			Symlinks:    tt.policy,
+		}
+		indexManager := NewIndexManager(nil, &projectConfig.COSConfig, log)
+		scanner := NewDirectoryScanner(projectConfig, indexManager, log)
+
+		localIndex, err := scanner.ScanDirectories()
+		if err != nil {
+			t.Fatalf("%s: ScanDirectories failed: %v", tt.policy, err)
+		}
+		if len(localIndex.Files) != len(tt.want) {
+			t.Errorf("%s: expected %d files, got %d", tt.policy, len(tt.want), len(localIndex.Files))
+		}
+		for rel, size := range tt.want {
+			entry := localIndex.GetEntry(scanKey(scanner, filepath.Join(tmpDir, rel)))
+			if entry == nil {
+				t.Errorf("%s: expected %s in index", tt.policy, rel)
+				continue
+			}
+			if entry.Size != size || entry.Hash == "" {
+				t.Errorf("%s: unexpected entry for %s: size %d, hash %q", tt.policy, rel, entry.Size, entry.Hash)
+			}
+		}
+	}
+}
+
+func TestScanDirectories_Cache(t *testing.T) {
+	tmpDir := t.TempDir()
+	log := &logger.Logger{}
+	log.SetWriter(io.Discard, io.Discard)
+
+	unchanged := filepath.Join(tmpDir, "unchanged.txt")
+	modified := filepath.Join(tmpDir, "modified.txt")
+	replaced := filepath.Join(tmpDir, "replaced.txt")
+	for _, path := range []string{unchanged, modified, replaced} {
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	projectConfig := config.ProjectConfig{
+		Name:        "test-project",
+		Directories: []string{tmpDir},
+	}
+	indexManager := NewIndexManager(nil, &projectConfig.COSConfig, log)
+
+	first, err := NewDirectoryScanner(projectConfig, indexManager, log).ScanDirectories()
+	if err != nil {
+		t.Fatalf("ScanDirectories failed: %v", err)
+	}
+	// 标记缓存中的哈希，复用时结果中出现标记值
+	for _, entry := range first.Files {
+		entry.Hash = "cached"
+	}
+
+	os.WriteFile(modified, []byte("more data"), 0644)
+
+	// 大小和修改时间不变但 inode 不同的文件需要重新计算
+	info, _ := os.Stat(replaced)
+	tmp := replaced + ".new"
+	os.WriteFile(tmp, []byte("DATA"), 0644)
+	os.Chtimes(tmp, info.ModTime(), info.ModTime())
+	os.Rename(tmp, replaced)
+	newInfo, _ := os.Stat(replaced)
+	hasInode := fileInode(newInfo) != 0
+
+	scanner := NewDirectoryScanner(projectConfig, indexManager, log)
+	scanner.SetCache(first)
+	second, err := scanner.ScanDirectories()
+	if err != nil {
+		t.Fatalf("ScanDirectories failed: %v", err)
+	}
+
+	if got := second.Files[scanKey(scanner, unchanged)].Hash; got != "cached" {
+		t.Errorf("Expected cached hash for unchanged file, got %q", got)
+	}
+	if got := second.Files[scanKey(scanner, modified)].Hash; got == "cached" {
+		t.Error("Expected modified file to be rehashed")
+	}
+	if got := second.Files[scanKey(scanner, replaced)].Hash; hasInode && got == "cached" {
+		t.Error("Expected replaced file to be rehashed")
+	}
+
+	// 不设置缓存时重新计算所有文件
+	rehashed, err := NewDirectoryScanner(projectConfig, indexManager, log).ScanDirectories()
+	if err != nil {
+		t.Fatalf("ScanDirectories failed: %v", err)
+	}
+	for path, entry := range rehashed.Files {
+		if entry.Hash == "cached" {
+			t.Errorf("Expected %s to be rehashed without cache", path)
+		}
+	}
+}
+
+func TestScanDirectories_Parallel(t *testing.T) {
+	tmpDir := t.TempDir()
+	log := &logger.Logger{}
+	log.SetWriter(io.Discard, io.Discard)
+
+	for i := 0; i < 50; i++ {
+		path := filepath.Join(tmpDir, fmt.Sprintf("dir%d", i%5), fmt.Sprintf("file%d.txt", i))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(strings.Repeat("x", i*100)), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	scan := func(workers, bufferMB int) *FileIndex {
+		projectConfig := config.ProjectConfig{
+			Name:        "test-project",
+			Directories: []string{tmpDir},
+			Scan:        config.ScanConfig{HashWorkers: workers, HashBufferMB: bufferMB},
+		}
+		indexManager := NewIndexManager(nil, &projectConfig.COSConfig, log)
+		idx, err := NewDirectoryScanner(projectConfig, indexManager, log).ScanDirectories()
+		if err != nil {
+			t.Fatalf("ScanDirectories failed: %v", err)
+		}
+		return idx
+	}
+
+	// 并发数不影响生成的索引
+	serial := scan(1, 1)
+	parallel := scan(8, 1)
+	if len(serial.Files) != 50 || len(parallel.Files) != 50 {
+		t.Fatalf("Expected 50 files, got %d and %d", len(serial.Files), len(parallel.Files))
+	}
+	for path, want := range serial.Files {
+		got := parallel.GetEntry(path)
+		if got == nil || got.Hash != want.Hash || got.Size != want.Size || got.RemotePath != want.RemotePath || got.ModTime != want.ModTime {
+			t.Errorf("Entry mismatch for %s: %+v vs %+v", path, got, want)
+		}
+	}
+}
+
+// scanKey 返回扫描器为本地路径生成的索引键
+func scanKey(scanner *DirectoryScanner, path string) string {
+	key, _ := scanner.Roots().Key(path)
+	return key
+}
@@ -0,0 +1,138 @@
+package uploader
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 汇总统计中保留的明细条数
+const (
+	maxSlowestUploads = 5  // 最慢上传
+	maxReportFailures = 10 // 失败文件
+)
+
+// UploadTiming 单个文件的上传耗时
+type UploadTiming struct {
+	FilePath   string
+	RemotePath string
+	Size       int64
+	Duration   time.Duration
+}
+
+// ReportStats 守护进程一个报告周期内的上传统计
+// 复用全量上传的统计字段，Duration 为统计周期的时长
+type ReportStats struct {
+	FullUploadStats
+	Since   time.Time      // 统计周期开始时间
+	Slowest []UploadTiming // 耗时最长的上传，按耗时降序
+	Backlog int64          // 已入队但尚未完成的任务数
+}
+
+// statsCollector 收集单个项目的上传统计
+type statsCollector struct {
+	mu      sync.Mutex
+	stats   ReportStats
+	backlog int64
+}
+
+// newStatsCollector 创建统计收集器
+func newStatsCollector(projectName string) *statsCollector {
+	c := &statsCollector{}
+	c.reset(projectName, time.Now())
+	return c
+}
+
+// reset 开始新的统计周期（调用者需持有锁）
+func (c *statsCollector) reset(projectName string, now time.Time) {
+	c.stats = ReportStats{
+		FullUploadStats: FullUploadStats{ProjectName: projectName},
+		Since:           now,
+	}
+}
+
+// queued 记录任务入队
+func (c *statsCollector) queued() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.backlog++
+}
+
+// succeeded 记录任务上传成功
+func (c *statsCollector) succeeded(task *UploadTask, size int64, duration time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.done()
+	c.stats.TotalFiles++
+	c.stats.UploadedFiles++
+	c.stats.TotalSize += size
+	c.stats.UploadedSize += size
+
+	c.stats.Slowest = append(c.stats.Slowest, UploadTiming{
+		FilePath:   task.FilePath,
+		RemotePath: task.RemotePath,
+		Size:       size,
+		Duration:   duration,
+	})
+	sort.SliceStable(c.stats.Slowest, func(i, j int) bool {
+		return c.stats.Slowest[i].Duration > c.stats.Slowest[j].Duration
+	})
+	if len(c.stats.Slowest) > maxSlowestUploads {
+		c.stats.Slowest = c.stats.Slowest[:maxSlowestUploads]
+	}
+}
+
+// completed 记录没有上传数据的任务完成，如删除远程对象或跳过符号链接
+func (c *statsCollector) completed() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.done()
+}
+
+// failed 记录任务最终失败
+func (c *statsCollector) failed(task *UploadTask, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.done()
+	c.stats.TotalFiles++
+	c.stats.FailedFiles++
+	if len(c.stats.Failures) < maxReportFailures {
+		c.stats.Failures = append(c.stats.Failures, FileFailure{
+			FilePath:   task.FilePath,
+			RemotePath: task.RemotePath,
+			Error:      err.Error(),
+		})
+	}
+}
+
+// done 任务完成，减少积压数（调用者需持有锁）
+func (c *statsCollector) done() {
+	if c.backlog > 0 {
+		c.backlog--
+	}
+}
+
+// take 返回当前周期的统计并开始新的周期
+func (c *statsCollector) take() *ReportStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	stats := c.stats
+	stats.Duration = now.Sub(stats.Since)
+	stats.Backlog = c.backlog
+	c.reset(stats.ProjectName, now)
+	return &stats
+}
+
+// TakeReportStats 返回项目自上次调用以来的上传统计，并开始新的统计周期
+func (u *Uploader) TakeReportStats(projectName string) (*ReportStats, error) {
+	c, ok := u.stats[projectName]
+	if !ok {
+		return nil, fmt.Errorf("project '%s' not found", projectName)
+	}
+	return c.take(), nil
+}
@@ -0,0 +1,89 @@
+package uploader
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hmw/cos-uploader/config"
+)
+
+func TestStatsCollector(t *testing.T) {
+	c := newStatsCollector("p")
+
+	for i := 0; i < 8; i++ {
+		c.queued()
+	}
+	for i := 1; i <= 6; i++ {
+		task := &UploadTask{FilePath: fmt.Sprintf("/data/%d.txt", i), ProjectName: "p"}
+		c.succeeded(task, 100, time.Duration(i)*time.Second)
+	}
+	c.failed(&UploadTask{FilePath: "/data/bad.txt", ProjectName: "p"}, errors.New("denied"))
+
+	stats := c.take()
+	if stats.UploadedFiles != 6 || stats.FailedFiles != 1 || stats.UploadedSize != 600 {
+		t.Errorf("Unexpected counters: %+v", stats.FullUploadStats)
+	}
+	if stats.Backlog != 1 {
+		t.Errorf("Expected backlog 1, got %d", stats.Backlog)
+	}
+	if len(stats.Slowest) != maxSlowestUploads || stats.Slowest[0].Duration != 6*time.Second {
+		t.Errorf("Unexpected slowest uploads: %+v", stats.Slowest)
+	}
+	if len(stats.Failures) != 1 || stats.Failures[0].Error != "denied" {
+		t.Errorf("Unexpected failures: %+v", stats.Failures)
+	}
+
+	// 取出后开始新的周期，积压数保留
+	next := c.take()
+	if next.UploadedFiles != 0 || len(next.Slowest) != 0 || next.Backlog != 1 {
+		t.Errorf("Expected a fresh period, got %+v", next)
+	}
+	if next.Since.Before(stats.Since) {
+		t.Error("New period should start after the previous one")
+	}
+}
+
+func TestTakeReportStatsUnknownProject(t *testing.T) {
+	u := newTestUploader(t, newFakeCOS(), config.ProjectConfig{Name: "test"})
+	if _, err := u.TakeReportStats("missing"); err == nil {
+		t.Error("Expected error for unknown project")
+	}
+}
+
+func TestWorkerCompletesDeleteTask(t *testing.T) {
+	dir := t.TempDir()
+	proj := config.ProjectConfig{
+		Name:        "test",
+		Directories: []string{dir},
+		RootIDs:     map[string]string{dir: "data"},
+	}
+	u := newTestUploader(t, newFakeCOS(), proj)
+
+	// 删除任务没有上传数据，完成时同样减少积压数
+	u.stats[proj.Name].queued()
+	u.pool = NewWorkerPool(1, u, u.logger)
+	u.pool.Start()
+	defer u.pool.Stop()
+	u.pool.AddTask(&UploadTask{FilePath: dir + "/gone.txt", RemotePath: "gone.txt", ProjectName: proj.Name, Op: TaskOpDelete})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		c := u.stats[proj.Name]
+		c.mu.Lock()
+		backlog := c.backlog
+		c.mu.Unlock()
+		if backlog == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected delete task to clear the backlog, got %d", backlog)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if stats, _ := u.TakeReportStats(proj.Name); stats.UploadedFiles != 0 {
+		t.Errorf("Delete task should not count as an upload, got %d", stats.UploadedFiles)
+	}
+}
@@ -0,0 +1,67 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hmw/cos-uploader/filter"
+	cos "github.com/tencentyun/cos-go-sdk-v5"
+)
+
+// SymlinkTargetHeader 保留的符号链接上传为空对象时，保存链接目标的元数据头
+// 目标路径按 URL 路径编码，避免非 ASCII 字符
+const SymlinkTargetHeader = "x-cos-meta-symlink-target"
+
+// symlinkInfo 保留的符号链接的文件信息，大小按上传的空对象计为 0
+type symlinkInfo struct {
+	os.FileInfo
+}
+
+// Size 返回 0
+func (symlinkInfo) Size() int64 { return 0 }
+
+// uploadSymlink 按项目的符号链接处理方式上传链接本身
+// 返回 handled=false 表示路径不是符号链接或应跟随链接，按普通文件上传
+func (u *Uploader) uploadSymlink(client *cos.Client, task *UploadTask) (os.FileInfo, bool, error) {
+	policy := filter.SymlinkPolicy(u.configs[task.ProjectName].Symlinks)
+	if policy != filter.SymlinkSkip && policy != filter.SymlinkPreserve {
+		return nil, false, nil
+	}
+
+	info, err := os.Lstat(task.FilePath)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return nil, false, nil
+	}
+
+	if policy == filter.SymlinkSkip {
+		u.logger.Info("Symlink skipped", "file", task.FilePath)
+		return nil, true, nil
+	}
+
+	target, err := os.Readlink(task.FilePath)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to read symlink %s: %w", task.FilePath, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	meta := http.Header{}
+	meta.Set(SymlinkTargetHeader, (&url.URL{Path: target}).EscapedPath())
+	opts := &cos.ObjectPutOptions{
+		ObjectPutHeaderOptions: &cos.ObjectPutHeaderOptions{
+			XCosMetaXXX: &meta,
+		},
+	}
+	if _, err := client.Object.Put(ctx, task.RemotePath, strings.NewReader(""), opts); err != nil {
+		return nil, true, fmt.Errorf("failed to upload symlink to COS: %w", err)
+	}
+
+	u.logger.Info("Symlink uploaded successfully", "file", task.FilePath, "remote", task.RemotePath, "target", target)
+	return symlinkInfo{info}, true, nil
+}
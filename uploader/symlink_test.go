@@ -0,0 +1,58 @@
+package uploader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hmw/cos-uploader/config"
+)
+
+func TestUploadFile_PreserveSymlink(t *testing.T) {
+	fake := newFakeCOS()
+	proj := config.ProjectConfig{Name: "test", Symlinks: "preserve"}
+	u := newTestUploader(t, fake, proj)
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "target.txt"), []byte("content"), 0644)
+	link := filepath.Join(dir, "link.txt")
+	if err := os.Symlink("target.txt", link); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+
+	info, err := u.uploadFile(&UploadTask{FilePath: link, RemotePath: "data/link.txt", ProjectName: "test"})
+	if err != nil {
+		t.Fatalf("uploadFile failed: %v", err)
+	}
+	if info.Size() != 0 {
+		t.Errorf("Expected size 0 for preserved symlink, got %d", info.Size())
+	}
+
+	data, ok := fake.objects["data/link.txt"]
+	if !ok || len(data) != 0 {
+		t.Errorf("Expected empty object, got %q (exists: %v)", data, ok)
+	}
+	if target := fake.headers["data/link.txt"].Get(SymlinkTargetHeader); target != "target.txt" {
+		t.Errorf("Expected symlink target in metadata, got %q", target)
+	}
+}
+
+func TestUploadFile_SkipSymlink(t *testing.T) {
+	fake := newFakeCOS()
+	proj := config.ProjectConfig{Name: "test", Symlinks: "skip"}
+	u := newTestUploader(t, fake, proj)
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "target.txt"), []byte("content"), 0644)
+	link := filepath.Join(dir, "link.txt")
+	if err := os.Symlink("target.txt", link); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+
+	if err := u.UploadFile(&UploadTask{FilePath: link, RemotePath: "data/link.txt", ProjectName: "test"}); err != nil {
+		t.Fatalf("UploadFile failed: %v", err)
+	}
+	if _, ok := fake.objects["data/link.txt"]; ok {
+		t.Error("Expected skipped symlink not to be uploaded")
+	}
+}
@@ -23,6 +23,37 @@ type Uploader struct {
 	logger  *logger.Logger
 	done    chan struct{}
 	wg      sync.WaitGroup
+
+	replayWG sync.WaitGroup // 重放任务日志的协程
+
+	deadLetters map[string]*DeadLetterStore // project name -> 死信存储
+	notifiers   map[string]FailureNotifier  // project name -> 失败通知
+	stats       map[string]*statsCollector  // project name -> 汇总报告统计
+
+	localIndexes map[string]*localIndexState // project name -> 本地索引的更新状态
+	roots        map[string]*IndexRoots      // project name -> 监控目录与索引根标识
+
+	deleteLimiters map[string]*deleteLimiter // project name -> 远程删除限流
+	remoteIndexMu  sync.Mutex                // 串行修改远程索引
+
+	changesMu sync.Mutex
+	changes   map[string][]RemoteIndexChange // project name -> 待批量写入远程索引的修改
+
+	indexCacheMu sync.Mutex
+	indexCache   map[string]*cachedRemoteIndex // project name -> 查询用的远程索引缓存
+
+	pathLocks pathLocks // 串行上传或删除同一远程路径
+}
+
+// FailureNotifier 上传最终失败时的通知接口
+type FailureNotifier interface {
+	NotifyUploadFailure(projectName, filePath, remotePath string, err error)
+}
+
+// UploadObserver 接收工作池每次上传尝试结果的接口
+// 项目的 FailureNotifier 实现该接口时，成功和失败的上传尝试都会通知它
+type UploadObserver interface {
+	ObserveUpload(projectName string, err error)
 }
 
 // NewUploader 创建新的上传器
@@ -33,6 +64,14 @@ func NewUploader(projects []config.ProjectConfig, log *logger.Logger) (*Uploader
 		queue:   NewQueue(1000),
 		logger:  log,
 		done:    make(chan struct{}),
+
+		deadLetters: make(map[string]*DeadLetterStore),
+		notifiers:   make(map[string]FailureNotifier),
+		stats:       make(map[string]*statsCollector),
+
+		localIndexes:   make(map[string]*localIndexState),
+		roots:          make(map[string]*IndexRoots),
+		deleteLimiters: make(map[string]*deleteLimiter),
 	}
 
 	// 初始化每个项目的COS客户端
@@ -43,6 +82,11 @@ func NewUploader(projects []config.ProjectConfig, log *logger.Logger) (*Uploader
 		}
 		u.clients[proj.Name] = client
 		u.configs[proj.Name] = proj
+		u.deadLetters[proj.Name] = NewDeadLetterStore(GetDeadLetterPath(proj.Name))
+		u.stats[proj.Name] = newStatsCollector(proj.Name)
+		u.localIndexes[proj.Name] = &localIndexState{}
+		u.roots[proj.Name] = NewIndexRoots(proj)
+		u.deleteLimiters[proj.Name] = newDeleteLimiter(proj.Watcher.DeleteLimit)
 		log.Info("COS client created", "project", proj.Name, "bucket", proj.COSConfig.Bucket)
 	}
 
@@ -82,11 +126,76 @@ func createCOSClient(cosConfig *config.COSConfig) (*cos.Client, error) {
 	return client, nil
 }
 
+// SetNotifier 设置项目的失败通知，需在 Start 之前调用
+func (u *Uploader) SetNotifier(projectName string, notifier FailureNotifier) {
+	u.notifiers[projectName] = notifier
+}
+
+// observeUpload 将上传尝试结果通知项目的 UploadObserver
+func (u *Uploader) observeUpload(task *UploadTask, err error) {
+	if observer, ok := u.notifiers[task.ProjectName].(UploadObserver); ok {
+		observer.ObserveUpload(task.ProjectName, err)
+	}
+}
+
+// OpenJournal 打开任务日志，使队列中的任务在进程重启后不丢失
+// 需在 Start 之前调用，Start 时会重新入队上次未完成的任务
+func (u *Uploader) OpenJournal(path string) error {
+	journal, err := OpenTaskJournal(path)
+	if err != nil {
+		return fmt.Errorf("failed to open task journal: %w", err)
+	}
+	u.queue.SetJournal(journal)
+	u.logger.Info("Task journal opened", "path", path)
+	return nil
+}
+
 // Start 启动上传器
 func (u *Uploader) Start() {
-	u.wg.Add(1)
+	u.wg.Add(2)
 	go u.run()
+	go u.flushLoop()
 	u.pool.Start()
+	u.replayPending()
+}
+
+// replayPending 将任务日志中未完成的任务重新放入队列
+func (u *Uploader) replayPending() {
+	pending := u.queue.Pending()
+	if len(pending) == 0 {
+		return
+	}
+
+	u.logger.Info("Replaying pending upload tasks", "count", len(pending))
+	for _, task := range pending {
+		if c, ok := u.stats[task.ProjectName]; ok {
+			c.queued()
+		}
+	}
+	u.replayWG.Add(1)
+	go func() {
+		defer u.replayWG.Done()
+		for _, task := range pending {
+			// 重放的删除任务同样受删除上限限制
+			if task.Op == TaskOpDelete {
+				if err := u.limitDelete(task); err != nil {
+					u.logger.Warn("Remote delete skipped", "project", task.ProjectName, "file", task.FilePath, "error", err)
+					if c, ok := u.stats[task.ProjectName]; ok {
+						c.failed(task, err)
+					}
+					if err := u.queue.MarkFailed(task, err); err != nil {
+						u.logger.Warn("Failed to write task journal", "file", task.FilePath, "error", err)
+					}
+					continue
+				}
+			}
+			select {
+			case u.queue.tasks <- task:
+			case <-u.done:
+				return
+			}
+		}
+	}()
 }
 
 // run 主循环
@@ -108,42 +217,83 @@ func (u *Uploader) run() {
 
 // AddTask 添加上传任务
 func (u *Uploader) AddTask(task *UploadTask) {
-	u.queue.Add(task)
+	if c, ok := u.stats[task.ProjectName]; ok {
+		c.queued()
+	}
+	if err := u.queue.Add(task); err != nil {
+		u.logger.Warn("Failed to write task journal", "file", task.FilePath, "error", err)
+	}
 }
 
 // UploadFile 上传单个文件（由工作池调用）
 func (u *Uploader) UploadFile(task *UploadTask) error {
+	_, err := u.uploadFile(task)
+	return err
+}
+
+// uploadFile 上传单个文件，返回上传前的文件信息
+// 同一远程路径的上传串行执行，避免共用断点文件和分块上传 ID
+func (u *Uploader) uploadFile(task *UploadTask) (os.FileInfo, error) {
 	client, ok := u.clients[task.ProjectName]
 	if !ok {
-		return fmt.Errorf("COS client not found for project %s", task.ProjectName)
+		return nil, fmt.Errorf("COS client not found for project %s", task.ProjectName)
+	}
+	unlock := u.pathLocks.lock(task.ProjectName, task.RemotePath)
+	defer unlock()
+
+	// 按项目配置保留或跳过符号链接
+	if info, handled, err := u.uploadSymlink(client, task); handled {
+		return info, err
 	}
 
 	// 打开文件
 	file, err := os.Open(task.FilePath)
 	if err != nil {
-		return fmt.Errorf("failed to open file %s: %w", task.FilePath, err)
+		return nil, fmt.Errorf("failed to open file %s: %w", task.FilePath, err)
 	}
 	defer file.Close()
 
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file %s: %w", task.FilePath, err)
+	}
+
+	// 改名或移动的文件内容未变时在服务端复制，不重新上传
+	if task.OldRemotePath != "" && u.moveRemote(task, fileInfo) {
+		return fileInfo, nil
+	}
+
+	// 大文件使用分块上传
+	opts := newMultipartOptions(u.configs[task.ProjectName].COSConfig)
+	if fileInfo.Size() >= opts.threshold {
+		if err := u.multipartUpload(client, task, file, fileInfo, opts); err != nil {
+			return nil, fmt.Errorf("failed to upload file to COS: %w", err)
+		}
+		u.logger.Info("File uploaded successfully", "file", task.FilePath, "remote", task.RemotePath)
+		return fileInfo, nil
+	}
+
 	// 上传文件
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	_, err = client.Object.Put(ctx, task.RemotePath, file, nil)
 	if err != nil {
-		return fmt.Errorf("failed to upload file to COS: %w", err)
+		return nil, fmt.Errorf("failed to upload file to COS: %w", err)
 	}
 
 	u.logger.Info("File uploaded successfully", "file", task.FilePath, "remote", task.RemotePath)
-	return nil
+	return fileInfo, nil
 }
 
 // Stop 关闭上传器
 func (u *Uploader) Stop() {
 	close(u.done)
+	u.replayWG.Wait()
 	u.pool.Stop()
 	u.queue.Close()
 	u.wg.Wait()
+	u.flushRemoteChanges()
 }
 
 // WorkerPool 工作池
@@ -190,16 +340,52 @@ func (wp *WorkerPool) worker(id int) {
 			}
 
 			wp.logger.Debug("Processing upload task", "worker", id, "file", task.FilePath)
-			err := wp.uploader.UploadFile(task)
-			if err != nil {
+			queue := wp.uploader.queue
+			if err := queue.MarkStarted(task); err != nil {
+				wp.logger.Warn("Failed to write task journal", "file", task.FilePath, "error", err)
+			}
+
+			startTime := time.Now()
+			var info os.FileInfo
+			var err error
+			if task.Op == TaskOpDelete {
+				err = wp.uploader.deleteRemote(task)
+			} else {
+				info, err = wp.uploader.uploadFile(task)
+				wp.uploader.observeUpload(task, err)
+			}
+			if err == nil {
+				if c, ok := wp.uploader.stats[task.ProjectName]; ok {
+					if info != nil {
+						c.succeeded(task, info.Size(), time.Since(startTime))
+					} else {
+						c.completed()
+					}
+				}
+				if info != nil {
+					wp.uploader.recordUpload(task, info)
+				}
+				wp.uploader.resolveFailures(task)
+				if err := queue.MarkSucceeded(task); err != nil {
+					wp.logger.Warn("Failed to write task journal", "file", task.FilePath, "error", err)
+				}
+			} else {
 				// 重试逻辑
 				if task.Retry < 3 {
 					task.Retry++
 					wp.logger.Warn("Upload failed, retrying", "file", task.FilePath, "retry", task.Retry, "error", err)
 					wp.tasks <- task
 				} else {
-					// 3次都失败，记录日志
+					// 3次都失败，记录日志并清理未完成的分块上传
 					wp.logger.Error("Upload failed after 3 retries", "file", task.FilePath, "error", err)
+					wp.uploader.discardMultipartUpload(task)
+					wp.uploader.handleFinalFailure(task, task.Retry+1, err)
+					if c, ok := wp.uploader.stats[task.ProjectName]; ok {
+						c.failed(task, err)
+					}
+					if err := queue.MarkFailed(task, err); err != nil {
+						wp.logger.Warn("Failed to write task journal", "file", task.FilePath, "error", err)
+					}
 				}
 			}
 		}
@@ -228,11 +414,20 @@ type FullUploadStats struct {
 	TotalSize        int64
 	UploadedSize     int64
 	Duration         time.Duration
+	Failures         []FileFailure // 上传失败的文件
+}
+
+// FileFailure 全量上传中失败的文件
+type FileFailure struct {
+	FilePath   string
+	RemotePath string
+	Error      string
 }
 
 // ExecuteFullUpload 执行全量上传
+// 默认复用上次本地索引中 stat 信息未变的文件的哈希，rehash 为 true 时重新计算所有文件的哈希。
 // 返回统计信息和任何致命错误
-func (u *Uploader) ExecuteFullUpload(projectName string) (*FullUploadStats, error) {
+func (u *Uploader) ExecuteFullUpload(projectName string, rehash bool) (*FullUploadStats, error) {
 	startTime := time.Now()
 	stats := &FullUploadStats{
 		ProjectName: projectName,
@@ -252,57 +447,109 @@ func (u *Uploader) ExecuteFullUpload(projectName string) (*FullUploadStats, erro
 		return nil, fmt.Errorf("COS client not found for project '%s'", projectName)
 	}
 	indexManager := NewIndexManager(cosClient, &projectConfig.COSConfig, u.logger)
+	indexManager.SetRoots(u.roots[projectName])
 
-	// 创建扫描器
-	scanner := NewDirectoryScanner(projectConfig, indexManager, u.logger)
-
-	// Step 1: 扫描本地目录，生成本地索引
-	u.logger.Info("Step 1: Scanning local directories", "project", projectName)
-	localIdx, err := scanner.ScanDirectories()
+	// 打开本地索引数据库，旧版本的 JSON 索引自动导入。之后按批打开数据库，
+	// 扫描和上传期间不长时间占用，不阻塞守护进程记录上传结果
+	roots := u.roots[projectName]
+	store, err := OpenLocalIndexStore(projectName, roots, true)
 	if err != nil {
-		return nil, fmt.Errorf("failed to scan directories: %w", err)
-	}
-
-	stats.TotalFiles = int64(len(localIdx.Files))
-	for _, entry := range localIdx.Files {
-		stats.TotalSize += entry.Size
+		return nil, fmt.Errorf("failed to open local index: %w", err)
 	}
-
-	// 保存本地索引
-	localIndexPath := GetLocalIndexPath(projectName)
-	if err := localIdx.SaveToFile(localIndexPath); err != nil {
-		u.logger.Warn("Failed to save local index", "error", err)
-	} else {
-		u.logger.Info("Local index saved", "path", localIndexPath)
+	generation, err := store.BeginScan()
+	store.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open local index: %w", err)
 	}
+	localIndex := NewLocalIndex(store.Path())
 
-	// Step 2: 下载远程索引
-	u.logger.Info("Step 2: Downloading remote index", "project", projectName)
+	// Step 1: 下载远程索引
+	u.logger.Info("Step 1: Downloading remote index", "project", projectName)
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	remoteIdx, err := indexManager.DownloadRemoteIndex(ctx, projectName)
 	cancel()
+	remoteLoaded := err == nil
 	if err != nil {
 		u.logger.Warn("Failed to download remote index, will proceed anyway", "error", err)
 		remoteIdx = NewFileIndex()
 	}
 
-	// Step 3: 对比索引，确定需要上传的文件
-	u.logger.Info("Step 3: Analyzing files for upload", "project", projectName)
-	filesToUpload, skipped := scanner.AnalyzeForUpload(localIdx, remoteIdx)
-	stats.SkippedFiles = skipped
+	// 创建扫描器
+	scanner := NewDirectoryScanner(projectConfig, indexManager, u.logger)
+	if !rehash {
+		scanner.SetCache(localIndex)
+	}
+
+	// Step 2: 扫描本地目录，按批写入本地索引并与远程索引对比，确定需要上传的文件
+	u.logger.Info("Step 2: Scanning local directories", "project", projectName, "rehash", rehash)
+	filesToUpload := make(map[string]*FileEntry)
+	var uploadSize int64
+	saved := true
+	err = scanner.ScanBatches(func(entries map[string]*FileEntry) {
+		batch := NewFileIndex()
+		batch.Files = entries
+		needsUpload, skipped := CompareIndices(batch, remoteIdx, roots)
+		stats.SkippedFiles += int64(skipped)
+		for key, entry := range entries {
+			stats.TotalFiles++
+			stats.TotalSize += entry.Size
+			if _, ok := needsUpload[key]; ok {
+				filesToUpload[key] = entry
+				uploadSize += entry.Size
+			}
+		}
+
+		if err := localIndex.PutEntries(entries); err != nil {
+			u.logger.Warn("Failed to save local index", "error", err)
+			saved = false
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan directories: %w", err)
+	}
+
+	// 删除本次扫描没有写入的条目（文件已删除或已被排除），扫描期间守护进程记录的上传结果保留；
+	// 部分扫描结果保存失败时不删除，避免误删这些文件的条目
+	if saved {
+		removed, err := localIndex.Prune(generation)
+		if err != nil {
+			u.logger.Warn("Failed to prune local index", "error", err)
+		} else {
+			u.logger.Info("Local index saved", "path", localIndex.Path(), "removed", removed)
+		}
+	}
+
+	u.logger.Info("Upload analysis completed",
+		"project", projectName,
+		"files_to_upload", len(filesToUpload),
+		"files_skipped", stats.SkippedFiles,
+		"upload_size", FormatBytes(uploadSize))
 
 	if len(filesToUpload) == 0 {
-		u.logger.Info("No files need to be uploaded", "project", projectName, "skipped", skipped)
+		u.logger.Info("No files need to be uploaded", "project", projectName, "skipped", stats.SkippedFiles)
+		// 远程索引可能已转换为新版本，或跨算法匹配的条目已改为本地的哈希，仍需保存；
+		// 下载失败时不保存，避免覆盖远程索引
+		if remoteLoaded {
+			remoteIdx.Timestamp = time.Now().UTC().Format(time.RFC3339)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			err := indexManager.UploadRemoteIndex(ctx, remoteIdx, projectName)
+			cancel()
+			if err != nil {
+				u.logger.Warn("Failed to upload remote index", "error", err)
+			}
+		}
 		stats.Duration = time.Since(startTime)
 		return stats, nil
 	}
 
-	// Step 4: 上传需要的文件
-	u.logger.Info("Step 4: Starting file uploads", "project", projectName, "count", len(filesToUpload))
+	// Step 3: 上传需要的文件
+	u.logger.Info("Step 3: Starting file uploads", "project", projectName, "count", len(filesToUpload))
 	successCount := 0
 	failureCount := 0
+	uploaded := make(map[string]*FileEntry)
 
-	for localPath, entry := range filesToUpload {
+	for key, entry := range filesToUpload {
+		localPath, _ := scanner.Roots().LocalPath(key)
 		task := &UploadTask{
 			FilePath:    localPath,
 			RemotePath:  entry.RemotePath,
@@ -314,31 +561,43 @@ func (u *Uploader) ExecuteFullUpload(projectName string) (*FullUploadStats, erro
 		err := u.uploadFileWithRetry(task, 3)
 		if err != nil {
 			u.logger.Error("File upload failed", "file", localPath, "error", err)
+			u.handleFinalFailure(task, 3, err)
+			stats.Failures = append(stats.Failures, FileFailure{
+				FilePath:   localPath,
+				RemotePath: entry.RemotePath,
+				Error:      err.Error(),
+			})
 			failureCount++
 		} else {
 			successCount++
+			u.resolveFailures(task)
 			stats.UploadedSize += entry.Size
 			// 更新本地索引为已上传状态
-			localIdx.Files[localPath].UploadedTime = time.Now().UTC().Format(time.RFC3339)
+			entry.UploadedTime = time.Now().UTC().Format(time.RFC3339)
+			uploaded[key] = entry
 		}
 	}
 
 	stats.UploadedFiles = int64(successCount)
 	stats.FailedFiles = int64(failureCount)
 
-	// Step 5: 更新远程索引
-	u.logger.Info("Step 5: Updating remote index", "project", projectName)
-	UpdateRemoteIndexWithUploads(remoteIdx, filesToUpload)
+	// Step 4: 更新远程索引，下载失败时不保存，避免覆盖远程索引
+	if remoteLoaded {
+		u.logger.Info("Step 4: Updating remote index", "project", projectName)
+		UpdateRemoteIndexWithUploads(remoteIdx, uploaded)
 
-	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
-	err = indexManager.UploadRemoteIndex(ctx, remoteIdx, projectName)
-	cancel()
-	if err != nil {
-		u.logger.Warn("Failed to upload remote index", "error", err)
+		ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		err = indexManager.UploadRemoteIndex(ctx, remoteIdx, projectName)
+		cancel()
+		if err != nil {
+			u.logger.Warn("Failed to upload remote index", "error", err)
+		}
+	} else {
+		u.logger.Warn("Skipping remote index update because it could not be downloaded", "project", projectName)
 	}
 
-	// 再次保存本地索引（带上传状态）
-	if err := localIdx.SaveToFile(localIndexPath); err != nil {
+	// 更新已上传文件的本地索引条目（带上传状态）
+	if err := localIndex.PutEntries(uploaded); err != nil {
 		u.logger.Warn("Failed to save updated local index", "error", err)
 	}
 
@@ -360,8 +619,9 @@ func (u *Uploader) ExecuteFullUpload(projectName string) (*FullUploadStats, erro
 
 // uploadFileWithRetry 上传文件并重试指定次数
 func (u *Uploader) uploadFileWithRetry(task *UploadTask, maxRetries int) error {
+	var err error
 	for attempt := 0; attempt < maxRetries; attempt++ {
-		err := u.UploadFile(task)
+		err = u.UploadFile(task)
 		if err == nil {
 			return nil
 		}
@@ -379,5 +639,87 @@ func (u *Uploader) uploadFileWithRetry(task *UploadTask, maxRetries int) error {
 		}
 	}
 
-	return fmt.Errorf("upload failed after %d attempts", maxRetries)
+	u.discardMultipartUpload(task)
+	return fmt.Errorf("upload failed after %d attempts: %w", maxRetries, err)
+}
+
+// DeadLetters 获取项目的死信存储
+func (u *Uploader) DeadLetters(projectName string) (*DeadLetterStore, error) {
+	store, ok := u.deadLetters[projectName]
+	if !ok {
+		return nil, fmt.Errorf("project '%s' not found", projectName)
+	}
+	return store, nil
+}
+
+// handleFinalFailure 处理最终失败的任务：写入项目死信并发送失败通知
+func (u *Uploader) handleFinalFailure(task *UploadTask, attempts int, taskErr error) {
+	if store, ok := u.deadLetters[task.ProjectName]; ok {
+		if err := store.Record(task, attempts, taskErr); err != nil {
+			u.logger.Warn("Failed to record failed upload", "file", task.FilePath, "error", err)
+		}
+	}
+	if notifier, ok := u.notifiers[task.ProjectName]; ok {
+		notifier.NotifyUploadFailure(task.ProjectName, task.FilePath, task.RemotePath, taskErr)
+	}
+}
+
+// resolveFailures 任务成功后移除死信中同一文件之前失败的任务
+func (u *Uploader) resolveFailures(task *UploadTask) {
+	store, ok := u.deadLetters[task.ProjectName]
+	if !ok {
+		return
+	}
+	removed, err := store.Resolve(task.FilePath)
+	if err != nil {
+		u.logger.Warn("Failed to clear resolved dead-letter entries", "file", task.FilePath, "error", err)
+	} else if removed > 0 {
+		u.logger.Info("Cleared failed tasks after success", "file", task.FilePath, "count", removed)
+	}
+}
+
+// RetryFailed 重新上传死信中的任务（同步，带重试）
+// ids 为空时重试项目的全部失败任务；成功的任务从死信中移除，仍失败的任务更新失败信息
+func (u *Uploader) RetryFailed(projectName string, ids []string) (succeeded, failed int, err error) {
+	store, err := u.DeadLetters(projectName)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	entries, err := store.List()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	for _, entry := range entries {
+		if len(wanted) > 0 && !wanted[entry.ID] {
+			continue
+		}
+
+		task := entry.Task()
+		u.logger.Info("Retrying failed upload", "project", projectName, "file", task.FilePath, "id", entry.ID, "op", task.Op)
+		retry := u.uploadFileWithRetry
+		if task.Op == TaskOpDelete {
+			retry = u.deleteRemoteWithRetry
+		}
+		if uploadErr := retry(task, 3); uploadErr != nil {
+			u.logger.Error("Retry failed", "file", task.FilePath, "error", uploadErr)
+			u.handleFinalFailure(task, 3, uploadErr)
+			failed++
+			continue
+		}
+
+		if _, err := store.Remove(entry.ID); err != nil {
+			u.logger.Warn("Failed to remove retried task from dead-letter", "file", task.FilePath, "error", err)
+		}
+		succeeded++
+	}
+
+	u.flushRemoteChanges()
+	return succeeded, failed, nil
 }
\ No newline at end of file
@@ -1,8 +1,10 @@
 package uploader
 
 import (
+	"errors"
 	"testing"
 
+	"github.com/hmw/cos-uploader/config"
 	"github.com/hmw/cos-uploader/logger"
 )
 
@@ -45,3 +47,59 @@ func TestWorkerPool(t *testing.T) {
 		t.Errorf("Expected 3 workers, got %d", pool.workers)
 	}
 }
+
+// recordingNotifier 记录失败通知
+type recordingNotifier struct {
+	files []string
+}
+
+func (n *recordingNotifier) NotifyUploadFailure(projectName, filePath, remotePath string, err error) {
+	n.files = append(n.files, filePath)
+}
+
+// observingNotifier 同时记录每次上传结果
+type observingNotifier struct {
+	recordingNotifier
+	results []error
+}
+
+func (n *observingNotifier) ObserveUpload(projectName string, err error) {
+	n.results = append(n.results, err)
+}
+
+func TestObserveUpload(t *testing.T) {
+	u := newTestUploader(t, newFakeCOS(), config.ProjectConfig{Name: "test"})
+	task := &UploadTask{FilePath: "/data/a.txt", RemotePath: "a.txt", ProjectName: "test"}
+
+	// 未实现 UploadObserver 的通知不受影响
+	u.SetNotifier("test", &recordingNotifier{})
+	u.observeUpload(task, nil)
+
+	notifier := &observingNotifier{}
+	u.SetNotifier("test", notifier)
+	u.observeUpload(task, nil)
+	u.observeUpload(task, errors.New("boom"))
+
+	if len(notifier.results) != 2 || notifier.results[0] != nil || notifier.results[1] == nil {
+		t.Errorf("Unexpected observed results: %v", notifier.results)
+	}
+}
+
+func TestHandleFinalFailureNotifies(t *testing.T) {
+	u := newTestUploader(t, newFakeCOS(), config.ProjectConfig{Name: "test"})
+	notifier := &recordingNotifier{}
+	u.SetNotifier("test", notifier)
+
+	task := &UploadTask{FilePath: "/data/a.txt", RemotePath: "a.txt", ProjectName: "test"}
+	u.handleFinalFailure(task, 4, errors.New("boom"))
+
+	if len(notifier.files) != 1 || notifier.files[0] != "/data/a.txt" {
+		t.Errorf("Expected failure notification for /data/a.txt, got %v", notifier.files)
+	}
+
+	store, _ := u.DeadLetters("test")
+	entries, _ := store.List()
+	if len(entries) != 1 {
+		t.Errorf("Expected failure recorded in dead-letter, got %d entries", len(entries))
+	}
+}
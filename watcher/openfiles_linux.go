@@ -0,0 +1,61 @@
+package watcher
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// openForWriting 返回当前被进程以写方式打开的文件集合
+// 通过 /proc/<pid>/fd 和 fdinfo 中的 flags 判断，只能看到有权限访问的进程
+func openForWriting() (map[string]bool, bool) {
+	fdDirs, err := filepath.Glob("/proc/[0-9]*/fd")
+	if err != nil {
+		return nil, false
+	}
+
+	files := make(map[string]bool)
+	for _, fdDir := range fdDirs {
+		entries, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			target, err := os.Readlink(filepath.Join(fdDir, entry.Name()))
+			if err != nil || !strings.HasPrefix(target, "/") {
+				continue
+			}
+			infoPath := filepath.Join(filepath.Dir(fdDir), "fdinfo", entry.Name())
+			if writable, ok := fdWritable(infoPath); ok && writable {
+				files[target] = true
+			}
+		}
+	}
+	return files, true
+}
+
+// fdWritable 读取 fdinfo 中的 flags，判断文件描述符是否可写
+func fdWritable(infoPath string) (bool, bool) {
+	f, err := os.Open(infoPath)
+	if err != nil {
+		return false, false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "flags:") {
+			continue
+		}
+		flags, err := strconv.ParseUint(strings.TrimSpace(strings.TrimPrefix(line, "flags:")), 8, 64)
+		if err != nil {
+			return false, false
+		}
+		// O_WRONLY 或 O_RDWR
+		return flags&(uint64(os.O_WRONLY)|uint64(os.O_RDWR)) != 0, true
+	}
+	return false, false
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package watcher
+
+// openForWriting 当前平台不支持检测文件是否正被写入
+func openForWriting() (map[string]bool, bool) {
+	return nil, false
+}
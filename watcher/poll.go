@@ -0,0 +1,183 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hmw/cos-uploader/filter"
+	"github.com/hmw/cos-uploader/logger"
+)
+
+// fileSnapshot 轮询时记录的文件状态
+type fileSnapshot struct {
+	size    int64
+	modTime time.Time
+}
+
+// NewPollingWatcher 创建定期扫描目录的文件监听器
+// 用于 NFS/SMB/FUSE 等不支持 inotify 的挂载，通过比较文件大小和修改时间生成
+// create、write、remove 事件；改名表现为 remove 和 create
+func NewPollingWatcher(directories []string, watchEvents []string, interval time.Duration, log *logger.Logger) (*Watcher, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid poll interval: %s", interval)
+	}
+
+	return &Watcher{
+		directories:  directories,
+		events:       watchEvents,
+		eventsChan:   make(chan Event, 100),
+		logger:       log,
+		done:         make(chan struct{}),
+		pending:      make(map[string]*pendingEvent),
+		ready:        make(chan string, 100),
+		held:         make(map[string]*heldFile),
+		ignore:       filter.NewIgnore(directories),
+		pollInterval: interval,
+		snapshots:    make(map[string]map[string]fileSnapshot),
+		initialized:  make(chan struct{}),
+	}, nil
+}
+
+// errWatcherClosed 扫描过程中监听器被关闭
+var errWatcherClosed = errors.New("watcher closed")
+
+// snapshotAll 记录所有目录的初始状态，扫描失败的目录在下次轮询时重试
+// 完成后关闭 initialized。监听器关闭时返回 false
+func (w *Watcher) snapshotAll() bool {
+	defer close(w.initialized)
+	for _, dir := range w.directories {
+		files, err := w.snapshot(dir, nil)
+		if errors.Is(err, errWatcherClosed) {
+			return false
+		}
+		if err != nil {
+			w.reportPollError(err)
+			continue
+		}
+		w.snapshots[dir] = files
+		w.logger.Debug("Polling directory", "path", dir, "files", len(files), "interval", w.pollInterval)
+	}
+	return true
+}
+
+// snapshot 扫描目录，返回其中所有文件的大小和修改时间
+// 目录本身无法访问时返回错误，监听器关闭时返回 errWatcherClosed。子目录和文件的错误记录日志，
+// 其下的文件沿用 previous 中上次的状态，避免暂时的 I/O 错误被当作文件删除
+func (w *Watcher) snapshot(dir string, previous map[string]fileSnapshot) (map[string]fileSnapshot, error) {
+	files := make(map[string]fileSnapshot)
+	var failed []string
+	err := filter.Walk(dir, w.symlinks, func(path string, info os.FileInfo, err error) error {
+		select {
+		case <-w.done:
+			return errWatcherClosed
+		default:
+		}
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			w.logger.Warn("Failed to poll path", "path", path, "error", err)
+			failed = append(failed, path)
+			return nil
+		}
+		if info.IsDir() {
+			if path != dir && w.skipDir(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !watchableFile(info) {
+			return nil
+		}
+
+		files[path] = fileSnapshot{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to poll directory %s: %w", dir, err)
+	}
+
+	if len(failed) > 0 {
+		for path, snap := range previous {
+			if _, ok := files[path]; !ok && underAny(path, failed) {
+				files[path] = snap
+			}
+		}
+	}
+	return files, nil
+}
+
+// underAny 判断 path 是否为 dirs 中的某个路径或位于其下
+func underAny(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// poll 重新扫描所有目录，与上次的状态比较后生成事件。监听器关闭时返回 false
+func (w *Watcher) poll() bool {
+	for _, dir := range w.directories {
+		previous, ok := w.snapshots[dir]
+		files, err := w.snapshot(dir, previous)
+		if errors.Is(err, errWatcherClosed) {
+			return false
+		}
+		if err != nil {
+			// 挂载暂时不可用时保留上次的状态，避免恢复后把所有文件当作新文件
+			w.reportPollError(err)
+			continue
+		}
+
+		w.snapshots[dir] = files
+		if !ok {
+			// 初始扫描失败的目录，恢复后只记录状态
+			continue
+		}
+
+		for path, current := range files {
+			old, existed := previous[path]
+			switch {
+			case !existed:
+				if !w.pollEvent(path, "create") {
+					return false
+				}
+			case current.size != old.size || !current.modTime.Equal(old.modTime):
+				if !w.pollEvent(path, "write") {
+					return false
+				}
+			}
+		}
+		for path := range previous {
+			if _, exists := files[path]; !exists {
+				if !w.pollEvent(path, "remove") {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// pollEvent 按事件类型和过滤规则生成轮询发现的事件。监听器关闭时返回 false
+func (w *Watcher) pollEvent(path, eventType string) bool {
+	if !w.shouldWatch(eventType) || !w.matchFile(path) {
+		return true
+	}
+	w.logger.Debug("File change polled", "file", path, "type", eventType)
+	return w.dispatch(path, eventType, "")
+}
+
+// reportPollError 记录轮询错误并通知错误回调
+func (w *Watcher) reportPollError(err error) {
+	w.logger.Error("Watcher error", "error", err)
+	if w.errorHandler != nil {
+		w.errorHandler(err)
+	}
+}
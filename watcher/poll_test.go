@@ -0,0 +1,219 @@
+package watcher
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hmw/cos-uploader/filter"
+	"github.com/hmw/cos-uploader/logger"
+)
+
+// newTestPollingWatcher 创建扫描间隔很短的轮询监听器
+func newTestPollingWatcher(t *testing.T, dir string, events []string) *Watcher {
+	t.Helper()
+
+	log := &logger.Logger{}
+	log.SetWriter(io.Discard, io.Discard)
+
+	w, err := NewPollingWatcher([]string{dir}, events, 20*time.Millisecond, log)
+	if err != nil {
+		t.Fatalf("Failed to create polling watcher: %v", err)
+	}
+	t.Cleanup(func() { w.Close() })
+	return w
+}
+
+// startPolling 启动轮询监听器并等待初始扫描完成
+func startPolling(t *testing.T, w *Watcher) {
+	t.Helper()
+
+	w.Start()
+	select {
+	case <-w.initialized:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for initial scan")
+	}
+}
+
+// expectEvent 等待指定文件和类型的事件
+func expectEvent(t *testing.T, w *Watcher, path, eventType string) {
+	t.Helper()
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case event := <-w.Events():
+			if event.FilePath == path && event.Type == eventType {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for %s event on %s", eventType, path)
+		}
+	}
+}
+
+func TestNewPollingWatcherInvalidInterval(t *testing.T) {
+	log := &logger.Logger{}
+	log.SetWriter(io.Discard, io.Discard)
+
+	if _, err := NewPollingWatcher([]string{t.TempDir()}, []string{"create"}, 0, log); err == nil {
+		t.Error("Expected error for zero poll interval")
+	}
+}
+
+func TestPollingWatcherEvents(t *testing.T) {
+	tmpDir := t.TempDir()
+	existing := filepath.Join(tmpDir, "existing.txt")
+	os.WriteFile(existing, []byte("data"), 0644)
+
+	w := newTestPollingWatcher(t, tmpDir, []string{"create", "write", "remove"})
+	startPolling(t, w)
+
+	created := filepath.Join(tmpDir, "sub", "new.txt")
+	os.MkdirAll(filepath.Dir(created), 0755)
+	os.WriteFile(created, []byte("data"), 0644)
+	expectEvent(t, w, created, "create")
+
+	os.WriteFile(existing, []byte("more data"), 0644)
+	expectEvent(t, w, existing, "write")
+
+	os.Remove(created)
+	expectEvent(t, w, created, "remove")
+}
+
+func TestPollingWatcherFilter(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.MkdirAll(filepath.Join(tmpDir, "cache"), 0755)
+
+	w := newTestPollingWatcher(t, tmpDir, []string{"create", "write"})
+	f, _ := filter.New(nil, []string{"cache/**", "**/*.tmp"})
+	w.SetFilter(f)
+	startPolling(t, w)
+
+	for _, name := range []string{filepath.Join("cache", "a.txt"), "b.tmp", "c.txt"} {
+		os.WriteFile(filepath.Join(tmpDir, name), []byte("data"), 0644)
+	}
+
+	// 只有 c.txt 产生事件
+	timeout := time.After(200 * time.Millisecond)
+	for {
+		select {
+		case event := <-w.Events():
+			if event.FilePath != filepath.Join(tmpDir, "c.txt") {
+				t.Errorf("Unexpected event for excluded file: %+v", event)
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
+
+func TestPollingWatcherKeepsSnapshotOnError(t *testing.T) {
+	tmpDir := t.TempDir()
+	root := filepath.Join(tmpDir, "mnt")
+	os.MkdirAll(root, 0755)
+	file := filepath.Join(root, "a.txt")
+	os.WriteFile(file, []byte("data"), 0644)
+
+	w := newTestPollingWatcher(t, root, []string{"create", "remove"})
+	errs := make(chan error, 10)
+	w.SetErrorHandler(func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	})
+	startPolling(t, w)
+
+	// 挂载点暂时消失时只报告错误，不生成 remove 事件
+	moved := filepath.Join(tmpDir, "moved")
+	if err := os.Rename(root, moved); err != nil {
+		t.Fatalf("Failed to move directory: %v", err)
+	}
+	select {
+	case <-errs:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected poll error for missing directory")
+	}
+	os.Rename(moved, root)
+
+	created := filepath.Join(root, "b.txt")
+	os.WriteFile(created, []byte("data"), 0644)
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case event := <-w.Events():
+			if event.FilePath == file {
+				t.Fatalf("Unexpected event for unchanged file: %+v", event)
+			}
+			if event.FilePath == created {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for create event on %s", created)
+		}
+	}
+}
+
+func TestPollingWatcherKeepsSubtreeOnError(t *testing.T) {
+	root := t.TempDir()
+	target := filepath.Join(t.TempDir(), "target")
+	os.MkdirAll(target, 0755)
+	os.WriteFile(filepath.Join(target, "a.txt"), []byte("data"), 0644)
+	link := filepath.Join(root, "link")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+	file := filepath.Join(link, "a.txt")
+
+	w := newTestPollingWatcher(t, root, []string{"create", "remove"})
+	startPolling(t, w)
+
+	// 子目录暂时无法访问时沿用其中文件上次的状态，不生成 remove 事件
+	moved := target + "-moved"
+	if err := os.Rename(target, moved); err != nil {
+		t.Fatalf("Failed to move directory: %v", err)
+	}
+	defer os.Rename(moved, target)
+	time.Sleep(100 * time.Millisecond) // 等待几次轮询
+
+	created := filepath.Join(root, "b.txt")
+	os.WriteFile(created, []byte("data"), 0644)
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case event := <-w.Events():
+			if event.FilePath == file {
+				t.Fatalf("Unexpected event for file in inaccessible directory: %+v", event)
+			}
+			if event.FilePath == created {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for create event on %s", created)
+		}
+	}
+}
+
+func TestPollingWatcherCloseDuringInitialScan(t *testing.T) {
+	tmpDir := t.TempDir()
+	for i := 0; i < 100; i++ {
+		dir := filepath.Join(tmpDir, fmt.Sprintf("d%d", i))
+		os.MkdirAll(dir, 0755)
+		os.WriteFile(filepath.Join(dir, "a.txt"), []byte("data"), 0644)
+	}
+
+	// 初始扫描在监听协程中进行，Start 不阻塞，Close 中断扫描
+	w := newTestPollingWatcher(t, tmpDir, []string{"create"})
+	w.Start()
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, ok := <-w.Events(); ok {
+		t.Error("Expected events channel to be closed")
+	}
+}
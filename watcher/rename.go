@@ -0,0 +1,60 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// renamePairWindow rename 事件与随后的 create 事件配对的最长间隔
+// inotify 的 MOVED_FROM 和 MOVED_TO 总是相邻到达，1 秒足够覆盖事件处理的延迟
+const renamePairWindow = time.Second
+
+// renamedPath 等待与 create 事件配对的改名前路径
+type renamedPath struct {
+	path string
+	at   time.Time
+}
+
+// recordRename 记录改名前的路径，等待随后的 create 事件配对
+func (w *Watcher) recordRename(path string) {
+	w.expireRenames(time.Now())
+	w.renames = append(w.renames, renamedPath{path: path, at: time.Now()})
+}
+
+// pairRename 返回与新路径配对的改名前路径，没有配对时返回空字符串
+// 优先选择文件名相同的路径（在目录之间移动），否则选择最近的一次改名；
+// 改名前的路径仍然存在时不配对
+func (w *Watcher) pairRename(newPath string) string {
+	w.expireRenames(time.Now())
+	if len(w.renames) == 0 {
+		return ""
+	}
+
+	match := len(w.renames) - 1
+	for i := len(w.renames) - 1; i >= 0; i-- {
+		if filepath.Base(w.renames[i].path) == filepath.Base(newPath) {
+			match = i
+			break
+		}
+	}
+
+	oldPath := w.renames[match].path
+	w.renames = append(w.renames[:match], w.renames[match+1:]...)
+	if oldPath == newPath {
+		return ""
+	}
+	if _, err := os.Lstat(oldPath); err == nil {
+		return ""
+	}
+	return oldPath
+}
+
+// expireRenames 丢弃超过配对窗口的改名记录
+func (w *Watcher) expireRenames(now time.Time) {
+	i := 0
+	for i < len(w.renames) && now.Sub(w.renames[i].at) > renamePairWindow {
+		i++
+	}
+	w.renames = w.renames[i:]
+}
@@ -0,0 +1,143 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// writersScanInterval 扫描正在写入的文件的最小间隔，扫描需要遍历所有进程的文件描述符
+const writersScanInterval = time.Second
+
+// heldFile 等待写入完成的文件
+type heldFile struct {
+	event    Event
+	size     int64
+	modTime  time.Time
+	since    time.Time // 大小和修改时间最近一次变化的时间
+	resolved string    // 解析符号链接后的绝对路径，与 /proc 中的文件描述符目标比较
+	written  bool      // 是否曾检测到文件被以写方式打开
+}
+
+// SetStabilityWait 设置文件稳定等待时间，需在 Start 之前调用
+// create 和 write 事件会被暂存，直到文件大小和修改时间在 wait 内保持不变，
+// 或者（在支持的平台上）曾被以写方式打开的文件不再被任何进程以写方式打开；0 表示不检查
+func (w *Watcher) SetStabilityWait(wait time.Duration) {
+	w.stabilityWait = wait
+}
+
+// stabilityInterval 返回检查暂存文件的间隔
+func (w *Watcher) stabilityInterval() time.Duration {
+	interval := w.stabilityWait / 2
+	if interval > time.Second {
+		interval = time.Second
+	}
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	return interval
+}
+
+// forward 发出事件；启用稳定检查时暂存文件的 create 和 write 事件。监听器关闭时返回 false
+func (w *Watcher) forward(event Event) bool {
+	if w.stabilityWait <= 0 {
+		return w.emit(event)
+	}
+
+	if event.Type != "create" && event.Type != "write" {
+		// 文件被删除或改名后不再等待
+		if held, ok := w.held[event.FilePath]; ok {
+			event.Count += held.event.Count
+			delete(w.held, event.FilePath)
+		}
+		return w.emit(event)
+	}
+
+	info, err := os.Stat(event.FilePath)
+	if err != nil || info.IsDir() {
+		return w.emit(event)
+	}
+
+	if held, ok := w.held[event.FilePath]; ok {
+		held.event.Type = event.Type
+		held.event.Time = event.Time
+		held.event.Count += event.Count
+		if event.OldPath != "" {
+			held.event.OldPath = event.OldPath
+		}
+		return true
+	}
+
+	w.held[event.FilePath] = &heldFile{
+		event:    event,
+		size:     info.Size(),
+		modTime:  info.ModTime(),
+		since:    time.Now(),
+		resolved: resolvePath(event.FilePath),
+	}
+	w.logger.Debug("Waiting for file to become stable", "file", event.FilePath)
+	return true
+}
+
+// resolvePath 返回解析符号链接后的绝对路径，解析失败时返回原路径
+func resolvePath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	return path
+}
+
+// writers 返回正在被以写方式打开的文件，距上次扫描不足 writersScanInterval 时返回 false
+func (w *Watcher) writers(now time.Time) (map[string]bool, bool) {
+	if now.Sub(w.writersAt) < writersScanInterval {
+		return nil, false
+	}
+	w.writersAt = now
+	return openForWriting()
+}
+
+// releaseStable 发出已经稳定的暂存文件，文件消失时丢弃。监听器关闭时返回 false
+// 曾被检测到以写方式打开的文件在关闭后立即发出，不必等待完整的稳定时间
+func (w *Watcher) releaseStable() bool {
+	if len(w.held) == 0 {
+		return true
+	}
+
+	now := time.Now()
+	writing, scanned := w.writers(now)
+	for path, held := range w.held {
+		info, err := os.Stat(path)
+		if err != nil {
+			w.logger.Debug("Held file disappeared", "file", path, "error", err)
+			delete(w.held, path)
+			continue
+		}
+
+		if scanned && writing[held.resolved] {
+			held.written = true
+		}
+
+		if info.Size() != held.size || !info.ModTime().Equal(held.modTime) {
+			held.size = info.Size()
+			held.modTime = info.ModTime()
+			held.since = now
+			continue
+		}
+
+		closed := scanned && held.written && !writing[held.resolved]
+		stable := now.Sub(held.since) >= w.stabilityWait
+		if !stable && !closed {
+			continue
+		}
+
+		delete(w.held, path)
+		w.logger.Debug("File is stable", "file", path, "closed", closed)
+		if !w.emit(held.event) {
+			return false
+		}
+	}
+	return true
+}
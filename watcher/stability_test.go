@@ -0,0 +1,148 @@
+package watcher
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/hmw/cos-uploader/logger"
+)
+
+// newStabilityWatcher 创建启用稳定检查的监听器
+func newStabilityWatcher(t *testing.T, dir string, wait time.Duration) *Watcher {
+	t.Helper()
+
+	log := &logger.Logger{}
+	log.SetWriter(io.Discard, io.Discard)
+
+	w, err := NewWatcher([]string{dir}, []string{"create", "write"}, log)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	t.Cleanup(func() { w.Close() })
+	w.SetStabilityWait(wait)
+	w.Start()
+	return w
+}
+
+func TestStabilityWaitHoldsGrowingFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	w := newStabilityWatcher(t, tmpDir, 300*time.Millisecond)
+
+	testFile := filepath.Join(tmpDir, "video.mp4")
+	f, err := os.Create(testFile)
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	defer f.Close()
+
+	// 持续写入期间不应发出事件
+	deadline := time.Now().Add(600 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		f.WriteString("frame")
+		select {
+		case event := <-w.Events():
+			t.Fatalf("Event emitted while file is still growing: %+v", event)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	// 停止写入（文件仍打开）后等待稳定时间再发出
+	select {
+	case event := <-w.Events():
+		if event.FilePath != testFile || event.Count < 2 {
+			t.Errorf("Unexpected event: %+v", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for stable file")
+	}
+}
+
+func TestStabilityWaitReleasesClosedFile(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Open file detection is only supported on Linux")
+	}
+
+	tmpDir := t.TempDir()
+	w := newStabilityWatcher(t, tmpDir, time.Minute)
+
+	testFile := filepath.Join(tmpDir, "a.txt")
+	f, err := os.Create(testFile)
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	f.WriteString("data")
+
+	// 等待监听器检测到文件正被写入
+	select {
+	case event := <-w.Events():
+		t.Fatalf("Event emitted while file is open for writing: %+v", event)
+	case <-time.After(writersScanInterval + 500*time.Millisecond):
+	}
+
+	// 文件关闭后不必等待完整的稳定时间
+	f.Close()
+	select {
+	case event := <-w.Events():
+		if event.FilePath != testFile {
+			t.Errorf("Unexpected event: %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Closed file should be released early")
+	}
+}
+
+func TestStabilityWaitHoldsFileNeverSeenWriting(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Open file detection is only supported on Linux")
+	}
+
+	tmpDir := t.TempDir()
+	w := newStabilityWatcher(t, tmpDir, time.Minute)
+
+	// 写入方可能会再次打开文件，未检测到写入的文件等待完整的稳定时间
+	testFile := filepath.Join(tmpDir, "a.txt")
+	if err := os.WriteFile(testFile, []byte("data"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	select {
+	case event := <-w.Events():
+		t.Errorf("File never seen open for writing should not be released early: %+v", event)
+	case <-time.After(2*writersScanInterval + 500*time.Millisecond):
+	}
+}
+
+func TestOpenForWriting(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Open file detection is only supported on Linux")
+	}
+
+	testFile := filepath.Join(t.TempDir(), "a.txt")
+	f, err := os.Create(testFile)
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	writing, supported := openForWriting()
+	if !supported || !writing[testFile] {
+		t.Errorf("Expected %s to be open for writing", testFile)
+	}
+
+	// 通过符号链接访问的文件按解析后的路径比较
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink(filepath.Dir(testFile), link); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	if resolved := resolvePath(filepath.Join(link, "a.txt")); !writing[resolved] {
+		t.Errorf("Expected %s to resolve to a file open for writing", resolved)
+	}
+
+	f.Close()
+	if writing, _ := openForWriting(); writing[testFile] {
+		t.Errorf("Expected %s to be closed", testFile)
+	}
+}
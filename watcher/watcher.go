@@ -1,6 +1,7 @@
 package watcher
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -9,6 +10,7 @@ import (
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/hmw/cos-uploader/filter"
 	"github.com/hmw/cos-uploader/logger"
 )
 
@@ -17,18 +19,50 @@ type Event struct {
 	FilePath string // 文件绝对路径
 	Type     string // 事件类型: create, write, remove, rename, chmod
 	Time     int64  // 事件时间戳（纳秒）
+	Count    int    // 合并的原始事件数，未启用合并时为 1
+	OldPath  string // 文件由该路径改名或移动而来（与 rename 事件配对的 create 事件），否则为空
+}
+
+// pendingEvent 合并窗口内尚未发出的事件
+type pendingEvent struct {
+	event    Event
+	lastSeen time.Time
+	timer    *time.Timer
 }
 
 // Watcher 文件监听器
 type Watcher struct {
-	watcher     *fsnotify.Watcher
+	watcher     *fsnotify.Watcher // poll 方式时为 nil
 	directories []string
 	events      []string // 监听的事件类型
 	eventsChan  chan Event
 	logger      *logger.Logger
 	done        chan struct{}
-	mu          sync.Mutex // 保护closed字段的并发访问
-	closed      bool       // 标记watcher是否已关闭
+	stopped     chan struct{} // Start 的协程退出时关闭，未启动时为 nil
+	mu          sync.Mutex    // 保护closed和stopped字段的并发访问
+	closed      bool          // 标记watcher是否已关闭
+
+	errorHandler func(error)          // 监听错误回调（可选）
+	filter       *filter.Filter       // include/exclude 过滤（可选）
+	ignore       *filter.Ignore       // .cosignore 规则，修改后立即生效
+	symlinks     filter.SymlinkPolicy // 符号链接处理方式，空表示 follow
+
+	// 事件合并：同一路径在 debounce 窗口内的事件合并为一个
+	debounce time.Duration
+	pending  map[string]*pendingEvent // 只在 Start 的协程中访问
+	ready    chan string              // 合并窗口结束的路径
+
+	// 稳定检查：写入中的文件暂存到大小和修改时间不再变化后再发出
+	stabilityWait time.Duration
+	held          map[string]*heldFile // 只在 Start 的协程中访问
+	writersAt     time.Time            // 最近一次扫描打开文件的时间，只在 Start 的协程中访问
+
+	renames []renamedPath // 等待与 create 配对的 rename 事件，只在 Start 的协程中访问
+
+	// 轮询：定期扫描目录代替 fsnotify
+	pollInterval time.Duration
+	snapshots    map[string]map[string]fileSnapshot // 监控目录 -> 文件状态，只在 Start 的协程中访问
+	initialized  chan struct{}                      // 初始扫描完成时关闭
 }
 
 // NewWatcher 创建新的文件监听器
@@ -45,6 +79,10 @@ func NewWatcher(directories []string, watchEvents []string, log *logger.Logger)
 		eventsChan:  make(chan Event, 100),
 		logger:      log,
 		done:        make(chan struct{}),
+		pending:     make(map[string]*pendingEvent),
+		ready:       make(chan string, 100),
+		held:        make(map[string]*heldFile),
+		ignore:      filter.NewIgnore(directories),
 	}
 
 	// 递归添加所有目录
@@ -66,14 +104,21 @@ func (w *Watcher) addRecursive(dir string) error {
 	}
 	w.logger.Debug("Watching directory", "path", dir)
 
-	// 遍历所有子目录并添加到监听
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+	// 遍历所有子目录并添加到监听，按符号链接处理方式决定是否进入链接指向的目录
+	err := filter.Walk(dir, w.symlinks, func(path string, info os.FileInfo, err error) error {
+		if errors.Is(err, filter.ErrSymlinkLoop) {
+			w.logger.Warn("Symlink loop skipped", "path", path, "error", err)
+			return nil
+		}
 		if err != nil {
 			return err
 		}
 
-		// 只添加目录到监听器
+		// 只添加目录到监听器，排除的目录不再遍历
 		if info.IsDir() && path != dir {
+			if w.skipDir(path) {
+				return filepath.SkipDir
+			}
 			if err := w.watcher.Add(path); err != nil {
 				w.logger.Debug("Failed to watch subdirectory", "path", path, "error", err)
 				// 继续处理其他目录，不中断遍历
@@ -92,44 +137,300 @@ func (w *Watcher) addRecursive(dir string) error {
 	return nil
 }
 
+// SetFilter 设置 include/exclude 过滤，需在 Start 之前调用
+// 已经加入监听的排除目录会被移除
+func (w *Watcher) SetFilter(f *filter.Filter) {
+	w.filter = f
+	if w.watcher == nil {
+		return
+	}
+	for _, path := range w.watcher.WatchList() {
+		if w.skipDir(path) {
+			w.watcher.Remove(path)
+			w.logger.Debug("Excluded directory unwatched", "path", path)
+		}
+	}
+}
+
+// SetSymlinkPolicy 设置符号链接处理方式，需在 Start 之前调用
+// skip 和 preserve 不监听链接指向的目录，已经加入的监听按新的方式重新添加
+func (w *Watcher) SetSymlinkPolicy(policy filter.SymlinkPolicy) {
+	w.symlinks = policy
+	if w.watcher == nil || policy == "" || policy == filter.SymlinkFollow {
+		return
+	}
+	for _, path := range w.watcher.WatchList() {
+		w.watcher.Remove(path)
+	}
+	for _, dir := range w.directories {
+		if err := w.addRecursive(dir); err != nil {
+			w.logger.Warn("Failed to watch directory", "path", dir, "error", err)
+		}
+	}
+}
+
+// skipDir 判断目录是否被排除
+func (w *Watcher) skipDir(path string) bool {
+	rel, ok := filter.Rel(w.directories, path)
+	return ok && w.filter.SkipDir(rel)
+}
+
+// matchFile 判断文件是否需要上传
+// .cosignore 忽略的目录仍然保持监听，规则修改后其中的文件无需重启即可上传
+func (w *Watcher) matchFile(path string) bool {
+	rel, ok := filter.Rel(w.directories, path)
+	if ok && !w.filter.Match(rel) {
+		return false
+	}
+	return !w.ignore.Ignored(path, false)
+}
+
+// SetErrorHandler 设置监听错误回调，需在 Start 之前调用
+func (w *Watcher) SetErrorHandler(handler func(error)) {
+	w.errorHandler = handler
+}
+
+// SetDebounce 设置事件合并窗口，需在 Start 之前调用
+// 同一路径的事件在窗口内没有新事件后才发出一个事件，类型为最后一次事件的类型；
+// 0 表示不合并。关闭监听器时尚未发出的事件会被丢弃
+func (w *Watcher) SetDebounce(window time.Duration) {
+	w.debounce = window
+}
+
 // Start 启动监听
+// 监听协程负责发送事件，退出时关闭 Events 返回的通道
 func (w *Watcher) Start() {
+	w.mu.Lock()
+	if w.closed || w.stopped != nil {
+		w.mu.Unlock()
+		return
+	}
+	stopped := make(chan struct{})
+	w.stopped = stopped
+	w.mu.Unlock()
+
+	// poll 方式没有 fsnotify 通道，nil 通道不会被选中
+	var fsEvents chan fsnotify.Event
+	var fsErrors chan error
+	if w.watcher != nil {
+		fsEvents = w.watcher.Events
+		fsErrors = w.watcher.Errors
+	}
+
 	go func() {
+		defer close(stopped)
+		defer close(w.eventsChan)
+
+		// 初始扫描可能很慢，在协程中进行，不阻塞调用者
+		if w.watcher == nil && !w.snapshotAll() {
+			return
+		}
+
+		var stabilityTick <-chan time.Time
+		if w.stabilityWait > 0 {
+			ticker := time.NewTicker(w.stabilityInterval())
+			defer ticker.Stop()
+			stabilityTick = ticker.C
+		}
+
+		var pollTick <-chan time.Time
+		if w.watcher == nil {
+			ticker := time.NewTicker(w.pollInterval)
+			defer ticker.Stop()
+			pollTick = ticker.C
+		}
+
 		for {
 			select {
-			case fsEvent, ok := <-w.watcher.Events:
+			case <-w.done:
+				return
+
+			case fsEvent, ok := <-fsEvents:
 				if !ok {
 					return
 				}
 
+				// 改名前的路径与随后的 create 事件配对
+				if fsEvent.Has(fsnotify.Rename) {
+					w.recordRename(fsEvent.Name)
+				}
+
+				// skip 方式忽略符号链接的所有事件
+				link := filter.IsSymlink(fsEvent.Name)
+				if link && w.symlinks == filter.SymlinkSkip {
+					continue
+				}
+
+				// 新建的目录加入监听，并为其中已有的文件补发事件
+				// preserve 方式下指向目录的链接按文件处理
+				var oldPath string
+				if fsEvent.Has(fsnotify.Create) {
+					oldPath = w.pairRename(fsEvent.Name)
+					if info, err := os.Stat(fsEvent.Name); err == nil && info.IsDir() && !(link && w.symlinks == filter.SymlinkPreserve) {
+						if w.skipDir(fsEvent.Name) {
+							continue
+						}
+						if !w.watchNewDirectory(fsEvent.Name, oldPath) {
+							return
+						}
+						continue
+					}
+				}
+
 				eventType := w.getEventType(fsEvent)
-				if !w.shouldWatch(eventType) {
+				if !w.shouldWatch(eventType) || !w.matchFile(fsEvent.Name) {
 					continue
 				}
 
-				event := Event{
-					FilePath: fsEvent.Name,
-					Type:     eventType,
-					Time:     time.Now().UnixNano(),
+				w.logger.Debug("File event detected", "file", fsEvent.Name, "type", eventType, "old_path", oldPath)
+				if !w.dispatch(fsEvent.Name, eventType, oldPath) {
+					return
+				}
+
+			case path := <-w.ready:
+				entry, ok := w.pending[path]
+				if !ok {
+					continue
+				}
+				// 计时器触发后又收到新事件时等待下一次触发
+				if time.Since(entry.lastSeen) < w.debounce {
+					continue
+				}
+				delete(w.pending, path)
+				if entry.event.Count > 1 {
+					w.logger.Debug("File events coalesced", "file", path, "type", entry.event.Type, "count", entry.event.Count)
+				}
+				if !w.forward(entry.event) {
+					return
+				}
+
+			case <-stabilityTick:
+				if !w.releaseStable() {
+					return
 				}
 
-				w.logger.Debug("File event detected", "file", fsEvent.Name, "type", eventType)
-				select {
-				case w.eventsChan <- event:
-				case <-w.done:
+			case <-pollTick:
+				if !w.poll() {
 					return
 				}
 
-			case err, ok := <-w.watcher.Errors:
+			case err, ok := <-fsErrors:
 				if !ok {
 					return
 				}
 				w.logger.Error("Watcher error", "error", err)
+				if w.errorHandler != nil {
+					w.errorHandler(err)
+				}
 			}
 		}
 	}()
 }
 
+// dispatch 生成事件并交给合并和稳定检查处理，监听器关闭时返回 false
+// oldPath 为文件改名或移动前的路径，没有时为空
+func (w *Watcher) dispatch(path, eventType, oldPath string) bool {
+	event := Event{
+		FilePath: path,
+		Type:     eventType,
+		Time:     time.Now().UnixNano(),
+		Count:    1,
+		OldPath:  oldPath,
+	}
+	if w.debounce > 0 {
+		w.coalesce(event)
+		return true
+	}
+	return w.forward(event)
+}
+
+// watchNewDirectory 递归监听新建的目录，并为目录中已有的文件生成 create 事件
+// 文件可能在目录加入监听之前就已创建，不补发事件会漏传。
+// 目录由 oldDir 改名或移动而来时，事件带上文件原来的路径。监听器关闭时返回 false
+func (w *Watcher) watchNewDirectory(dir, oldDir string) bool {
+	if err := w.addRecursive(dir); err != nil {
+		w.logger.Warn("Failed to watch new directory", "path", dir, "error", err)
+		return true
+	}
+	w.logger.Info("Watching new directory", "path", dir)
+
+	if !w.shouldWatch("create") {
+		return true
+	}
+
+	var files []string
+	filter.Walk(dir, w.symlinks, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			w.logger.Debug("Failed to scan new directory", "path", path, "error", err)
+			return nil
+		}
+		if info.IsDir() && path != dir && (w.skipDir(path) || w.ignore.Ignored(path, true)) {
+			return filepath.SkipDir
+		}
+		if watchableFile(info) && w.matchFile(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+
+	for _, path := range files {
+		var oldPath string
+		if oldDir != "" {
+			rel, _ := filepath.Rel(dir, path)
+			oldPath = filepath.Join(oldDir, rel)
+		}
+		w.logger.Debug("File found in new directory", "file", path, "old_path", oldPath)
+		if !w.dispatch(path, "create", oldPath) {
+			return false
+		}
+	}
+	return true
+}
+
+// watchableFile 判断遍历到的路径是否按文件生成事件
+// filter.Walk 只在 preserve 方式下以链接自身的信息回调
+func watchableFile(info os.FileInfo) bool {
+	return info.Mode().IsRegular() || info.Mode()&os.ModeSymlink != 0
+}
+
+// coalesce 将事件合并到路径的待发事件中，并重新开始合并窗口
+func (w *Watcher) coalesce(event Event) {
+	now := time.Now()
+	if entry, ok := w.pending[event.FilePath]; ok {
+		entry.event.Type = event.Type
+		entry.event.Time = event.Time
+		entry.event.Count++
+		if event.OldPath != "" {
+			entry.event.OldPath = event.OldPath
+		}
+		entry.lastSeen = now
+		entry.timer.Reset(w.debounce)
+		return
+	}
+
+	path := event.FilePath
+	w.pending[path] = &pendingEvent{
+		event:    event,
+		lastSeen: now,
+		timer: time.AfterFunc(w.debounce, func() {
+			select {
+			case w.ready <- path:
+			case <-w.done:
+			}
+		}),
+	}
+}
+
+// emit 发出事件，监听器关闭时返回 false
+func (w *Watcher) emit(event Event) bool {
+	select {
+	case w.eventsChan <- event:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
 // getEventType 获取事件类型名称
 func (w *Watcher) getEventType(fsEvent fsnotify.Event) string {
 	switch {
@@ -171,16 +472,21 @@ func (w *Watcher) Close() error {
 		return nil
 	}
 	w.closed = true
+	stopped := w.stopped
 	w.mu.Unlock()
 
-	// 关闭done通道，使Start中的goroutine退出
+	// 关闭done通道，等待Start中的goroutine退出，goroutine退出时关闭eventsChan，
+	// 使main中的range循环能够结束
 	close(w.done)
-	// 给goroutine时间退出
-	time.Sleep(100 * time.Millisecond)
-
-	// 关闭eventsChan，使main中的range循环能够结束
-	close(w.eventsChan)
+	if stopped != nil {
+		<-stopped
+	} else {
+		close(w.eventsChan)
+	}
 
+	if w.watcher == nil {
+		return nil
+	}
 	return w.watcher.Close()
 }
 
@@ -1,11 +1,14 @@
 package watcher
 
 import (
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/hmw/cos-uploader/filter"
 	"github.com/hmw/cos-uploader/logger"
 )
 
@@ -27,6 +30,38 @@ func TestNewWatcher(t *testing.T) {
 	}
 }
 
+func TestCloseWhileEmitting(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	log := &logger.Logger{}
+	log.SetWriter(io.Discard, io.Discard)
+	watcher, err := NewWatcher([]string{tmpDir}, []string{"create", "write"}, log)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	watcher.Start()
+
+	// 事件通道写满后监听协程阻塞在发送上，Close 需等待其退出后再关闭通道
+	for i := 0; i < 200; i++ {
+		os.WriteFile(filepath.Join(tmpDir, fmt.Sprintf("%d.txt", i)), []byte("data"), 0644)
+	}
+	if err := watcher.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	for range watcher.Events() {
+	}
+
+	// 未启动的监听器关闭时同样关闭事件通道
+	idle, err := NewWatcher([]string{tmpDir}, []string{"create"}, log)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	idle.Close()
+	if _, ok := <-idle.Events(); ok {
+		t.Error("Expected events channel to be closed")
+	}
+}
+
 func TestShouldWatch(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -84,3 +119,310 @@ func TestIsInWatchedDirectory(t *testing.T) {
 		t.Errorf("Expected file not in watched directory, but IsInWatchedDirectory returned true")
 	}
 }
+
+func TestDebounceCoalescesEvents(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	log := &logger.Logger{}
+	log.SetWriter(io.Discard, io.Discard)
+
+	watcher, err := NewWatcher([]string{tmpDir}, []string{"create", "write"}, log)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	defer watcher.Close()
+	watcher.SetDebounce(200 * time.Millisecond)
+	watcher.Start()
+
+	// 一次保存产生 create 和多次 write
+	testFile := filepath.Join(tmpDir, "test.txt")
+	f, err := os.Create(testFile)
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		f.WriteString("data")
+		f.Sync()
+		time.Sleep(20 * time.Millisecond)
+	}
+	f.Close()
+
+	select {
+	case event := <-watcher.Events():
+		if event.FilePath != testFile || event.Type != "write" {
+			t.Errorf("Unexpected event: %+v", event)
+		}
+		if event.Count < 2 {
+			t.Errorf("Expected coalesced events, got count %d", event.Count)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for coalesced event")
+	}
+
+	select {
+	case event := <-watcher.Events():
+		t.Errorf("Expected a single event per burst, got another: %+v", event)
+	case <-time.After(400 * time.Millisecond):
+	}
+}
+
+func TestWatchNewSubdirectory(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	log := &logger.Logger{}
+	log.SetWriter(io.Discard, io.Discard)
+
+	watcher, err := NewWatcher([]string{tmpDir}, []string{"create", "write"}, log)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	defer watcher.Close()
+	watcher.Start()
+
+	// 在 tmpDir 外创建带文件的子树再移入，文件先于监听存在
+	staging := t.TempDir()
+	existing := filepath.Join(staging, "2026", "10", "16", "existing.txt")
+	if err := os.MkdirAll(filepath.Dir(existing), 0755); err != nil {
+		t.Fatalf("Failed to create directories: %v", err)
+	}
+	if err := os.WriteFile(existing, []byte("data"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.Rename(filepath.Join(staging, "2026"), filepath.Join(tmpDir, "2026")); err != nil {
+		t.Fatalf("Failed to move directory: %v", err)
+	}
+
+	wantExisting := filepath.Join(tmpDir, "2026", "10", "16", "existing.txt")
+	waitForEvent(t, watcher, wantExisting)
+
+	// 新目录已加入监听，之后创建的文件也会产生事件
+	later := filepath.Join(tmpDir, "2026", "10", "16", "later.txt")
+	if err := os.WriteFile(later, []byte("data"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	waitForEvent(t, watcher, later)
+}
+
+// waitForEvent 等待指定文件的事件
+func waitForEvent(t *testing.T, watcher *Watcher, path string) {
+	t.Helper()
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case event := <-watcher.Events():
+			if event.FilePath == path {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for event on %s", path)
+		}
+	}
+}
+
+func TestWatcherFilter(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmpDir, "cache"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	log := &logger.Logger{}
+	log.SetWriter(io.Discard, io.Discard)
+
+	watcher, err := NewWatcher([]string{tmpDir}, []string{"create", "write"}, log)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	defer watcher.Close()
+
+	f, _ := filter.New(nil, []string{"cache/**", "**/*.tmp"})
+	watcher.SetFilter(f)
+	watcher.Start()
+
+	for _, name := range []string{filepath.Join("cache", "a.txt"), "b.tmp", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte("data"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	// 只有 c.txt 产生事件
+	timeout := time.After(500 * time.Millisecond)
+	for {
+		select {
+		case event := <-watcher.Events():
+			if event.FilePath != filepath.Join(tmpDir, "c.txt") {
+				t.Errorf("Unexpected event for excluded file: %+v", event)
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
+
+func TestWatcherCosIgnore(t *testing.T) {
+	tmpDir := t.TempDir()
+	ignorePath := filepath.Join(tmpDir, filter.IgnoreFileName)
+	if err := os.WriteFile(ignorePath, []byte("*.log\n"), 0644); err != nil {
+		t.Fatalf("Failed to write .cosignore: %v", err)
+	}
+
+	log := &logger.Logger{}
+	log.SetWriter(io.Discard, io.Discard)
+
+	watcher, err := NewWatcher([]string{tmpDir}, []string{"create", "write"}, log)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	defer watcher.Close()
+	watcher.Start()
+
+	logPath := filepath.Join(tmpDir, "a.log")
+	os.WriteFile(logPath, []byte("data"), 0644)
+
+	timeout := time.After(500 * time.Millisecond)
+	for waiting := true; waiting; {
+		select {
+		case event := <-watcher.Events():
+			if event.FilePath == logPath {
+				t.Fatalf("Unexpected event for ignored file: %+v", event)
+			}
+		case <-timeout:
+			waiting = false
+		}
+	}
+
+	// 修改 .cosignore 后无需重启即可生效
+	os.WriteFile(ignorePath, []byte("*.tmp\n"), 0644)
+	time.Sleep(1100 * time.Millisecond)
+	os.WriteFile(logPath, []byte("more data"), 0644)
+	waitForEvent(t, watcher, logPath)
+}
+
+func TestWatcherPairsRename(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldPath := filepath.Join(tmpDir, "a.txt")
+	os.WriteFile(oldPath, []byte("data"), 0644)
+	os.MkdirAll(filepath.Join(tmpDir, "dst"), 0755)
+	oldDir := filepath.Join(tmpDir, "src")
+	os.MkdirAll(oldDir, 0755)
+	os.WriteFile(filepath.Join(oldDir, "b.txt"), []byte("data"), 0644)
+
+	log := &logger.Logger{}
+	log.SetWriter(io.Discard, io.Discard)
+
+	watcher, err := NewWatcher([]string{tmpDir}, []string{"create", "write"}, log)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	defer watcher.Close()
+	watcher.Start()
+
+	tests := []struct {
+		from, to string
+		want     string // 期望事件的文件
+		wantOld  string
+	}{
+		{oldPath, filepath.Join(tmpDir, "dst", "a.txt"), filepath.Join(tmpDir, "dst", "a.txt"), oldPath},
+		{oldDir, filepath.Join(tmpDir, "dst", "src"), filepath.Join(tmpDir, "dst", "src", "b.txt"), filepath.Join(oldDir, "b.txt")},
+	}
+	for _, tt := range tests {
+		if err := os.Rename(tt.from, tt.to); err != nil {
+			t.Fatalf("Failed to rename: %v", err)
+		}
+
+		timeout := time.After(2 * time.Second)
+	wait:
+		for {
+			select {
+			case event := <-watcher.Events():
+				if event.FilePath != tt.want {
+					continue
+				}
+				if event.OldPath != tt.wantOld {
+					t.Errorf("Expected old path %s for %s, got %q", tt.wantOld, tt.want, event.OldPath)
+				}
+				break wait
+			case <-timeout:
+				t.Fatalf("Timed out waiting for event on %s", tt.want)
+			}
+		}
+	}
+}
+
+func TestWatcherFollowsSymlinkedDirectory(t *testing.T) {
+	tmpDir := t.TempDir()
+	outside := t.TempDir()
+	linked := filepath.Join(tmpDir, "linked")
+	if err := os.Symlink(outside, linked); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+
+	log := &logger.Logger{}
+	log.SetWriter(io.Discard, io.Discard)
+
+	watcher, err := NewWatcher([]string{tmpDir}, []string{"create", "write"}, log)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	defer watcher.Close()
+	watcher.Start()
+
+	// 链接指向的目录中的文件以链接下的路径发出事件
+	os.WriteFile(filepath.Join(outside, "a.txt"), []byte("data"), 0644)
+	waitForEvent(t, watcher, filepath.Join(linked, "a.txt"))
+}
+
+func TestWatcherSymlinkPolicy(t *testing.T) {
+	tests := []struct {
+		policy   filter.SymlinkPolicy
+		wantLink bool
+	}{
+		{filter.SymlinkSkip, false},
+		{filter.SymlinkPreserve, true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			tmpDir := t.TempDir()
+			target := t.TempDir()
+
+			log := &logger.Logger{}
+			log.SetWriter(io.Discard, io.Discard)
+
+			watcher, err := NewWatcher([]string{tmpDir}, []string{"create", "write"}, log)
+			if err != nil {
+				t.Fatalf("Failed to create watcher: %v", err)
+			}
+			defer watcher.Close()
+			watcher.SetSymlinkPolicy(tt.policy)
+			watcher.Start()
+
+			// 指向目录的链接：skip 不发事件，preserve 按文件发出事件
+			link := filepath.Join(tmpDir, "link")
+			if err := os.Symlink(target, link); err != nil {
+				t.Skipf("Symlinks not supported: %v", err)
+			}
+			marker := filepath.Join(tmpDir, "marker.txt")
+			os.WriteFile(marker, []byte("data"), 0644)
+
+			gotLink := false
+			timeout := time.After(2 * time.Second)
+		wait:
+			for {
+				select {
+				case event := <-watcher.Events():
+					if event.FilePath == link {
+						gotLink = true
+					}
+					if event.FilePath == marker {
+						break wait
+					}
+				case <-timeout:
+					t.Fatalf("Timed out waiting for event on %s", marker)
+				}
+			}
+			if gotLink != tt.wantLink {
+				t.Errorf("Expected link event %v, got %v", tt.wantLink, gotLink)
+			}
+		})
+	}
+}